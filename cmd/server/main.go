@@ -18,9 +18,21 @@ func main() {
 		"millseconds after which to clear expired keys in storage",
 	)
 
+	maxBulkLenPtr := flag.Int64(
+		"maxbulklen",
+		microredis.DefaultMaxBulkLen,
+		"maximum length in bytes of a single argument of a request",
+	)
+	maxMultiBulkLenPtr := flag.Int64(
+		"maxmultibulklen",
+		microredis.DefaultMaxMultiBulkLen,
+		"maximum number of arguments in a single request",
+	)
+
 	flag.Parse()
 
 	server := microredis.NewServer(*addressPtr, *portPtr, time.Duration(*clearFreqPtr*int64(time.Millisecond)))
+	server.SetRequestLimits(*maxBulkLenPtr, *maxMultiBulkLenPtr)
 
 	fmt.Printf("Starting Server at %s:%s \n", *addressPtr, *portPtr)
 	server.Run()
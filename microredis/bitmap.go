@@ -0,0 +1,595 @@
+package microredis
+
+import (
+	"errors"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// errBitOffset is returned when a bit offset is not a valid position in a
+// string of at most maxStringLen bytes
+var errBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+
+// getBit function returns the bit at offset of buf, bits past the end of
+// buf are 0. Bits are numbered from the most significant bit of the first
+// byte the same way redis does
+func getBit(buf []byte, offset int64) int {
+	if offset/8 >= int64(len(buf)) {
+		return 0
+	}
+	return int(buf[offset/8]>>(7-uint(offset%8))) & 1
+}
+
+// setBit function sets the bit at offset of buf to bit, buf has to be long
+// enough to hold it
+func setBit(buf []byte, offset int64, bit int) {
+	mask := byte(1) << (7 - uint(offset%8))
+	if bit == 1 {
+		buf[offset/8] |= mask
+	} else {
+		buf[offset/8] &^= mask
+	}
+}
+
+// growBitmap function pads buf with zero bytes so that it holds the bit at
+// offset
+func growBitmap(buf []byte, offset int64) []byte {
+	if size := offset/8 + 1; int64(len(buf)) < size {
+		buf = append(buf, make([]byte, size-int64(len(buf)))...)
+	}
+	return buf
+}
+
+// Bitmap struct denotes a string value written by SETBIT or BITFIELD. It is
+// kept as bytes so that writing bits modifies it in place rather than
+// copying the whole string, every other command reads it as a string
+type Bitmap struct {
+	buf []byte
+}
+
+// getBitmap function returns the string stored at key as bytes, nil if the
+// key does not exist. The bytes of a Bitmap are returned without being
+// copied so they must not be modified
+func (s *Storage) getBitmap(key Key) ([]byte, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		return nil, nil
+	}
+	switch v := val.val.(type) {
+	case *Bitmap:
+		return v.buf, nil
+	case *string:
+		return []byte(*v), nil
+	}
+	return nil, ErrWrongType
+}
+
+// getWritableBitmap function returns the Bitmap stored at key so that it
+// can be modified in place. A string stored at key is converted into a
+// Bitmap keeping its expiry, and an empty Bitmap is stored if the key does
+// not exist
+func (s *Storage) getWritableBitmap(key Key) (*Bitmap, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		result := &Bitmap{buf: []byte{}}
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	switch v := val.val.(type) {
+	case *Bitmap:
+		return v, nil
+	case *string:
+		result := &Bitmap{buf: []byte(*v)}
+		val.val = result
+		s.data[key] = val
+		return result, nil
+	}
+	return nil, ErrWrongType
+}
+
+// SetBit function sets the bit at offset of the string stored at key to
+// bit and returns its previous value. The string is padded with zero bytes
+// if it is too short and a key which does not exist is created
+func (s *Storage) SetBit(key Key, offset int64, bit int) (int, error) {
+	bm, err := s.getWritableBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	bm.buf = growBitmap(bm.buf, offset)
+	result := getBit(bm.buf, offset)
+	setBit(bm.buf, offset, bit)
+	s.keyModified(key)
+	return result, nil
+}
+
+// GetBit function returns the bit at offset of the string stored at key
+func (s *Storage) GetBit(key Key, offset int64) (int, error) {
+	buf, err := s.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	return getBit(buf, offset), nil
+}
+
+// bitRange function converts start and end, both inclusive and possibly
+// negative, into a range of bits of a string of size bytes following the
+// rules of GETRANGE. start and end are offsets of bits if in_bits is set
+// and of bytes otherwise. ok is false if the range is empty
+func bitRange(size int64, start int64, end int64, in_bits bool) (int64, int64, bool) {
+	total := size
+	if in_bits {
+		total = size * 8
+	}
+	if start < 0 {
+		start = total + start
+	}
+	if end < 0 {
+		end = total + end
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+	if start > end {
+		return 0, 0, false
+	}
+	if !in_bits {
+		return start * 8, end*8 + 7, true
+	}
+	return start, end, true
+}
+
+// BitCount function returns the number of bits set to 1 in the string
+// stored at key between start and end, see bitRange. 0 and -1 count the
+// whole string
+func (s *Storage) BitCount(key Key, start int64, end int64, in_bits bool) (int64, error) {
+	buf, err := s.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	first, last, ok := bitRange(int64(len(buf)), start, end, in_bits)
+	if !ok {
+		return 0, nil
+	}
+	result := int64(0)
+	for i := first; i <= last; {
+		if i%8 == 0 && i+7 <= last {
+			// count whole bytes at once
+			result += int64(bits.OnesCount8(buf[i/8]))
+			i += 8
+			continue
+		}
+		result += int64(getBit(buf, i))
+		i++
+	}
+	return result, nil
+}
+
+// BitPos function returns the position of the first bit set to bit in the
+// string stored at key between start and end, see bitRange, or -1 if there
+// is none. When looking for a 0 bit without an explicit end the string is
+// considered to be padded with zero bytes, so the first bit after the
+// range is returned instead of -1
+func (s *Storage) BitPos(key Key, bit int, start int64, end int64, end_given bool, in_bits bool) (int64, error) {
+	buf, err := s.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	if buf == nil {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	first, last, ok := bitRange(int64(len(buf)), start, end, in_bits)
+	if !ok {
+		return -1, nil
+	}
+	for i := first; i <= last; {
+		if i%8 == 0 && i+7 <= last {
+			// skip whole bytes which hold none of the bits looked for
+			if (bit == 1 && buf[i/8] == 0) || (bit == 0 && buf[i/8] == 0xff) {
+				i += 8
+				continue
+			}
+		}
+		if getBit(buf, i) == bit {
+			return i, nil
+		}
+		i++
+	}
+	if bit == 0 && !end_given {
+		return last + 1, nil
+	}
+	return -1, nil
+}
+
+// BitOp function performs the bitwise operation op, one of AND, OR, XOR
+// and NOT, between the strings stored at keys and stores the result at
+// dest. Strings shorter than the longest one are considered to be padded
+// with zero bytes. Returns the length of the string stored at dest, which
+// is deleted if the result is empty
+func (s *Storage) BitOp(op string, dest Key, keys []Key) (int, error) {
+	srcs := make([][]byte, len(keys))
+	size := 0
+	for i, key := range keys {
+		buf, err := s.getBitmap(key)
+		if err != nil {
+			return 0, err
+		}
+		srcs[i] = buf
+		if len(buf) > size {
+			size = len(buf)
+		}
+	}
+	if size == 0 {
+		s.Del([]Key{dest})
+		return 0, nil
+	}
+	result := make([]byte, size)
+	copy(result, srcs[0])
+	if op == "NOT" {
+		for i := range result {
+			result[i] = ^result[i]
+		}
+	}
+	for _, src := range srcs[1:] {
+		for i := range result {
+			b := byte(0)
+			if i < len(src) {
+				b = src[i]
+			}
+			switch op {
+			case "AND":
+				result[i] &= b
+			case "OR":
+				result[i] |= b
+			case "XOR":
+				result[i] ^= b
+			}
+		}
+	}
+	s.Set(dest, string(result), nil, false, false, false, false)
+	return size, nil
+}
+
+// BitFieldOp struct denotes one of the operations of BITFIELD on an
+// integer field of Bits bits at Offset, signed if Signed is set. Op is one
+// of GET, SET or INCRBY and Value holds the value to set or the increment.
+// Overflow is one of WRAP, SAT or FAIL and decides what happens when the
+// result of SET or INCRBY does not fit into the field
+type BitFieldOp struct {
+	Op       string
+	Signed   bool
+	Bits     int
+	Offset   int64
+	Value    int64
+	Overflow string
+}
+
+// get function reads the field of op from buf
+func (op BitFieldOp) get(buf []byte) int64 {
+	raw := uint64(0)
+	for i := int64(0); i < int64(op.Bits); i++ {
+		raw = raw<<1 | uint64(getBit(buf, op.Offset+i))
+	}
+	if op.Signed && op.Bits < 64 && raw&(1<<(op.Bits-1)) != 0 {
+		// extend the sign of negative values
+		raw |= ^uint64(0) << op.Bits
+	}
+	return int64(raw)
+}
+
+// set function writes v into the field of op in buf, buf has to be long
+// enough to hold it
+func (op BitFieldOp) set(buf []byte, v int64) {
+	for i := int64(0); i < int64(op.Bits); i++ {
+		setBit(buf, op.Offset+i, int(uint64(v)>>(int64(op.Bits)-1-i))&1)
+	}
+}
+
+// fit function converts v into a value of the field of op following its
+// overflow mode, ok is false if v does not fit and the mode is FAIL
+func (op BitFieldOp) fit(v *big.Int) (int64, bool) {
+	min, max := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), uint(op.Bits))
+	if op.Signed {
+		min.Rsh(max, 1).Neg(min)
+		max.Rsh(max, 1)
+	}
+	max.Sub(max, big.NewInt(1))
+	if v.Cmp(min) >= 0 && v.Cmp(max) <= 0 {
+		return v.Int64(), true
+	}
+	switch op.Overflow {
+	case "SAT":
+		if v.Cmp(min) < 0 {
+			return min.Int64(), true
+		}
+		return max.Int64(), true
+	case "FAIL":
+		return 0, false
+	}
+	// wrap around into the range of the field
+	size := new(big.Int).Sub(max, min)
+	size.Add(size, big.NewInt(1))
+	result := new(big.Int).Sub(v, min)
+	result.Mod(result, size).Add(result, min)
+	return result.Int64(), true
+}
+
+// BitField function performs the operations on the string stored at key
+// in order. The reply of GET is the value of the field, of SET its previous
+// value and of INCRBY its new value, nil if the operation failed due to an
+// overflow. If any operation writes, the string is padded with zero bytes
+// to hold all the fields written to and a key which does not exist is
+// created
+func (s *Storage) BitField(key Key, ops []BitFieldOp) ([]*int64, error) {
+	write := false
+	for _, op := range ops {
+		write = write || op.Op != "GET"
+	}
+	var buf []byte
+	var bm *Bitmap
+	var err error
+	if write {
+		bm, err = s.getWritableBitmap(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range ops {
+			if op.Op != "GET" {
+				bm.buf = growBitmap(bm.buf, op.Offset+int64(op.Bits)-1)
+			}
+		}
+		buf = bm.buf
+	} else if buf, err = s.getBitmap(key); err != nil {
+		return nil, err
+	}
+	result := make([]*int64, len(ops))
+	for i, op := range ops {
+		cur := op.get(buf)
+		switch op.Op {
+		case "GET":
+			result[i] = &cur
+		case "SET":
+			if v, ok := op.fit(big.NewInt(op.Value)); ok {
+				op.set(buf, v)
+				result[i] = &cur
+			}
+		case "INCRBY":
+			sum := new(big.Int).Add(big.NewInt(cur), big.NewInt(op.Value))
+			if v, ok := op.fit(sum); ok {
+				op.set(buf, v)
+				result[i] = &v
+			}
+		}
+	}
+	if write {
+		s.keyModified(key)
+	}
+	return result, nil
+}
+
+// parseBitOffset function parses the offset of a bit in a string, which
+// can't be bigger than maxStringLen bytes
+func parseBitOffset(arg string) (int64, error) {
+	offset, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || offset < 0 || offset >= maxStringLen*8 {
+		return 0, errBitOffset
+	}
+	return offset, nil
+}
+
+// parseBit function parses a bit argument which is either 0 or 1
+func parseBit(arg string, err error) (int, error) {
+	switch arg {
+	case "0":
+		return 0, nil
+	case "1":
+		return 1, nil
+	}
+	return 0, err
+}
+
+// ProcessRespCommandSetBit function processes redis command SETBIT
+func (s *Server) ProcessRespCommandSetBit(c *Connection, commands []string) (interface{}, error) {
+	offset, err := parseBitOffset(commands[2])
+	if err != nil {
+		return nil, err
+	}
+	bit, err := parseBit(commands[3], errors.New("ERR bit is not an integer or out of range"))
+	if err != nil {
+		return nil, err
+	}
+	return s.db.SetBit(Key(commands[1]), offset, bit)
+}
+
+// ProcessRespCommandGetBit function processes redis command GETBIT
+func (s *Server) ProcessRespCommandGetBit(c *Connection, commands []string) (interface{}, error) {
+	offset, err := parseBitOffset(commands[2])
+	if err != nil {
+		return nil, err
+	}
+	return s.db.GetBit(Key(commands[1]), offset)
+}
+
+// parseBitRange function parses the optional start, end and BYTE or BIT
+// unit arguments of BITCOUNT and BITPOS. Without them the range covers the
+// whole string
+func parseBitRange(args []string) (int64, int64, bool, error) {
+	start, end, in_bits := int64(0), int64(-1), false
+	if len(args) > 3 {
+		return 0, 0, false, ErrSyntax
+	}
+	bounds := []*int64{&start, &end}
+	for i, arg := range args {
+		if i == 2 {
+			switch strings.ToUpper(arg) {
+			case "BIT":
+				in_bits = true
+			case "BYTE":
+			default:
+				return 0, 0, false, ErrSyntax
+			}
+			continue
+		}
+		val, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return 0, 0, false, ErrNotInteger
+		}
+		*bounds[i] = val
+	}
+	return start, end, in_bits, nil
+}
+
+// ProcessRespCommandBitCount function processes redis command BITCOUNT
+func (s *Server) ProcessRespCommandBitCount(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) == 3 {
+		return nil, ErrSyntax
+	}
+	start, end, in_bits, err := parseBitRange(commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	return s.db.BitCount(Key(commands[1]), start, end, in_bits)
+}
+
+// ProcessRespCommandBitPos function processes redis command BITPOS
+func (s *Server) ProcessRespCommandBitPos(c *Connection, commands []string) (interface{}, error) {
+	bit, err := parseBit(commands[2], errors.New("ERR The bit argument must be 1 or 0."))
+	if err != nil {
+		return nil, err
+	}
+	start, end, in_bits, err := parseBitRange(commands[3:])
+	if err != nil {
+		return nil, err
+	}
+	return s.db.BitPos(Key(commands[1]), bit, start, end, len(commands) > 4, in_bits)
+}
+
+// ProcessRespCommandBitOp function processes redis command BITOP
+func (s *Server) ProcessRespCommandBitOp(c *Connection, commands []string) (interface{}, error) {
+	op := strings.ToUpper(commands[1])
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(commands) != 4 {
+			return nil, errors.New("ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return nil, ErrSyntax
+	}
+	return s.db.BitOp(op, Key(commands[2]), toKeys(commands[3:]))
+}
+
+// parseBitFieldType function parses the type of a BITFIELD field such as
+// i16 or u8, signed fields can have up to 64 bits and unsigned ones up to
+// 63 bits
+func parseBitFieldType(arg string) (bool, int, error) {
+	err := errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(arg) < 2 {
+		return false, 0, err
+	}
+	signed := arg[0] == 'i' || arg[0] == 'I'
+	if !signed && arg[0] != 'u' && arg[0] != 'U' {
+		return false, 0, err
+	}
+	size, perr := strconv.Atoi(arg[1:])
+	if perr != nil || size < 1 || (signed && size > 64) || (!signed && size > 63) {
+		return false, 0, err
+	}
+	return signed, size, nil
+}
+
+// parseBitFieldOffset function parses the offset of a BITFIELD field, an
+// offset prefixed with # is multiplied by the size of the field
+func parseBitFieldOffset(arg string, size int) (int64, error) {
+	multiply := strings.HasPrefix(arg, "#")
+	if multiply {
+		arg = arg[1:]
+	}
+	offset, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errBitOffset
+	}
+	if multiply {
+		if offset > maxStringLen*8/int64(size) {
+			return 0, errBitOffset
+		}
+		offset *= int64(size)
+	}
+	if offset+int64(size) > maxStringLen*8 {
+		return 0, errBitOffset
+	}
+	return offset, nil
+}
+
+// ProcessRespCommandBitField function processes redis command BITFIELD
+// OVERFLOW changes the overflow mode of the SET and INCRBY operations
+// which follow it, the default mode being WRAP
+func (s *Server) ProcessRespCommandBitField(c *Connection, commands []string) (interface{}, error) {
+	ops := []BitFieldOp{}
+	overflow := "WRAP"
+	for i := 2; i < len(commands); i++ {
+		op := strings.ToUpper(commands[i])
+		switch op {
+		case "OVERFLOW":
+			if i+1 >= len(commands) {
+				return nil, ErrSyntax
+			}
+			overflow = strings.ToUpper(commands[i+1])
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				return nil, errors.New("ERR Invalid OVERFLOW type specified")
+			}
+			i++
+			continue
+		case "GET":
+			if i+2 >= len(commands) {
+				return nil, ErrSyntax
+			}
+		case "SET", "INCRBY":
+			if i+3 >= len(commands) {
+				return nil, ErrSyntax
+			}
+		default:
+			return nil, ErrSyntax
+		}
+		signed, size, err := parseBitFieldType(commands[i+1])
+		if err != nil {
+			return nil, err
+		}
+		offset, err := parseBitFieldOffset(commands[i+2], size)
+		if err != nil {
+			return nil, err
+		}
+		field := BitFieldOp{Op: op, Signed: signed, Bits: size, Offset: offset, Overflow: overflow}
+		i += 2
+		if op != "GET" {
+			val, err := strconv.ParseInt(commands[i+1], 10, 64)
+			if err != nil {
+				return nil, ErrNotInteger
+			}
+			field.Value = val
+			i++
+		}
+		ops = append(ops, field)
+	}
+	fields, err := s.db.BitField(Key(commands[1]), ops)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if field != nil {
+			result[i] = *field
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,193 @@
+package microredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestBitmapStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	// the string grows with zero bytes to hold the bit
+	old, err := s.SetBit(m.Key("b"), 17, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, old)
+	assert.Equal(t, "\x00\x00\x40", *getString(t, s, "b"))
+	old, _ = s.SetBit(m.Key("b"), 17, 0)
+	assert.Equal(t, 1, old)
+	s.SetBit(m.Key("b"), 0, 1)
+	assert.Equal(t, "\x80\x00\x00", *getString(t, s, "b"))
+	bit, _ := s.GetBit(m.Key("b"), 0)
+	assert.Equal(t, 1, bit)
+	bit, _ = s.GetBit(m.Key("b"), 1000)
+	assert.Equal(t, 0, bit)
+
+	// setting bits keeps the expiry of the key
+	s.Expire(m.Key("b"), 100, false, false, false, false)
+	s.SetBit(m.Key("b"), 1, 1)
+	ttl := s.TTL(m.Key("b"))
+	assert.Greater(t, ttl, int64(0))
+
+	s.Set(m.Key("s"), "foobar", nil, false, false, false, false)
+	count, err := s.BitCount(m.Key("s"), 0, -1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(26), count)
+	count, _ = s.BitCount(m.Key("s"), 5, 30, true)
+	assert.Equal(t, int64(17), count)
+	count, _ = s.BitCount(m.Key("s"), 3, 1, false)
+	assert.Equal(t, int64(0), count)
+
+	s.Set(m.Key("p"), "\xff\xf0\x00", nil, false, false, false, false)
+	pos, err := s.BitPos(m.Key("p"), 0, 0, -1, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), pos)
+	pos, _ = s.BitPos(m.Key("p"), 1, 2, -1, true, false)
+	assert.Equal(t, int64(-1), pos)
+	pos, _ = s.BitPos(m.Key("p"), 1, 7, 15, true, true)
+	assert.Equal(t, int64(7), pos)
+
+	// a string read before its bits are set is not modified along with them
+	s.Set(m.Key("v"), "\x00", nil, false, false, false, false)
+	before := getString(t, s, "v")
+	s.SetBit(m.Key("v"), 7, 1)
+	assert.Equal(t, "\x00", *before)
+	assert.Equal(t, "\x01", *getString(t, s, "v"))
+	assert.Equal(t, "string", s.Type(m.Key("v")))
+	n, _ := s.Append(m.Key("v"), "a")
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "\x01a", *getString(t, s, "v"))
+
+	s.Push(m.Key("l"), []string{"x"}, true)
+	_, err = s.SetBit(m.Key("l"), 0, 1)
+	assert.Equal(t, m.ErrWrongType, err)
+	_, err = s.BitOp("AND", m.Key("dst"), []m.Key{"s", "l"})
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestBitField(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	fields, err := s.BitField(m.Key("f"), []m.BitFieldOp{
+		{Op: "SET", Signed: true, Bits: 8, Offset: 0, Value: -1, Overflow: "WRAP"},
+		{Op: "GET", Signed: false, Bits: 8, Offset: 0},
+		{Op: "GET", Signed: true, Bits: 4, Offset: 4},
+		{Op: "INCRBY", Signed: false, Bits: 4, Offset: 0, Value: 1, Overflow: "WRAP"},
+		{Op: "INCRBY", Signed: true, Bits: 8, Offset: 8, Value: 200, Overflow: "SAT"},
+		{Op: "INCRBY", Signed: true, Bits: 8, Offset: 8, Value: -300, Overflow: "SAT"},
+		{Op: "SET", Signed: false, Bits: 3, Offset: 16, Value: 8, Overflow: "FAIL"},
+	})
+	assert.NoError(t, err)
+	result := []interface{}{}
+	for _, field := range fields {
+		if field == nil {
+			result = append(result, nil)
+		} else {
+			result = append(result, *field)
+		}
+	}
+	assert.Equal(t, []interface{}{int64(0), int64(255), int64(-1), int64(0), int64(127), int64(-128), nil}, result)
+	// the string holds all the fields written to, even the failed ones
+	assert.Equal(t, "\x0f\x80\x00", *getString(t, s, "f"))
+
+	fields, _ = s.BitField(m.Key("i64"), []m.BitFieldOp{
+		{Op: "SET", Signed: true, Bits: 64, Offset: 0, Value: 9223372036854775807, Overflow: "WRAP"},
+		{Op: "INCRBY", Signed: true, Bits: 64, Offset: 0, Value: 1, Overflow: "WRAP"},
+	})
+	assert.Equal(t, int64(-9223372036854775808), *fields[1])
+
+	// reading only does not create the key
+	fields, _ = s.BitField(m.Key("missing"), []m.BitFieldOp{{Op: "GET", Bits: 8, Offset: 100}})
+	assert.Equal(t, int64(0), *fields[0])
+	assert.Equal(t, "none", s.Type(m.Key("missing")))
+}
+
+func TestBitmapCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"setbit and getbit", []step{
+			{args: []string{"SETBIT", "b", "7", "1"}, reply: ":0\r\n"},
+			{args: []string{"GET", "b"}, reply: "$1\r\n\x01\r\n"},
+			{args: []string{"GETBIT", "b", "7"}, reply: ":1\r\n"},
+			{args: []string{"GETBIT", "b", "100"}, reply: ":0\r\n"},
+			{args: []string{"SETBIT", "b", "7", "0"}, reply: ":1\r\n"},
+			{args: []string{"SETBIT", "b", "-1", "1"}, reply: "-ERR bit offset is not an integer or out of range\r\n"},
+			{args: []string{"SETBIT", "b", "4294967296", "1"}, reply: "-ERR bit offset is not an integer or out of range\r\n"},
+			{args: []string{"SETBIT", "b", "1", "2"}, reply: "-ERR bit is not an integer or out of range\r\n"},
+			{args: []string{"GETBIT", "missing", "0"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "b"}, reply: "+string\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "b"}, reply: "$3\r\nraw\r\n"},
+			{args: []string{"SET", "e", ""}, reply: "+OK\r\n"},
+			{args: []string{"BITPOS", "e", "0"}, reply: ":-1\r\n"},
+		}},
+		{"bitcount", []step{
+			{args: []string{"SET", "s", "foobar"}, reply: "+OK\r\n"},
+			{args: []string{"BITCOUNT", "s"}, reply: ":26\r\n"},
+			{args: []string{"BITCOUNT", "s", "0", "0"}, reply: ":4\r\n"},
+			{args: []string{"BITCOUNT", "s", "1", "1", "BYTE"}, reply: ":6\r\n"},
+			{args: []string{"BITCOUNT", "s", "5", "30", "bit"}, reply: ":17\r\n"},
+			{args: []string{"BITCOUNT", "s", "-2", "-1"}, reply: ":7\r\n"},
+			{args: []string{"BITCOUNT", "s", "0"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"BITCOUNT", "s", "0", "-1", "BITS"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"BITCOUNT", "s", "a", "-1"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"BITCOUNT", "missing"}, reply: ":0\r\n"},
+		}},
+		{"bitpos", []step{
+			{args: []string{"SET", "p", "\x00\xff\xf0"}, reply: "+OK\r\n"},
+			{args: []string{"BITPOS", "p", "1"}, reply: ":8\r\n"},
+			{args: []string{"BITPOS", "p", "1", "2"}, reply: ":16\r\n"},
+			{args: []string{"BITPOS", "p", "1", "7", "15", "BIT"}, reply: ":8\r\n"},
+			{args: []string{"BITPOS", "p", "0", "1"}, reply: ":20\r\n"},
+			{args: []string{"SET", "f", "\xff\xff\xff"}, reply: "+OK\r\n"},
+			{args: []string{"BITPOS", "f", "0"}, reply: ":24\r\n"},
+			{args: []string{"BITPOS", "f", "0", "0", "-1"}, reply: ":-1\r\n"},
+			{args: []string{"BITPOS", "missing", "0"}, reply: ":0\r\n"},
+			{args: []string{"BITPOS", "missing", "1"}, reply: ":-1\r\n"},
+			{args: []string{"BITPOS", "p", "2"}, reply: "-ERR The bit argument must be 1 or 0.\r\n"},
+		}},
+		{"bitop", []step{
+			{args: []string{"SET", "k1", "foobar"}, reply: "+OK\r\n"},
+			{args: []string{"SET", "k2", "abcdef"}, reply: "+OK\r\n"},
+			{args: []string{"BITOP", "AND", "dest", "k1", "k2"}, reply: ":6\r\n"},
+			{args: []string{"GET", "dest"}, reply: "$6\r\n`bc`ab\r\n"},
+			{args: []string{"BITOP", "or", "dest", "k1", "k2", "missing"}, reply: ":6\r\n"},
+			{args: []string{"GET", "dest"}, reply: "$6\r\ngoofev\r\n"},
+			{args: []string{"SET", "short", "\x0f"}, reply: "+OK\r\n"},
+			{args: []string{"BITOP", "NOT", "dest", "short"}, reply: ":1\r\n"},
+			{args: []string{"GET", "dest"}, reply: "$1\r\n\xf0\r\n"},
+			{args: []string{"BITOP", "XOR", "dest", "short", "k1"}, reply: ":6\r\n"},
+			{args: []string{"GETRANGE", "dest", "0", "1"}, reply: "$2\r\nio\r\n"},
+			{args: []string{"BITOP", "NOT", "dest", "k1", "k2"}, reply: "-ERR BITOP NOT must be called with a single source key.\r\n"},
+			{args: []string{"BITOP", "NAND", "dest", "k1"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"BITOP", "AND", "dest", "missing"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "dest"}, reply: "+none\r\n"},
+			{args: []string{"COMMAND", "GETKEYS", "BITOP", "AND", "dest", "k1", "k2"}, reply: "*3\r\n$4\r\ndest\r\n$2\r\nk1\r\n$2\r\nk2\r\n"},
+		}},
+		{"bitfield", []step{
+			{args: []string{"BITFIELD", "f", "INCRBY", "i5", "100", "1", "GET", "u4", "0"}, reply: "*2\r\n:1\r\n:0\r\n"},
+			{args: []string{"BITFIELD", "f", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"}, reply: "*2\r\n:1\r\n:1\r\n"},
+			{args: []string{"BITFIELD", "f", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"}, reply: "*2\r\n:2\r\n:2\r\n"},
+			{args: []string{"BITFIELD", "f", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"}, reply: "*2\r\n:3\r\n:3\r\n"},
+			{args: []string{"BITFIELD", "f", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"}, reply: "*2\r\n:0\r\n:3\r\n"},
+			{args: []string{"BITFIELD", "f", "OVERFLOW", "FAIL", "INCRBY", "u2", "102", "1"}, reply: "*1\r\n$-1\r\n"},
+			{args: []string{"BITFIELD", "g", "SET", "u8", "#1", "200", "GET", "u8", "8", "GET", "i8", "#1"}, reply: "*3\r\n:0\r\n:200\r\n:-56\r\n"},
+			{args: []string{"BITFIELD", "g", "GET", "u64", "0"}, reply: "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n"},
+			{args: []string{"BITFIELD", "g", "GET", "i65", "0"}, reply: "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n"},
+			{args: []string{"BITFIELD", "g", "GET", "i8", "-1"}, reply: "-ERR bit offset is not an integer or out of range\r\n"},
+			{args: []string{"BITFIELD", "g", "OVERFLOW", "NONE", "GET", "i8", "0"}, reply: "-ERR Invalid OVERFLOW type specified\r\n"},
+			{args: []string{"BITFIELD", "g", "SET", "i8", "0", "x"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"BITFIELD", "g", "GET", "i8"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"BITFIELD", "g", "DEL", "i8", "0"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"BITFIELD", "g"}, reply: "*0\r\n"},
+			{args: []string{"BITFIELD", "missing", "GET", "u8", "0"}, reply: "*1\r\n:0\r\n"},
+			{args: []string{"TYPE", "missing"}, reply: "+none\r\n"},
+		}},
+		{"wrongtype", []step{
+			{args: []string{"RPUSH", "l", "a"}, reply: ":1\r\n"},
+			{args: []string{"SETBIT", "l", "0", "1"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"BITCOUNT", "l"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"BITFIELD", "l", "GET", "u8", "0"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}
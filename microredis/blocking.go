@@ -0,0 +1,220 @@
+package microredis
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// blockOn struct is returned as the reply of a blocking command which
+// can't be served right away. Rather than sending it to the client the
+// server parks the connection on keys until one of them is modified or
+// timeout passes, a timeout of 0 blocks indefinitely
+// commands, if set, is executed again instead of the blocking command, for
+// commands whose arguments refer to the state at the time they blocked
+// such as the $ ID of XREAD
+type blockOn struct {
+	keys     []Key
+	timeout  time.Duration
+	commands []string
+}
+
+// waiter struct denotes a client parked by a blocking command. commands
+// is the blocking command which is executed again whenever one of keys
+// is modified, until it replies with something other than blockOn
+// served is set once the waiter has been removed from the queues of its
+// keys, along with the reply if it was served. Both are guarded by the
+// server lock and woken is closed to wake the parked connection up
+type waiter struct {
+	c        *Connection
+	commands []string
+	keys     []Key
+	served   bool
+	reply    interface{}
+	woken    chan struct{}
+}
+
+// parseTimeout function parses the timeout argument of blocking commands
+// given in seconds, fractions of a second are allowed
+func parseTimeout(arg string) (time.Duration, error) {
+	secs, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) || secs > math.MaxInt64/float64(time.Second) {
+		return 0, errors.New("ERR timeout is not a float or out of range")
+	}
+	if secs < 0 {
+		return 0, errors.New("ERR timeout is negative")
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// parseTimeoutMillis function parses the timeout argument of blocking
+// commands given in milliseconds such as the BLOCK option of XREAD
+func parseTimeoutMillis(arg string) (time.Duration, error) {
+	millis, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || millis > math.MaxInt64/int64(time.Millisecond) {
+		return 0, errors.New("ERR timeout is not an integer or out of range")
+	}
+	if millis < 0 {
+		return 0, errors.New("ERR timeout is negative")
+	}
+	return time.Duration(millis) * time.Millisecond, nil
+}
+
+// park function parks connection c on the keys of b until it is served
+// by serveBlocked, b.timeout passes or the client disconnects. It has to
+// be called with the server lock held, which is released while parked and
+// held again when it returns. The reply of the command is returned, ok is
+// false if the client disconnected
+// Replies buffered before the blocking command are flushed before parking
+// so that pipelining clients receive them without waiting
+func (s *Server) park(c *Connection, commands []string, b blockOn) (interface{}, bool) {
+	if b.commands != nil {
+		commands = b.commands
+	}
+	w := &waiter{
+		c:        c,
+		commands: commands,
+		keys:     b.keys,
+		woken:    make(chan struct{}),
+	}
+	for _, key := range w.keys {
+		s.waiters[key] = append(s.waiters[key], w)
+		s.db.block(key)
+	}
+	// earlier commands of the batch may already have made keys ready
+	s.serveBlocked()
+	s.lock.Unlock()
+
+	var timeout <-chan time.Time
+	if b.timeout > 0 {
+		timer := time.NewTimer(b.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	gone, stop := c.watchDisconnect()
+	disconnected := false
+	if err := c.Flush(); err != nil {
+		disconnected = true
+	} else {
+		select {
+		case <-w.woken:
+		case <-timeout:
+		case <-gone:
+			disconnected = true
+		}
+	}
+	stop()
+
+	s.lock.Lock()
+	if !w.served {
+		s.unpark(w)
+		w.reply = NullArray{}
+	}
+	return w.reply, !disconnected
+}
+
+// unpark function removes w from the queues of all its keys
+func (s *Server) unpark(w *waiter) {
+	w.served = true
+	for _, key := range w.keys {
+		queue := s.waiters[key]
+		for i, other := range queue {
+			if other == w {
+				queue = append(queue[:i:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(s.waiters, key)
+		} else {
+			s.waiters[key] = queue
+		}
+		s.db.unblock(key)
+	}
+}
+
+// serveBlocked function serves the clients parked on keys modified since
+// it was last called. For each ready key the clients parked on it are
+// served in the order they were parked by executing their command again
+// A client whose command still can't be served stays parked. Serving a
+// command may modify other keys which are then served as well
+// Note: the caller has to hold the server lock
+func (s *Server) serveBlocked() {
+	for {
+		ready := s.db.readyKeys()
+		if len(ready) == 0 {
+			return
+		}
+		for _, key := range ready {
+			queue := append([]*waiter{}, s.waiters[key]...)
+			for _, w := range queue {
+				if w.served {
+					continue
+				}
+				response, err := s.ProcessRESP(w.c, w.commands)
+				if _, ok := response.(blockOn); ok && err == nil {
+					continue
+				}
+				s.unpark(w)
+				if err != nil {
+					w.reply = err
+				} else {
+					w.reply = response
+				}
+				close(w.woken)
+			}
+		}
+	}
+}
+
+// watchDisconnect function watches the connection for the client closing
+// it while parked, in which case gone is closed. stop has to be called
+// before reading from the connection again, it interrupts the watch
+// Requests the client pipelines while parked are kept buffered and read
+// as usual once the blocking command replies
+func (c *Connection) watchDisconnect() (gone <-chan struct{}, stop func()) {
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if c.reader.Buffered() > 0 {
+			return
+		}
+		if _, err := c.reader.rd.Peek(1); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			close(closed)
+		}
+	}()
+	stop = func() {
+		c.conn.SetReadDeadline(time.Now())
+		<-done
+		c.conn.SetReadDeadline(time.Time{})
+	}
+	return closed, stop
+}
+
+// ProcessRespCommandWaitKey function processes the command WAITKEY which
+// is not part of redis. It replies with the first of the keys holding a
+// string as an array of the key and its value. If none of the keys exist
+// the client is blocked until one of them is set or timeout seconds pass,
+// in which case a nil array is replied. A timeout of 0 blocks indefinitely
+func (s *Server) ProcessRespCommandWaitKey(c *Connection, commands []string) (interface{}, error) {
+	timeout, err := parseTimeout(commands[len(commands)-1])
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(commands)-2)
+	for _, arg := range commands[1 : len(commands)-1] {
+		key := Key(arg)
+		val, err := s.db.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if val != nil {
+			return []string{arg, *val}, nil
+		}
+		keys = append(keys, key)
+	}
+	return blockOn{keys: keys, timeout: timeout}, nil
+}
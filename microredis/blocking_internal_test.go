@@ -0,0 +1,94 @@
+package microredis
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// parkedOn function returns the clients parked on key in the order they
+// were parked
+func (s *Server) parkedOn(key Key) []*waiter {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*waiter{}, s.waiters[key]...)
+}
+
+// WaitParked function waits until n clients are parked on key, failing the
+// test if they aren't within a few seconds. The tests of microredis_test
+// use it to know that a client which sent a blocking command is parked
+func (s *Server) WaitParked(t *testing.T, key Key, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.parkedOn(key)) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients parked on %q, expected %d", len(s.parkedOn(key)), key, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestParkDisconnect(t *testing.T) {
+	s := NewServer("localhost", "6379", time.Second)
+	client, server := net.Pipe()
+	go s.HandleConnection(server)
+	defer client.Close()
+
+	if _, err := client.Write([]byte(MarshalResp([]string{"WAITKEY", "a", "b", "0"}))); err != nil {
+		t.Fatal(err)
+	}
+	s.WaitParked(t, Key("a"), 1)
+	s.WaitParked(t, Key("b"), 1)
+
+	// the client is removed from the queues of all its keys
+	client.Close()
+	s.WaitParked(t, Key("a"), 0)
+	s.WaitParked(t, Key("b"), 0)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.waiters) != 0 {
+		t.Errorf("waiters left: %v", s.waiters)
+	}
+	if s.db.blocked[Key("a")] != 0 || s.db.blocked[Key("b")] != 0 {
+		t.Errorf("keys still blocked: %v", s.db.blocked)
+	}
+}
+
+func TestServeBlockedOrder(t *testing.T) {
+	s := NewServer("localhost", "6379", time.Second)
+	c := NewConnection(nil, 1)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var parked []*waiter
+	for _, commands := range [][]string{
+		{"APPEND", "log", "1"},
+		{"WAITKEY", "other", "0"},
+		{"APPEND", "log", "2"},
+		{"APPEND", "log", "3"},
+	} {
+		w := &waiter{c: c, commands: commands, keys: []Key{"k"}, woken: make(chan struct{})}
+		s.waiters[Key("k")] = append(s.waiters[Key("k")], w)
+		s.db.block(Key("k"))
+		parked = append(parked, w)
+	}
+
+	// the clients are served in the order they parked and a client whose
+	// command still blocks keeps its place
+	s.db.Set(Key("k"), "v", nil, false, false, false, false)
+	s.serveBlocked()
+	if log, _ := s.db.Get(Key("log")); log == nil || *log != "123" {
+		t.Fatalf("clients served out of order: %v", log)
+	}
+	if queue := s.waiters[Key("k")]; len(queue) != 1 || queue[0] != parked[1] {
+		t.Fatalf("unexpected clients parked: %v", queue)
+	}
+	for i, w := range parked {
+		if w.served != (i != 1) {
+			t.Errorf("client %d served: %v", i, w.served)
+		}
+	}
+	if s.db.blocked[Key("k")] != 1 {
+		t.Errorf("%d clients blocked on k, expected 1", s.db.blocked[Key("k")])
+	}
+}
@@ -0,0 +1,83 @@
+package microredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestWaitKey(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"existing key", []step{
+			{args: []string{"SET", "b", "v"}, reply: "+OK\r\n"},
+			{args: []string{"WAITKEY", "a", "b", "0"}, reply: "*2\r\n$1\r\nb\r\n$1\r\nv\r\n"},
+		}},
+		{"timeout", []step{
+			{args: []string{"WAITKEY", "a", "0.05"}, reply: "*-1\r\n"},
+			{args: []string{"HELLO", "3"}, pattern: `^%7`},
+			{args: []string{"WAITKEY", "a", "0.01"}, reply: "_\r\n"},
+		}},
+		{"errors", []step{
+			{args: []string{"WAITKEY", "a", "x"}, reply: "-ERR timeout is not a float or out of range\r\n"},
+			{args: []string{"WAITKEY", "a", "-1"}, reply: "-ERR timeout is negative\r\n"},
+			{args: []string{"WAITKEY", "0"}, reply: "-ERR wrong number of arguments for 'waitkey' command\r\n"},
+			{args: []string{"RPUSH", "l", "a"}, reply: ":1\r\n"},
+			{args: []string{"WAITKEY", "l", "0"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+
+	start := time.Now()
+	c := newTestConn(t, m.NewServer("localhost", "6379", time.Second))
+	assert.Equal(t, "*-1\r\n", c.do("WAITKEY", "a", "0.1"))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestWaitKeyWakeUp(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c1 := newTestConn(t, s)
+	c2 := newTestConn(t, s)
+	c3 := newTestConn(t, s)
+
+	// commands pipelined after the blocking one run once it replies
+	c1.send(m.MarshalResp([]string{"SET", "x", "1"}) + m.MarshalResp([]string{"WAITKEY", "a", "b", "0"}) + m.MarshalResp([]string{"GET", "b"}))
+	assert.Equal(t, "+OK\r\n", c1.read())
+	s.WaitParked(t, m.Key("b"), 1)
+	c2.send(m.MarshalResp([]string{"WAITKEY", "b", "0"}))
+	s.WaitParked(t, m.Key("b"), 2)
+
+	// the lock is not held while clients are parked
+	assert.Equal(t, "$1\r\n1\r\n", c3.do("GET", "x"))
+	assert.Equal(t, "+OK\r\n", c3.do("SET", "b", "v"))
+
+	assert.Equal(t, "*2\r\n$1\r\nb\r\n$1\r\nv\r\n", c1.read())
+	assert.Equal(t, "$1\r\nv\r\n", c1.read())
+	assert.Equal(t, "*2\r\n$1\r\nb\r\n$1\r\nv\r\n", c2.read())
+}
+
+func TestWaitKeyWrongType(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c1 := newTestConn(t, s)
+	c2 := newTestConn(t, s)
+
+	c1.send(m.MarshalResp([]string{"WAITKEY", "k", "0"}))
+	s.WaitParked(t, m.Key("k"), 1)
+	assert.Equal(t, ":1\r\n", c2.do("RPUSH", "k", "a"))
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", c1.read())
+}
+
+func TestWaitKeyDisconnect(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c1 := newTestConn(t, s)
+	c2 := newTestConn(t, s)
+
+	c1.send(m.MarshalResp([]string{"WAITKEY", "k", "0"}))
+	s.WaitParked(t, m.Key("k"), 1)
+	c1.conn.Close()
+	s.WaitParked(t, m.Key("k"), 0)
+
+	// the disconnected client is no longer parked
+	assert.Equal(t, "+OK\r\n", c2.do("SET", "k", "v"))
+	assert.Equal(t, "*2\r\n$1\r\nk\r\n$1\r\nv\r\n", c2.do("WAITKEY", "k", "0"))
+}
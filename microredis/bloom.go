@@ -0,0 +1,420 @@
+package microredis
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// bloomDefaultErrorRate and bloomDefaultCapacity are the parameters of
+	// the filters BF.ADD and BF.MADD create, the same as RedisBloom
+	bloomDefaultErrorRate = 0.01
+	bloomDefaultCapacity  = 100
+	bloomDefaultExpansion = 2
+	// bloomTightening is the ratio the error rate of each filter added to a
+	// scalable Bloom filter is multiplied by, so that the overall error rate
+	// stays below the requested one
+	bloomTightening = 0.5
+	// bloomMaxCapacity and bloomMaxExpansion bound the parameters of
+	// BF.RESERVE like RedisBloom does, and bloomMaxBits bounds the size of
+	// each filter so that neither a tiny error rate nor scaling can make a
+	// filter take unbounded memory
+	bloomMaxCapacity  = 1 << 30
+	bloomMaxExpansion = 32768
+	bloomMaxBits      = 8 * maxStringLen
+)
+
+// errBloomNotFound is returned by the commands which require the filter to
+// exist
+var errBloomNotFound = errors.New("ERR not found")
+
+// errBloomTooLarge is returned when a filter would exceed bloomMaxBits
+var errBloomTooLarge = errors.New("ERR filter would exceed the maximum size")
+
+// bloomLayer struct denotes one of the fixed size filters a scalable Bloom
+// filter is made of, it holds up to capacity items
+type bloomLayer struct {
+	bits     []uint64
+	size     uint64
+	hashes   int
+	capacity int64
+	items    int64
+}
+
+// bloomBitsPerEntry function returns the number of bits a filter with the
+// given error rate needs for each item, as RedisBloom does
+func bloomBitsPerEntry(error_rate float64) float64 {
+	return -math.Log(error_rate) / (math.Ln2 * math.Ln2)
+}
+
+// bloomFits function returns whether a filter for capacity items with the
+// given error rate stays within bloomMaxBits
+func bloomFits(capacity int64, error_rate float64) bool {
+	return math.Ceil(float64(capacity)*bloomBitsPerEntry(error_rate)) <= bloomMaxBits
+}
+
+// newBloomLayer function creates a filter sized for capacity items with
+// the given error rate, which has to fit within bloomMaxBits
+func newBloomLayer(capacity int64, error_rate float64) *bloomLayer {
+	bpe := bloomBitsPerEntry(error_rate)
+	size := uint64(math.Ceil(float64(capacity) * bpe))
+	return &bloomLayer{
+		bits:     make([]uint64, (size+63)/64),
+		size:     size,
+		hashes:   int(math.Ceil(math.Ln2 * bpe)),
+		capacity: capacity,
+	}
+}
+
+// bloomHashes function returns the two hashes of item the positions of its
+// bits are derived from
+func bloomHashes(item string) (uint64, uint64) {
+	a := murmurHash64A([]byte(item), 0xc6a4a7935bd1e995)
+	return a, murmurHash64A([]byte(item), a)
+}
+
+// contains function returns whether every bit of the item is set
+func (l *bloomLayer) contains(a uint64, b uint64) bool {
+	for i := 0; i < l.hashes; i++ {
+		bit := (a + uint64(i)*b) % l.size
+		if l.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add function sets every bit of the item
+func (l *bloomLayer) add(a uint64, b uint64) {
+	for i := 0; i < l.hashes; i++ {
+		bit := (a + uint64(i)*b) % l.size
+		l.bits[bit/64] |= 1 << (bit % 64)
+	}
+	l.items++
+}
+
+// BloomFilter struct denotes the scalable Bloom filter datatype which tests
+// whether items were added to it, with false positives but no false
+// negatives. Once its last filter is full a filter expansion times larger
+// is added, unless expansion is 0 for a filter which does not scale
+type BloomFilter struct {
+	layers     []*bloomLayer
+	error_rate float64
+	expansion  int64
+}
+
+// NewBloomFilter function creates an empty Bloom filter for capacity items
+// with the given error rate
+func NewBloomFilter(error_rate float64, capacity int64, expansion int64) *BloomFilter {
+	return &BloomFilter{
+		layers:     []*bloomLayer{newBloomLayer(capacity, error_rate*bloomTightening)},
+		error_rate: error_rate,
+		expansion:  expansion,
+	}
+}
+
+// Exists function returns whether item may have been added to the filter
+func (bf *BloomFilter) Exists(item string) bool {
+	a, b := bloomHashes(item)
+	for _, l := range bf.layers {
+		if l.contains(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add function adds item to the filter, it returns false if the item may
+// have been added already. An error is returned if the filter is full and
+// can't scale, or the filter it would scale with is too large
+func (bf *BloomFilter) Add(item string) (bool, error) {
+	a, b := bloomHashes(item)
+	for _, l := range bf.layers {
+		if l.contains(a, b) {
+			return false, nil
+		}
+	}
+	last := bf.layers[len(bf.layers)-1]
+	if last.items >= last.capacity {
+		if bf.expansion == 0 {
+			return false, errors.New("ERR non scaling filter is full")
+		}
+		error_rate := bf.error_rate * math.Pow(bloomTightening, float64(len(bf.layers)+1))
+		if last.capacity > math.MaxInt64/bf.expansion || !bloomFits(last.capacity*bf.expansion, error_rate) {
+			return false, errBloomTooLarge
+		}
+		last = newBloomLayer(last.capacity*bf.expansion, error_rate)
+		bf.layers = append(bf.layers, last)
+	}
+	last.add(a, b)
+	return true, nil
+}
+
+// Capacity function returns the number of items the filter holds before
+// it has to scale
+func (bf *BloomFilter) Capacity() int64 {
+	result := int64(0)
+	for _, l := range bf.layers {
+		result += l.capacity
+	}
+	return result
+}
+
+// Items function returns the number of items added to the filter
+func (bf *BloomFilter) Items() int64 {
+	result := int64(0)
+	for _, l := range bf.layers {
+		result += l.items
+	}
+	return result
+}
+
+// Size function returns the number of bytes used by the filter
+func (bf *BloomFilter) Size() int64 {
+	result := int64(0)
+	for _, l := range bf.layers {
+		result += int64(len(l.bits) * 8)
+	}
+	return result
+}
+
+// getBloomFilter function returns the Bloom filter stored at key. If the key
+// does not exist nil is returned, unless create is set in which case a new
+// filter with the default parameters is stored at key. ErrWrongType is
+// returned if the key holds another datatype
+func (s *Storage) getBloomFilter(key Key, create bool) (*BloomFilter, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		if !create {
+			return nil, nil
+		}
+		result := NewBloomFilter(bloomDefaultErrorRate, bloomDefaultCapacity, bloomDefaultExpansion)
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	result, ok := val.val.(*BloomFilter)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// BFReserve function stores an empty Bloom filter at key, failing if the
+// key already exists
+func (s *Storage) BFReserve(key Key, error_rate float64, capacity int64, expansion int64) error {
+	if _, prs := s.lookup(key); prs {
+		return errors.New("ERR item exists")
+	}
+	s.data[key] = Value{val: NewBloomFilter(error_rate, capacity, expansion)}
+	s.keyModified(key)
+	return nil
+}
+
+// BFAdd function adds the items to the Bloom filter stored at key,
+// creating it if it does not exist. For each item it returns whether it
+// was added, or the error which prevented it
+func (s *Storage) BFAdd(key Key, items []string) ([]bool, []error, error) {
+	bf, err := s.getBloomFilter(key, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	added := make([]bool, len(items))
+	errs := make([]error, len(items))
+	modified := false
+	for i, item := range items {
+		added[i], errs[i] = bf.Add(item)
+		modified = modified || added[i]
+	}
+	if modified {
+		s.keyModified(key)
+	}
+	return added, errs, nil
+}
+
+// BFExists function returns whether each of the items may have been added
+// to the Bloom filter stored at key
+func (s *Storage) BFExists(key Key, items []string) ([]bool, error) {
+	bf, err := s.getBloomFilter(key, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]bool, len(items))
+	if bf == nil {
+		return result, nil
+	}
+	for i, item := range items {
+		result[i] = bf.Exists(item)
+	}
+	return result, nil
+}
+
+// BFInfo function returns the Bloom filter stored at key, failing if the
+// key does not exist
+func (s *Storage) BFInfo(key Key) (*BloomFilter, error) {
+	bf, err := s.getBloomFilter(key, false)
+	if err != nil {
+		return nil, err
+	}
+	if bf == nil {
+		return nil, errBloomNotFound
+	}
+	return bf, nil
+}
+
+// boolsReply function converts booleans into a reply of integers, 1 for
+// true and 0 for false
+func boolsReply(values []bool) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = 0
+		if v {
+			result[i] = 1
+		}
+	}
+	return result
+}
+
+// ProcessRespCommandBFReserve function processes command BF.RESERVE
+func (s *Server) ProcessRespCommandBFReserve(c *Connection, commands []string) (interface{}, error) {
+	error_rate, err := strconv.ParseFloat(commands[2], 64)
+	if err != nil {
+		return nil, errors.New("ERR bad error rate")
+	}
+	if error_rate <= 0 || error_rate >= 1 {
+		return nil, errors.New("ERR (0 < error rate range < 1)")
+	}
+	capacity, err := strconv.ParseInt(commands[3], 10, 64)
+	if err != nil {
+		return nil, errors.New("ERR bad capacity")
+	}
+	if capacity <= 0 {
+		return nil, errors.New("ERR (capacity should be larger than 0)")
+	}
+	if capacity > bloomMaxCapacity {
+		return nil, errors.New("ERR (capacity should be at most 1073741824)")
+	}
+	expansion, nonscaling, expansion_given := int64(bloomDefaultExpansion), false, false
+	for i := 4; i < len(commands); i++ {
+		switch strings.ToUpper(commands[i]) {
+		case "NONSCALING":
+			nonscaling = true
+		case "EXPANSION":
+			if i+1 >= len(commands) {
+				return nil, ErrSyntax
+			}
+			expansion, err = strconv.ParseInt(commands[i+1], 10, 64)
+			if err != nil || expansion < 1 {
+				return nil, errors.New("ERR expansion should be greater or equal to 1")
+			}
+			if expansion > bloomMaxExpansion {
+				return nil, errors.New("ERR expansion should be at most 32768")
+			}
+			expansion_given = true
+			i++
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	if nonscaling {
+		if expansion_given {
+			return nil, errors.New("ERR Nonscaling filters cannot expand")
+		}
+		expansion = 0
+	}
+	if !bloomFits(capacity, error_rate*bloomTightening) {
+		return nil, errBloomTooLarge
+	}
+	if err := s.db.BFReserve(Key(commands[1]), error_rate, capacity, expansion); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
+
+// ProcessRespCommandBFAdd function processes command BF.ADD
+func (s *Server) ProcessRespCommandBFAdd(c *Connection, commands []string) (interface{}, error) {
+	added, errs, err := s.db.BFAdd(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	if errs[0] != nil {
+		return nil, errs[0]
+	}
+	return boolsReply(added)[0], nil
+}
+
+// ProcessRespCommandBFMAdd function processes command BF.MADD, an item
+// which could not be added is replied with the error preventing it
+func (s *Server) ProcessRespCommandBFMAdd(c *Connection, commands []string) (interface{}, error) {
+	added, errs, err := s.db.BFAdd(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	result := boolsReply(added)
+	for i, err := range errs {
+		if err != nil {
+			result[i] = err
+		}
+	}
+	return result, nil
+}
+
+// ProcessRespCommandBFExists function processes command BF.EXISTS
+func (s *Server) ProcessRespCommandBFExists(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.BFExists(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	return boolsReply(result)[0], nil
+}
+
+// ProcessRespCommandBFMExists function processes command BF.MEXISTS
+func (s *Server) ProcessRespCommandBFMExists(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.BFExists(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	return boolsReply(result), nil
+}
+
+// ProcessRespCommandBFInfo function processes command BF.INFO. Given one
+// of CAPACITY, SIZE, FILTERS, ITEMS or EXPANSION only that field is
+// replied
+func (s *Server) ProcessRespCommandBFInfo(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) > 3 {
+		return nil, ErrSyntax
+	}
+	bf, err := s.db.BFInfo(Key(commands[1]))
+	if err != nil {
+		return nil, err
+	}
+	var expansion interface{} = bf.expansion
+	if bf.expansion == 0 {
+		expansion = nil
+	}
+	fields := []struct {
+		option string
+		name   string
+		value  interface{}
+	}{
+		{"CAPACITY", "Capacity", bf.Capacity()},
+		{"SIZE", "Size", bf.Size()},
+		{"FILTERS", "Number of filters", int64(len(bf.layers))},
+		{"ITEMS", "Number of items inserted", bf.Items()},
+		{"EXPANSION", "Expansion rate", expansion},
+	}
+	if len(commands) == 3 {
+		for _, field := range fields {
+			if strings.ToUpper(commands[2]) == field.option {
+				return []interface{}{field.value}, nil
+			}
+		}
+		return nil, errors.New("ERR Invalid information value")
+	}
+	result := MapReply{}
+	for _, field := range fields {
+		result = append(result, field.name, field.value)
+	}
+	return result, nil
+}
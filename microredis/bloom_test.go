@@ -0,0 +1,138 @@
+package microredis_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestBloomFilter(t *testing.T) {
+	bf := m.NewBloomFilter(0.01, 1000, 2)
+	count := int64(0)
+	for i := 0; i < 1000; i++ {
+		added, err := bf.Add("item:" + strconv.Itoa(i))
+		assert.Nil(t, err)
+		if added {
+			count++
+		}
+	}
+	// an item is only not added on a false positive
+	assert.Greater(t, count, int64(990))
+	added, _ := bf.Add("item:0")
+	assert.False(t, added)
+
+	// no false negatives and about the requested rate of false positives
+	positives := 0
+	for i := 0; i < 10000; i++ {
+		assert.True(t, bf.Exists("item:"+strconv.Itoa(i%1000)))
+		if bf.Exists("other:" + strconv.Itoa(i)) {
+			positives++
+		}
+	}
+	assert.Less(t, positives, 200)
+	assert.Equal(t, count, bf.Items())
+	assert.Equal(t, int64(1000), bf.Capacity())
+
+	// a full filter scales by adding a larger filter
+	for i := 1000; i < 1500; i++ {
+		bf.Add("item:" + strconv.Itoa(i))
+	}
+	assert.Equal(t, int64(3000), bf.Capacity())
+	for i := 0; i < 1500; i++ {
+		assert.True(t, bf.Exists("item:"+strconv.Itoa(i)))
+	}
+
+	bf = m.NewBloomFilter(0.01, 2, 0)
+	bf.Add("a")
+	bf.Add("b")
+	_, err := bf.Add("c")
+	assert.Equal(t, "ERR non scaling filter is full", err.Error())
+
+	// a filter which would scale past the maximum size is full
+	bf = m.NewBloomFilter(0.01, 2, math.MaxInt64/2+1)
+	bf.Add("a")
+	bf.Add("b")
+	_, err = bf.Add("c")
+	assert.Equal(t, "ERR filter would exceed the maximum size", err.Error())
+	assert.Equal(t, int64(2), bf.Items())
+}
+
+func TestBloomFilterStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	added, errs, err := s.BFAdd(m.Key("bf"), []string{"a", "b", "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, false}, added)
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+	assert.Equal(t, "MBbloom--", s.Type(m.Key("bf")))
+
+	exists, err := s.BFExists(m.Key("bf"), []string{"a", "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false}, exists)
+	exists, _ = s.BFExists(m.Key("missing"), []string{"a"})
+	assert.Equal(t, []bool{false}, exists)
+
+	assert.Equal(t, "ERR item exists", s.BFReserve(m.Key("bf"), 0.01, 10, 2).Error())
+	assert.Nil(t, s.BFReserve(m.Key("small"), 0.01, 1, 0))
+	_, errs, _ = s.BFAdd(m.Key("small"), []string{"a", "b"})
+	assert.Nil(t, errs[0])
+	assert.Equal(t, "ERR non scaling filter is full", errs[1].Error())
+
+	_, err = s.BFInfo(m.Key("missing"))
+	assert.Equal(t, "ERR not found", err.Error())
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, _, err = s.BFAdd(m.Key("str"), []string{"a"})
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestBloomFilterCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"add and exists", []step{
+			{args: []string{"BF.ADD", "bf", "a"}, reply: ":1\r\n"},
+			{args: []string{"BF.ADD", "bf", "a"}, reply: ":0\r\n"},
+			{args: []string{"BF.MADD", "bf", "a", "b", "c"}, reply: "*3\r\n:0\r\n:1\r\n:1\r\n"},
+			{args: []string{"BF.EXISTS", "bf", "b"}, reply: ":1\r\n"},
+			{args: []string{"BF.EXISTS", "bf", "d"}, reply: ":0\r\n"},
+			{args: []string{"BF.EXISTS", "missing", "d"}, reply: ":0\r\n"},
+			{args: []string{"BF.MEXISTS", "bf", "a", "d", "c"}, reply: "*3\r\n:1\r\n:0\r\n:1\r\n"},
+			{args: []string{"TYPE", "bf"}, reply: "+MBbloom--\r\n"},
+			{args: []string{"BF.INFO", "bf"}, reply: "*10\r\n$8\r\nCapacity\r\n:100\r\n$4\r\nSize\r\n:144\r\n" +
+				"$17\r\nNumber of filters\r\n:1\r\n$24\r\nNumber of items inserted\r\n:3\r\n$14\r\nExpansion rate\r\n:2\r\n"},
+			{args: []string{"BF.INFO", "bf", "items"}, reply: "*1\r\n:3\r\n"},
+			{args: []string{"HELLO", "3"}, pattern: `^%7`},
+			{args: []string{"BF.INFO", "bf"}, pattern: `^%5\r\n\$8\r\nCapacity\r\n:100\r\n`},
+		}},
+		{"reserve", []step{
+			{args: []string{"BF.RESERVE", "bf", "0.001", "2", "NONSCALING"}, reply: "+OK\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "0.001", "2"}, reply: "-ERR item exists\r\n"},
+			{args: []string{"BF.MADD", "bf", "a", "b", "c"}, reply: "*3\r\n:1\r\n:1\r\n-ERR non scaling filter is full\r\n"},
+			{args: []string{"BF.ADD", "bf", "c"}, reply: "-ERR non scaling filter is full\r\n"},
+			{args: []string{"BF.INFO", "bf", "EXPANSION"}, reply: "*1\r\n$-1\r\n"},
+			{args: []string{"BF.RESERVE", "scaling", "0.01", "2", "EXPANSION", "4"}, reply: "+OK\r\n"},
+			{args: []string{"BF.MADD", "scaling", "a", "b", "c"}, reply: "*3\r\n:1\r\n:1\r\n:1\r\n"},
+			{args: []string{"BF.INFO", "scaling", "CAPACITY"}, reply: "*1\r\n:10\r\n"},
+			{args: []string{"BF.INFO", "scaling", "FILTERS"}, reply: "*1\r\n:2\r\n"},
+		}},
+		{"errors", []step{
+			{args: []string{"BF.RESERVE", "bf", "x", "10"}, reply: "-ERR bad error rate\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "1", "10"}, reply: "-ERR (0 < error rate range < 1)\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "0.1", "0"}, reply: "-ERR (capacity should be larger than 0)\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "0.1", "9223372036854775807"}, reply: "-ERR (capacity should be at most 1073741824)\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "1e-300", "1073741824"}, reply: "-ERR filter would exceed the maximum size\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "0.1", "10", "EXPANSION", "0"}, reply: "-ERR expansion should be greater or equal to 1\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "0.1", "10", "EXPANSION", "32769"}, reply: "-ERR expansion should be at most 32768\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "0.1", "10", "EXPANSION", "2", "NONSCALING"}, reply: "-ERR Nonscaling filters cannot expand\r\n"},
+			{args: []string{"BF.RESERVE", "bf", "0.1", "10", "FOO"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"BF.INFO", "missing"}, reply: "-ERR not found\r\n"},
+			{args: []string{"BF.ADD", "bf", "a"}, reply: ":1\r\n"},
+			{args: []string{"BF.INFO", "bf", "FOO"}, reply: "-ERR Invalid information value\r\n"},
+			{args: []string{"GET", "bf"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"SET", "str", "v"}, reply: "+OK\r\n"},
+			{args: []string{"BF.ADD", "str", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"BF.EXISTS", "str", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}
@@ -41,29 +41,32 @@ func (c *Client) Run() {
 	defer conn.Close()
 
 	scanner := bufio.NewScanner(os.Stdin)
-	conn_scanner := bufio.NewScanner(conn)
+	conn_reader := bufio.NewReader(conn)
 	fmt.Print(fmt.Sprintf("redis://%s:%s> ", c.address, c.port))
 	for scanner.Scan() {
 		msg := scanner.Text()
 		msg = strings.Trim(msg, " ")
-		if msg == "quit" {
-			_, err := conn.Write([]byte("quit" + "\n"))
-			if err != nil {
-				fmt.Println("Error writing to server:", err)
-			}
-			return
+		if msg == "" {
+			fmt.Print(fmt.Sprintf("redis://%s:%s> ", c.address, c.port))
+			continue
+		}
+		args, err := splitArgs(msg)
+		if err != nil {
+			fmt.Println("Invalid argument(s)")
+			fmt.Print(fmt.Sprintf("redis://%s:%s> ", c.address, c.port))
+			continue
 		}
-		marshalled_msg := MarshalResp(strings.Split(msg, " "))
-		_, err := conn.Write([]byte(marshalled_msg + "\n"))
+		marshalled_msg := MarshalResp(args)
+		_, err = conn.Write([]byte(marshalled_msg))
 		if err != nil {
 			fmt.Println("Error writing to server:", err)
 			continue
 		}
 		// read from server
-		line := ""
-		for conn_scanner.Scan() {
-			line = conn_scanner.Text()
-			break
+		line, err := ReadResp(conn_reader)
+		if err != nil {
+			fmt.Println("Error reading from server:", err)
+			return
 		}
 
 		response, err := UnmarshalResp(line)
@@ -72,6 +75,9 @@ func (c *Client) Run() {
 		} else {
 			fmt.Println(response)
 		}
+		if strings.ToUpper(msg) == "QUIT" {
+			return
+		}
 		fmt.Print(fmt.Sprintf("redis://%s:%s> ", c.address, c.port))
 	}
 	if err := scanner.Err(); err != nil {
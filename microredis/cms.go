@@ -0,0 +1,335 @@
+package microredis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// errCMSNotFound is returned by the sketch commands when the key does not
+// exist, a sketch has to be created explicitly with its dimensions
+var errCMSNotFound = errors.New("ERR CMS: key does not exist")
+
+// cmsMaxCounters bounds the dimensions of a sketch so that it can't take
+// unbounded memory, the counters take 8 bytes each and are bounded to
+// maxStringLen bytes
+const cmsMaxCounters = maxStringLen / 8
+
+// errCMSTooLarge is returned when a sketch would exceed cmsMaxCounters
+var errCMSTooLarge = errors.New("ERR CMS: dimensions are too large")
+
+// errCMSIncrOverflow and errCMSMergeOverflow are returned when a counter or
+// the total count of a sketch would overflow, the sketch is then unchanged
+var (
+	errCMSIncrOverflow  = errors.New("ERR CMS: INCRBY overflow")
+	errCMSMergeOverflow = errors.New("ERR CMS: MERGE overflow")
+)
+
+// CountMinSketch struct denotes the Count-Min Sketch datatype which
+// estimates how many times items were counted using a fixed amount of
+// memory. Each item is counted in one counter of each of the depth rows of
+// width counters, and its count estimated as the lowest of them, which may
+// overestimate but never underestimate it
+type CountMinSketch struct {
+	width    int64
+	depth    int64
+	counters []int64
+	count    int64
+}
+
+// NewCountMinSketch function creates a sketch of the given dimensions with
+// every counter at zero
+func NewCountMinSketch(width int64, depth int64) *CountMinSketch {
+	return &CountMinSketch{width: width, depth: depth, counters: make([]int64, width*depth)}
+}
+
+// cmsDimsByProb function returns the width and depth of a sketch whose
+// estimates exceed the counts by at most error times the total count, with
+// the probability prob of the estimate being higher. They are returned as
+// floats so that they can be checked against cmsMaxCounters before being
+// converted
+func cmsDimsByProb(error_rate float64, prob float64) (float64, float64) {
+	return math.Ceil(2 / error_rate), math.Ceil(math.Log10(prob) / math.Log10(0.5))
+}
+
+// NewCountMinSketchByProb function creates a sketch whose estimates exceed
+// the counts by at most error times the total count, with the probability
+// prob of the estimate being higher
+func NewCountMinSketchByProb(error_rate float64, prob float64) *CountMinSketch {
+	width, depth := cmsDimsByProb(error_rate, prob)
+	return NewCountMinSketch(int64(width), int64(depth))
+}
+
+// counter function returns the index of the counter of item in row
+func (cms *CountMinSketch) counter(item string, row int64) int64 {
+	return row*cms.width + int64(murmurHash64A([]byte(item), uint64(row))%uint64(cms.width))
+}
+
+// Query function returns the estimated count of item
+func (cms *CountMinSketch) Query(item string) int64 {
+	result := int64(math.MaxInt64)
+	for row := int64(0); row < cms.depth; row++ {
+		if cur := cms.counters[cms.counter(item, row)]; cur < result {
+			result = cur
+		}
+	}
+	return result
+}
+
+// IncrBy function increments the count of item by incr, which can't be
+// negative, and returns its new estimated count. errCMSIncrOverflow is
+// returned if a counter would overflow
+func (cms *CountMinSketch) IncrBy(item string, incr int64) (int64, error) {
+	if cms.count > math.MaxInt64-incr {
+		return 0, errCMSIncrOverflow
+	}
+	for row := int64(0); row < cms.depth; row++ {
+		if cms.counters[cms.counter(item, row)] > math.MaxInt64-incr {
+			return 0, errCMSIncrOverflow
+		}
+	}
+	for row := int64(0); row < cms.depth; row++ {
+		cms.counters[cms.counter(item, row)] += incr
+	}
+	cms.count += incr
+	return cms.Query(item), nil
+}
+
+// cmsAddWeighted function returns sum + val*weight, ok is false if it
+// overflows
+func cmsAddWeighted(sum int64, val int64, weight int64) (result int64, ok bool) {
+	if val == 0 || weight == 0 {
+		return sum, true
+	}
+	product := val * weight
+	if product/weight != val || (val == math.MinInt64 && weight == -1) {
+		return 0, false
+	}
+	if (product > 0 && sum > math.MaxInt64-product) || (product < 0 && sum < math.MinInt64-product) {
+		return 0, false
+	}
+	return sum + product, true
+}
+
+// getCountMinSketch function returns the sketch stored at key, nil if the
+// key does not exist. ErrWrongType is returned if the key holds another
+// datatype
+func (s *Storage) getCountMinSketch(key Key) (*CountMinSketch, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		return nil, nil
+	}
+	result, ok := val.val.(*CountMinSketch)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// CMSInit function stores the sketch cms at key, failing if the key already
+// exists
+func (s *Storage) CMSInit(key Key, cms *CountMinSketch) error {
+	if _, prs := s.lookup(key); prs {
+		return errors.New("ERR CMS: key already exists")
+	}
+	s.data[key] = Value{val: cms}
+	s.keyModified(key)
+	return nil
+}
+
+// CMSIncrBy function increments the counts of the items of the sketch
+// stored at key by the matching increments and returns their new estimated
+// counts. On overflow the items before the one overflowing stay counted
+func (s *Storage) CMSIncrBy(key Key, items []string, incrs []int64) ([]int64, error) {
+	cms, err := s.getCountMinSketch(key)
+	if err != nil {
+		return nil, err
+	}
+	if cms == nil {
+		return nil, errCMSNotFound
+	}
+	result := make([]int64, len(items))
+	for i, item := range items {
+		if result[i], err = cms.IncrBy(item, incrs[i]); err != nil {
+			if i > 0 {
+				s.keyModified(key)
+			}
+			return nil, err
+		}
+	}
+	s.keyModified(key)
+	return result, nil
+}
+
+// CMSQuery function returns the estimated counts of the items of the
+// sketch stored at key
+func (s *Storage) CMSQuery(key Key, items []string) ([]int64, error) {
+	cms, err := s.getCountMinSketch(key)
+	if err != nil {
+		return nil, err
+	}
+	if cms == nil {
+		return nil, errCMSNotFound
+	}
+	result := make([]int64, len(items))
+	for i, item := range items {
+		result[i] = cms.Query(item)
+	}
+	return result, nil
+}
+
+// CMSMerge function replaces the counters of the sketch stored at dest by
+// the sum of the counters of the sketches stored at keys, each multiplied
+// by the matching weight. Every sketch must have the same dimensions and
+// errCMSMergeOverflow is returned if a counter would overflow
+func (s *Storage) CMSMerge(dest Key, keys []Key, weights []int64) error {
+	target, err := s.getCountMinSketch(dest)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return errCMSNotFound
+	}
+	sources := make([]*CountMinSketch, len(keys))
+	for i, key := range keys {
+		cms, err := s.getCountMinSketch(key)
+		if err != nil {
+			return err
+		}
+		if cms == nil {
+			return errCMSNotFound
+		}
+		if cms.width != target.width || cms.depth != target.depth {
+			return errors.New("ERR CMS: width/depth is not equal")
+		}
+		sources[i] = cms
+	}
+	counters := make([]int64, len(target.counters))
+	count := int64(0)
+	var ok bool
+	for i, cms := range sources {
+		for j, cur := range cms.counters {
+			if counters[j], ok = cmsAddWeighted(counters[j], cur, weights[i]); !ok {
+				return errCMSMergeOverflow
+			}
+		}
+		if count, ok = cmsAddWeighted(count, cms.count, weights[i]); !ok {
+			return errCMSMergeOverflow
+		}
+	}
+	target.counters, target.count = counters, count
+	s.keyModified(dest)
+	return nil
+}
+
+// countsReply function converts counts into a reply of integers
+func countsReply(counts []int64) []interface{} {
+	result := make([]interface{}, len(counts))
+	for i, count := range counts {
+		result[i] = count
+	}
+	return result
+}
+
+// ProcessRespCommandCMSInitByDim function processes command CMS.INITBYDIM
+func (s *Server) ProcessRespCommandCMSInitByDim(c *Connection, commands []string) (interface{}, error) {
+	width, err := strconv.ParseInt(commands[2], 10, 64)
+	if err != nil || width < 1 {
+		return nil, errors.New("ERR CMS: invalid width")
+	}
+	depth, err := strconv.ParseInt(commands[3], 10, 64)
+	if err != nil || depth < 1 {
+		return nil, errors.New("ERR CMS: invalid depth")
+	}
+	if width > cmsMaxCounters/depth {
+		return nil, errCMSTooLarge
+	}
+	if err := s.db.CMSInit(Key(commands[1]), NewCountMinSketch(width, depth)); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
+
+// ProcessRespCommandCMSInitByProb function processes command
+// CMS.INITBYPROB
+func (s *Server) ProcessRespCommandCMSInitByProb(c *Connection, commands []string) (interface{}, error) {
+	error_rate, err := strconv.ParseFloat(commands[2], 64)
+	if err != nil || error_rate <= 0 || error_rate >= 1 {
+		return nil, errors.New("ERR CMS: invalid overestimation value")
+	}
+	prob, err := strconv.ParseFloat(commands[3], 64)
+	if err != nil || prob <= 0 || prob >= 1 {
+		return nil, errors.New("ERR CMS: invalid prob value")
+	}
+	if width, depth := cmsDimsByProb(error_rate, prob); width*depth > cmsMaxCounters {
+		return nil, errCMSTooLarge
+	}
+	if err := s.db.CMSInit(Key(commands[1]), NewCountMinSketchByProb(error_rate, prob)); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
+
+// ProcessRespCommandCMSIncrBy function processes command CMS.INCRBY
+func (s *Server) ProcessRespCommandCMSIncrBy(c *Connection, commands []string) (interface{}, error) {
+	pairs := commands[2:]
+	if len(pairs)%2 != 0 {
+		return nil, errors.New(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(commands[0])))
+	}
+	items := make([]string, len(pairs)/2)
+	incrs := make([]int64, len(items))
+	for i := range items {
+		incr, err := strconv.ParseInt(pairs[2*i+1], 10, 64)
+		if err != nil || incr < 0 {
+			return nil, errors.New("ERR CMS: Cannot parse number")
+		}
+		items[i], incrs[i] = pairs[2*i], incr
+	}
+	result, err := s.db.CMSIncrBy(Key(commands[1]), items, incrs)
+	if err != nil {
+		return nil, err
+	}
+	return countsReply(result), nil
+}
+
+// ProcessRespCommandCMSQuery function processes command CMS.QUERY
+func (s *Server) ProcessRespCommandCMSQuery(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.CMSQuery(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	return countsReply(result), nil
+}
+
+// ProcessRespCommandCMSMerge function processes command CMS.MERGE, every
+// source has a weight of 1 unless WEIGHTS are given
+func (s *Server) ProcessRespCommandCMSMerge(c *Connection, commands []string) (interface{}, error) {
+	numkeys, err := strconv.ParseInt(commands[2], 10, 64)
+	if err != nil || numkeys < 1 || numkeys > int64(len(commands)-3) {
+		return nil, errors.New("ERR CMS: invalid numkeys")
+	}
+	keys := toKeys(commands[3 : 3+numkeys])
+	weights := make([]int64, numkeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	rest := commands[3+numkeys:]
+	if len(rest) > 0 {
+		if strings.ToUpper(rest[0]) != "WEIGHTS" || len(rest) != len(keys)+1 {
+			return nil, ErrSyntax
+		}
+		for i, arg := range rest[1:] {
+			weight, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil, errors.New("ERR CMS: invalid weight value")
+			}
+			weights[i] = weight
+		}
+	}
+	if err := s.db.CMSMerge(Key(commands[1]), keys, weights); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
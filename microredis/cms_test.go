@@ -0,0 +1,127 @@
+package microredis_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestCountMinSketch(t *testing.T) {
+	cms := m.NewCountMinSketchByProb(0.001, 0.01)
+	counts := map[string]int64{}
+	total := int64(0)
+	for i := 0; i < 20000; i++ {
+		item := strconv.Itoa(i % 500)
+		counts[item]++
+		total++
+		cms.IncrBy(item, 1)
+	}
+
+	// estimates never undercount and overcount by at most error * total
+	for item, count := range counts {
+		estimate := cms.Query(item)
+		assert.GreaterOrEqual(t, estimate, count)
+		assert.LessOrEqual(t, estimate, count+total/1000)
+	}
+	assert.Equal(t, int64(0), m.NewCountMinSketch(10, 2).Query("a"))
+	count, err := m.NewCountMinSketch(10, 2).IncrBy("a", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), count)
+
+	// a counter overflowing leaves the sketch unchanged
+	cms = m.NewCountMinSketch(10, 2)
+	cms.IncrBy("a", math.MaxInt64-1)
+	_, err = cms.IncrBy("b", 2)
+	assert.Equal(t, "ERR CMS: INCRBY overflow", err.Error())
+	assert.Equal(t, int64(0), cms.Query("b"))
+	assert.Equal(t, int64(math.MaxInt64-1), cms.Query("a"))
+}
+
+func TestCountMinSketchStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	_, err := s.CMSIncrBy(m.Key("cms"), []string{"a"}, []int64{1})
+	assert.Equal(t, "ERR CMS: key does not exist", err.Error())
+	assert.Nil(t, s.CMSInit(m.Key("cms"), m.NewCountMinSketch(1000, 5)))
+	assert.Equal(t, "ERR CMS: key already exists", s.CMSInit(m.Key("cms"), m.NewCountMinSketch(10, 5)).Error())
+	assert.Equal(t, "CMSk-TYPE", s.Type(m.Key("cms")))
+
+	counts, err := s.CMSIncrBy(m.Key("cms"), []string{"a", "b", "a"}, []int64{2, 3, 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{2, 3, 3}, counts)
+	counts, err = s.CMSQuery(m.Key("cms"), []string{"a", "b", "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{3, 3, 0}, counts)
+
+	s.CMSInit(m.Key("other"), m.NewCountMinSketch(1000, 5))
+	s.CMSIncrBy(m.Key("other"), []string{"a", "c"}, []int64{1, 4})
+	s.CMSInit(m.Key("dst"), m.NewCountMinSketch(1000, 5))
+	assert.Nil(t, s.CMSMerge(m.Key("dst"), []m.Key{"cms", "other"}, []int64{1, 2}))
+	counts, _ = s.CMSQuery(m.Key("dst"), []string{"a", "b", "c"})
+	assert.Equal(t, []int64{5, 3, 8}, counts)
+
+	s.CMSInit(m.Key("big"), m.NewCountMinSketch(1000, 5))
+	s.CMSIncrBy(m.Key("big"), []string{"a"}, []int64{math.MaxInt64 / 2})
+	assert.Equal(t, "ERR CMS: MERGE overflow", s.CMSMerge(m.Key("dst"), []m.Key{"big"}, []int64{3}).Error())
+	assert.Equal(t, "ERR CMS: MERGE overflow", s.CMSMerge(m.Key("dst"), []m.Key{"big", "big", "cms"}, []int64{1, 1, 1}).Error())
+	counts, _ = s.CMSQuery(m.Key("dst"), []string{"a", "b", "c"})
+	assert.Equal(t, []int64{5, 3, 8}, counts)
+
+	s.CMSInit(m.Key("small"), m.NewCountMinSketch(10, 5))
+	assert.Equal(t, "ERR CMS: width/depth is not equal", s.CMSMerge(m.Key("dst"), []m.Key{"small"}, []int64{1}).Error())
+	assert.Equal(t, "ERR CMS: key does not exist", s.CMSMerge(m.Key("dst"), []m.Key{"missing"}, []int64{1}).Error())
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, err = s.CMSQuery(m.Key("str"), []string{"a"})
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestCountMinSketchCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"count", []step{
+			{args: []string{"CMS.INITBYDIM", "cms", "2000", "5"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.INCRBY", "cms", "a", "5", "b", "2"}, reply: "*2\r\n:5\r\n:2\r\n"},
+			{args: []string{"CMS.INCRBY", "cms", "a", "1"}, reply: "*1\r\n:6\r\n"},
+			{args: []string{"CMS.QUERY", "cms", "a", "b", "c"}, reply: "*3\r\n:6\r\n:2\r\n:0\r\n"},
+			{args: []string{"TYPE", "cms"}, reply: "+CMSk-TYPE\r\n"},
+			{args: []string{"CMS.INITBYPROB", "prob", "0.001", "0.01"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.INCRBY", "prob", "a", "1"}, reply: "*1\r\n:1\r\n"},
+		}},
+		{"merge", []step{
+			{args: []string{"CMS.INITBYDIM", "a", "100", "4"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.INITBYDIM", "b", "100", "4"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.INITBYDIM", "dst", "100", "4"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.INCRBY", "a", "x", "1"}, reply: "*1\r\n:1\r\n"},
+			{args: []string{"CMS.INCRBY", "b", "x", "2"}, reply: "*1\r\n:2\r\n"},
+			{args: []string{"CMS.MERGE", "dst", "2", "a", "b"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.QUERY", "dst", "x"}, reply: "*1\r\n:3\r\n"},
+			{args: []string{"CMS.MERGE", "dst", "2", "a", "b", "WEIGHTS", "3", "1"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.QUERY", "dst", "x"}, reply: "*1\r\n:5\r\n"},
+			{args: []string{"CMS.MERGE", "dst", "2", "a", "b", "WEIGHTS", "3"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"CMS.MERGE", "dst", "3", "a", "b"}, reply: "-ERR CMS: invalid numkeys\r\n"},
+			{args: []string{"CMS.MERGE", "missing", "1", "a"}, reply: "-ERR CMS: key does not exist\r\n"},
+		}},
+		{"errors", []step{
+			{args: []string{"CMS.INITBYDIM", "cms", "0", "5"}, reply: "-ERR CMS: invalid width\r\n"},
+			{args: []string{"CMS.INITBYDIM", "cms", "10", "x"}, reply: "-ERR CMS: invalid depth\r\n"},
+			{args: []string{"CMS.INITBYPROB", "cms", "1", "0.1"}, reply: "-ERR CMS: invalid overestimation value\r\n"},
+			{args: []string{"CMS.INITBYPROB", "cms", "0.1", "0"}, reply: "-ERR CMS: invalid prob value\r\n"},
+			{args: []string{"CMS.INITBYDIM", "cms", "2000000000", "1000"}, reply: "-ERR CMS: dimensions are too large\r\n"},
+			{args: []string{"CMS.INITBYDIM", "cms", "9223372036854775807", "2"}, reply: "-ERR CMS: dimensions are too large\r\n"},
+			{args: []string{"CMS.INITBYPROB", "cms", "1e-300", "0.5"}, reply: "-ERR CMS: dimensions are too large\r\n"},
+			{args: []string{"CMS.INITBYPROB", "cms", "5e-324", "0.5"}, reply: "-ERR CMS: dimensions are too large\r\n"},
+			{args: []string{"CMS.QUERY", "cms", "a"}, reply: "-ERR CMS: key does not exist\r\n"},
+			{args: []string{"CMS.INITBYDIM", "cms", "10", "2"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.INITBYDIM", "cms", "10", "2"}, reply: "-ERR CMS: key already exists\r\n"},
+			{args: []string{"CMS.INCRBY", "cms", "a", "-1"}, reply: "-ERR CMS: Cannot parse number\r\n"},
+			{args: []string{"CMS.INCRBY", "cms", "a", "1", "b"}, reply: "-ERR wrong number of arguments for 'cms.incrby' command\r\n"},
+			{args: []string{"CMS.INCRBY", "cms", "a", "9223372036854775807"}, reply: "*1\r\n:9223372036854775807\r\n"},
+			{args: []string{"CMS.INCRBY", "cms", "b", "1"}, reply: "-ERR CMS: INCRBY overflow\r\n"},
+			{args: []string{"CMS.INITBYDIM", "dst", "10", "2"}, reply: "+OK\r\n"},
+			{args: []string{"CMS.MERGE", "dst", "1", "cms", "WEIGHTS", "-2"}, reply: "-ERR CMS: MERGE overflow\r\n"},
+			{args: []string{"GET", "cms"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}
@@ -0,0 +1,1009 @@
+package microredis
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Errors shared by commands, ErrSyntax is returned when the options of a
+// command can't be parsed, ErrNotInteger and ErrNotFloat when an argument
+// or a stored value is expected to be a number but it isn't one
+var (
+	ErrSyntax     = errors.New("ERR syntax error")
+	ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+	ErrNotFloat   = errors.New("ERR value is not a valid float")
+)
+
+// CommandFlag type denotes a property of a command, flags are bits so a
+// command can have multiple of them
+type CommandFlag int
+
+const (
+	// FlagWrite denotes a command which may modify the storage
+	FlagWrite CommandFlag = 1 << iota
+	// FlagReadonly denotes a command which only reads from the storage
+	FlagReadonly
+	// FlagFast denotes a command which runs in O(1) or O(log(N)) time
+	FlagFast
+	// FlagAdmin denotes an administrative command
+	FlagAdmin
+	// FlagPubsub denotes a command related to publish/subscribe
+	FlagPubsub
+	// FlagBlocking denotes a command which may block the client
+	FlagBlocking
+)
+
+// commandFlagNames maps each flag to the name redis uses for it
+var commandFlagNames = []struct {
+	flag CommandFlag
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadonly, "readonly"},
+	{FlagFast, "fast"},
+	{FlagAdmin, "admin"},
+	{FlagPubsub, "pubsub"},
+	{FlagBlocking, "blocking"},
+}
+
+// Names function returns the names of all the flags which are set
+func (f CommandFlag) Names() []string {
+	result := []string{}
+	for _, fn := range commandFlagNames {
+		if f&fn.flag != 0 {
+			result = append(result, fn.name)
+		}
+	}
+	return result
+}
+
+// CommandHandler type denotes the function which executes a command, it
+// receives the connection the command was sent on and the command along
+// with its arguments
+type CommandHandler func(s *Server, c *Connection, commands []string) (interface{}, error)
+
+// Command struct declares a command supported by the server
+// Arity is the number of arguments including the command name itself, a
+// negative arity -N means at least N arguments.
+// FirstKey, LastKey and Step are the positions of the key arguments, the
+// same way redis declares them: FirstKey is the index of the first key,
+// LastKey the index of the last key or negative to count from the end and
+// Step the distance between two keys. A command without key arguments has
+// all three set to 0
+// MovableKeys is set for commands whose keys can't be declared through
+// positions, such as commands taking the number of keys as an argument
+// It returns the key arguments of the command and FirstKey, LastKey and
+// Step are left as 0
+// Group, Summary, Since and Complexity document the command and are
+// reported through COMMAND DOCS. Since is left empty for the commands
+// which are not part of redis
+type Command struct {
+	Name        string
+	Arity       int
+	Flags       CommandFlag
+	FirstKey    int
+	LastKey     int
+	Step        int
+	MovableKeys func(commands []string) []Key
+	Group       string
+	Summary     string
+	Since       string
+	Complexity  string
+	Handler     CommandHandler
+}
+
+// commandTable holds every command supported by the server keyed by its
+// lower case name
+var commandTable = map[string]*Command{}
+
+// registerCommands function adds commands to the command table
+func registerCommands(commands ...*Command) {
+	for _, cmd := range commands {
+		commandTable[cmd.Name] = cmd
+	}
+}
+
+func init() {
+	registerCommands(
+		&Command{
+			Name: "get", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the string value of a key.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandGet,
+		},
+		&Command{
+			Name: "set", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandSet,
+		},
+		&Command{
+			Name: "getdel", Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the string value of a key after deleting the key.", Since: "6.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandGetDel,
+		},
+		&Command{
+			Name: "getex", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the string value of a key after setting its expiration time.", Since: "6.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandGetEx,
+		},
+		&Command{
+			Name: "mget", Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "string", Summary: "Atomically returns the string values of one or more keys.", Since: "1.0.0", Complexity: "O(N) where N is the number of keys to retrieve.",
+			Handler: (*Server).ProcessRespCommandMGet,
+		},
+		&Command{
+			Name: "mset", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 2,
+			Group: "string", Summary: "Atomically creates or modifies the string values of one or more keys.", Since: "1.0.1", Complexity: "O(N) where N is the number of keys to set.",
+			Handler: (*Server).ProcessRespCommandMSet,
+		},
+		&Command{
+			Name: "msetnx", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 2,
+			Group: "string", Summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.", Since: "1.0.1", Complexity: "O(N) where N is the number of keys to set.",
+			Handler: (*Server).ProcessRespCommandMSetNX,
+		},
+		&Command{
+			Name: "incr", Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandIncr,
+		},
+		&Command{
+			Name: "decr", Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandDecr,
+		},
+		&Command{
+			Name: "incrby", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandIncrBy,
+		},
+		&Command{
+			Name: "decrby", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandDecrBy,
+		},
+		&Command{
+			Name: "incrbyfloat", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.", Since: "2.6.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandIncrByFloat,
+		},
+		&Command{
+			Name: "append", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.", Since: "2.0.0", Complexity: "O(1). The amortized time complexity is O(1) assuming the appended value is small and the already present value is of any size, since the dynamic string library used by Redis will double the free space available on every reallocation.",
+			Handler: (*Server).ProcessRespCommandAppend,
+		},
+		&Command{
+			Name: "strlen", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the length of a string value.", Since: "2.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandStrLen,
+		},
+		&Command{
+			Name: "getrange", Arity: 4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns a substring of the string stored at a key.", Since: "2.4.0", Complexity: "O(N) where N is the length of the returned string. The complexity is ultimately determined by the returned length, but because creating a substring from an existing string is very cheap, it can be considered O(1) for small strings.",
+			Handler: (*Server).ProcessRespCommandGetRange,
+		},
+		&Command{
+			Name: "setrange", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.", Since: "2.2.0", Complexity: "O(1), not counting the time taken to copy the new string in place. Usually, this string is very small so the amortized complexity is O(1). Otherwise, complexity is O(M) with M being the length of the value argument.",
+			Handler: (*Server).ProcessRespCommandSetRange,
+		},
+		&Command{
+			Name: "lcs", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "string", Summary: "Finds the longest common substring.", Since: "7.0.0", Complexity: "O(N*M) where N and M are the lengths of s1 and s2, respectively",
+			Handler: (*Server).ProcessRespCommandLCS,
+		},
+		&Command{
+			Name: "getver", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the string value of a key along with its version.", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandGetVer,
+		},
+		&Command{
+			Name: "delifver", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Deletes a key only if its version matches the given one.", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandDelIfVer,
+		},
+		&Command{
+			Name: "del", Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "generic", Summary: "Deletes one or more keys.", Since: "1.0.0", Complexity: "O(N) where N is the number of keys that will be removed.",
+			Handler: (*Server).ProcessRespCommandDel,
+		},
+		&Command{
+			Name: "expire", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Sets the expiration time of a key in seconds.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandExpire,
+		},
+		&Command{
+			Name: "pexpire", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Sets the expiration time of a key in milliseconds.", Since: "2.6.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandPExpire,
+		},
+		&Command{
+			Name: "expireat", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Sets the expiration time of a key to a Unix timestamp.", Since: "1.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandExpireAt,
+		},
+		&Command{
+			Name: "pexpireat", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", Since: "2.6.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandPExpireAt,
+		},
+		&Command{
+			Name: "persist", Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Removes the expiration time of a key.", Since: "2.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandPersist,
+		},
+		&Command{
+			Name: "ttl", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Returns the expiration time in seconds of a key.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandTTL,
+		},
+		&Command{
+			Name: "pttl", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Returns the expiration time in milliseconds of a key.", Since: "2.6.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandPTTL,
+		},
+		&Command{
+			Name: "expiretime", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Returns the expiration time of a key as a Unix timestamp.", Since: "7.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandExpireTime,
+		},
+		&Command{
+			Name: "pexpiretime", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.", Since: "7.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandPExpireTime,
+		},
+		&Command{
+			Name: "keys", Arity: 2, Flags: FlagReadonly,
+			Group: "generic", Summary: "Returns all key names that match a pattern.", Since: "1.0.0", Complexity: "O(N) with N being the number of keys in the database.",
+			Handler: (*Server).ProcessRespCommandKeys,
+		},
+		&Command{
+			Name: "type", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Determines the type of value stored at a key.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandType,
+		},
+		&Command{
+			Name: "lpush", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0", Complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			Handler: (*Server).ProcessRespCommandLPush,
+		},
+		&Command{
+			Name: "rpush", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0", Complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			Handler: (*Server).ProcessRespCommandRPush,
+		},
+		&Command{
+			Name: "lpop", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", Since: "1.0.0", Complexity: "O(N) where N is the number of elements returned",
+			Handler: (*Server).ProcessRespCommandLPop,
+		},
+		&Command{
+			Name: "rpop", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped.", Since: "1.0.0", Complexity: "O(N) where N is the number of elements returned",
+			Handler: (*Server).ProcessRespCommandRPop,
+		},
+		&Command{
+			Name: "lrange", Arity: 4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns a range of elements from a list.", Since: "1.0.0", Complexity: "O(S+N) where S is the distance of start offset from HEAD for small lists, from nearest end (HEAD or TAIL) for large lists; and N is the number of elements in the specified range.",
+			Handler: (*Server).ProcessRespCommandLRange,
+		},
+		&Command{
+			Name: "llen", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns the length of a list.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandLLen,
+		},
+		&Command{
+			Name: "lindex", Arity: 3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns an element from a list by its index.", Since: "1.0.0", Complexity: "O(N) where N is the number of elements to traverse to get to the element at index. This makes asking for the first or the last element of the list O(1).",
+			Handler: (*Server).ProcessRespCommandLIndex,
+		},
+		&Command{
+			Name: "lset", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Sets the value of an element in a list by its index.", Since: "1.0.0", Complexity: "O(N) where N is the length of the list. Setting either the first or the last element of the list is O(1).",
+			Handler: (*Server).ProcessRespCommandLSet,
+		},
+		&Command{
+			Name: "lrem", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Removes elements from a list. Deletes the list if the last element was removed.", Since: "1.0.0", Complexity: "O(N+M) where N is the length of the list and M is the number of elements removed.",
+			Handler: (*Server).ProcessRespCommandLRem,
+		},
+		&Command{
+			Name: "ltrim", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.", Since: "1.0.0", Complexity: "O(N) where N is the number of elements to be removed by the operation.",
+			Handler: (*Server).ProcessRespCommandLTrim,
+		},
+		&Command{
+			Name: "linsert", Arity: 5, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Inserts an element before or after another element in a list.", Since: "2.2.0", Complexity: "O(N) where N is the number of elements to traverse before seeing the value pivot.",
+			Handler: (*Server).ProcessRespCommandLInsert,
+		},
+		&Command{
+			Name: "lmove", Arity: 5, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "list", Summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.", Since: "6.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandLMove,
+		},
+		&Command{
+			Name: "hset", Arity: -4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Creates or modifies the value of a field in a hash.", Since: "2.0.0", Complexity: "O(1) for each field/value pair added, so O(N) to add N field/value pairs when the command is called with multiple field/value pairs.",
+			Handler: (*Server).ProcessRespCommandHSet,
+		},
+		&Command{
+			Name: "hsetnx", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Sets the value of a field in a hash only when the field doesn't exist.", Since: "2.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandHSetNX,
+		},
+		&Command{
+			Name: "hget", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the value of a field in a hash.", Since: "2.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandHGet,
+		},
+		&Command{
+			Name: "hmget", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the values of all fields in a hash.", Since: "2.0.0", Complexity: "O(N) where N is the number of fields being requested.",
+			Handler: (*Server).ProcessRespCommandHMGet,
+		},
+		&Command{
+			Name: "hgetall", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns all fields and values in a hash.", Since: "2.0.0", Complexity: "O(N) where N is the size of the hash.",
+			Handler: (*Server).ProcessRespCommandHGetAll,
+		},
+		&Command{
+			Name: "hkeys", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns all fields in a hash.", Since: "2.0.0", Complexity: "O(N) where N is the size of the hash.",
+			Handler: (*Server).ProcessRespCommandHKeys,
+		},
+		&Command{
+			Name: "hvals", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns all values in a hash.", Since: "2.0.0", Complexity: "O(N) where N is the size of the hash.",
+			Handler: (*Server).ProcessRespCommandHVals,
+		},
+		&Command{
+			Name: "hdel", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.", Since: "2.0.0", Complexity: "O(N) where N is the number of fields to be removed.",
+			Handler: (*Server).ProcessRespCommandHDel,
+		},
+		&Command{
+			Name: "hexists", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Determines whether a field exists in a hash.", Since: "2.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandHExists,
+		},
+		&Command{
+			Name: "hlen", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the number of fields in a hash.", Since: "2.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandHLen,
+		},
+		&Command{
+			Name: "hincrby", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.", Since: "2.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandHIncrBy,
+		},
+		&Command{
+			Name: "hincrbyfloat", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.", Since: "2.6.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandHIncrByFloat,
+		},
+		&Command{
+			Name: "hrandfield", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns one or more random fields from a hash.", Since: "6.2.0", Complexity: "O(N) where N is the number of fields returned",
+			Handler: (*Server).ProcessRespCommandHRandField,
+		},
+		&Command{
+			Name: "sadd", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", Since: "1.0.0", Complexity: "O(1) for each element added, so O(N) to add N elements when the command is called with multiple arguments.",
+			Handler: (*Server).ProcessRespCommandSAdd,
+		},
+		&Command{
+			Name: "srem", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Removes one or more members from a set. Deletes the set if the last member was removed.", Since: "1.0.0", Complexity: "O(N) where N is the number of members to be removed.",
+			Handler: (*Server).ProcessRespCommandSRem,
+		},
+		&Command{
+			Name: "sismember", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Determines whether a member belongs to a set.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandSIsMember,
+		},
+		&Command{
+			Name: "smismember", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Determines whether multiple members belong to a set.", Since: "6.2.0", Complexity: "O(N) where N is the number of elements being checked for membership",
+			Handler: (*Server).ProcessRespCommandSMIsMember,
+		},
+		&Command{
+			Name: "smembers", Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Returns all members of a set.", Since: "1.0.0", Complexity: "O(N) where N is the set cardinality.",
+			Handler: (*Server).ProcessRespCommandSMembers,
+		},
+		&Command{
+			Name: "scard", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Returns the number of members in a set.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandSCard,
+		},
+		&Command{
+			Name: "spop", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.", Since: "1.0.0", Complexity: "Without the count argument O(1), otherwise O(N) where N is the value of the passed count.",
+			Handler: (*Server).ProcessRespCommandSPop,
+		},
+		&Command{
+			Name: "srandmember", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Get one or multiple random members from a set", Since: "1.0.0", Complexity: "Without the count argument O(1), otherwise O(N) where N is the absolute value of the passed count.",
+			Handler: (*Server).ProcessRespCommandSRandMember,
+		},
+		&Command{
+			Name: "sinter", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Returns the intersect of multiple sets.", Since: "1.0.0", Complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			Handler: (*Server).ProcessRespCommandSInter,
+		},
+		&Command{
+			Name: "sunion", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Returns the union of multiple sets.", Since: "1.0.0", Complexity: "O(N) where N is the total number of elements in all given sets.",
+			Handler: (*Server).ProcessRespCommandSUnion,
+		},
+		&Command{
+			Name: "sdiff", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Returns the difference of multiple sets.", Since: "1.0.0", Complexity: "O(N) where N is the total number of elements in all given sets.",
+			Handler: (*Server).ProcessRespCommandSDiff,
+		},
+		&Command{
+			Name: "sinterstore", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Stores the intersect of multiple sets in a key.", Since: "1.0.0", Complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			Handler: (*Server).ProcessRespCommandSInterStore,
+		},
+		&Command{
+			Name: "sunionstore", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Stores the union of multiple sets in a key.", Since: "1.0.0", Complexity: "O(N) where N is the total number of elements in all given sets.",
+			Handler: (*Server).ProcessRespCommandSUnionStore,
+		},
+		&Command{
+			Name: "sdiffstore", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Stores the difference of multiple sets in a key.", Since: "1.0.0", Complexity: "O(N) where N is the total number of elements in all given sets.",
+			Handler: (*Server).ProcessRespCommandSDiffStore,
+		},
+		&Command{
+			Name: "sintercard", Arity: -3, Flags: FlagReadonly, MovableKeys: numKeysAt(1),
+			Group: "set", Summary: "Returns the number of members of the intersect of multiple sets.", Since: "7.0.0", Complexity: "O(N*M) worst case where N is the cardinality of the smallest set and M is the number of sets.",
+			Handler: (*Server).ProcessRespCommandSInterCard,
+		},
+		&Command{
+			Name: "zadd", Arity: -4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", Since: "1.2.0", Complexity: "O(log(N)) for each item added, where N is the number of elements in the sorted set.",
+			Handler: (*Server).ProcessRespCommandZAdd,
+		},
+		&Command{
+			Name: "zincrby", Arity: 4, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Increments the score of a member in a sorted set.", Since: "1.2.0", Complexity: "O(log(N)) where N is the number of elements in the sorted set.",
+			Handler: (*Server).ProcessRespCommandZIncrBy,
+		},
+		&Command{
+			Name: "zrem", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", Since: "1.2.0", Complexity: "O(M*log(N)) with N being the number of elements in the sorted set and M the number of elements to be removed.",
+			Handler: (*Server).ProcessRespCommandZRem,
+		},
+		&Command{
+			Name: "zcard", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the number of members in a sorted set.", Since: "1.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandZCard,
+		},
+		&Command{
+			Name: "zscore", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the score of a member in a sorted set.", Since: "1.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandZScore,
+		},
+		&Command{
+			Name: "zrank", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the index of a member in a sorted set ordered by ascending scores.", Since: "2.0.0", Complexity: "O(log(N))",
+			Handler: (*Server).ProcessRespCommandZRank,
+		},
+		&Command{
+			Name: "zrevrank", Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the index of a member in a sorted set ordered by descending scores.", Since: "2.0.0", Complexity: "O(log(N))",
+			Handler: (*Server).ProcessRespCommandZRevRank,
+		},
+		&Command{
+			Name: "zcount", Arity: 4, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the count of members in a sorted set that have scores within a range.", Since: "2.0.0", Complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+			Handler: (*Server).ProcessRespCommandZCount,
+		},
+		&Command{
+			Name: "zrange", Arity: -4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns members in a sorted set within a range of indexes.", Since: "1.2.0", Complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements returned.",
+			Handler: (*Server).ProcessRespCommandZRange,
+		},
+		&Command{
+			Name: "zrangestore", Arity: -5, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "sorted-set", Summary: "Stores a range of members from sorted set in a key.", Since: "6.2.0", Complexity: "O(log(N)+M) with N being the number of elements in the sorted set and M the number of elements stored into the destination key.",
+			Handler: (*Server).ProcessRespCommandZRangeStore,
+		},
+		&Command{
+			Name: "zpopmin", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", Since: "5.0.0", Complexity: "O(log(N)*M) with N being the number of elements in the sorted set, and M being the number of elements popped.",
+			Handler: (*Server).ProcessRespCommandZPopMin,
+		},
+		&Command{
+			Name: "zpopmax", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.", Since: "5.0.0", Complexity: "O(log(N)*M) with N being the number of elements in the sorted set, and M being the number of elements popped.",
+			Handler: (*Server).ProcessRespCommandZPopMax,
+		},
+		&Command{
+			Name: "zunionstore", Arity: -4, Flags: FlagWrite, MovableKeys: storeKeysAt(2),
+			Group: "sorted-set", Summary: "Stores the union of multiple sorted sets in a key.", Since: "2.0.0", Complexity: "O(N)+O(M log(M)) with N being the sum of the sizes of the input sorted sets, and M being the number of elements in the resulting sorted set.",
+			Handler: (*Server).ProcessRespCommandZUnionStore,
+		},
+		&Command{
+			Name: "zinterstore", Arity: -4, Flags: FlagWrite, MovableKeys: storeKeysAt(2),
+			Group: "sorted-set", Summary: "Stores the intersect of multiple sorted sets in a key.", Since: "2.0.0", Complexity: "O(N*K)+O(M*log(M)) worst case with N being the smallest input sorted set, K being the number of input sorted sets and M being the number of elements in the resulting sorted set.",
+			Handler: (*Server).ProcessRespCommandZInterStore,
+		},
+		&Command{
+			Name: "setbit", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.", Since: "2.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandSetBit,
+		},
+		&Command{
+			Name: "getbit", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Returns a bit value by offset.", Since: "2.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandGetBit,
+		},
+		&Command{
+			Name: "bitcount", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Counts the number of set bits (population counting) in a string.", Since: "2.6.0", Complexity: "O(N)",
+			Handler: (*Server).ProcessRespCommandBitCount,
+		},
+		&Command{
+			Name: "bitpos", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Finds the first set (1) or clear (0) bit in a string.", Since: "2.8.7", Complexity: "O(N)",
+			Handler: (*Server).ProcessRespCommandBitPos,
+		},
+		&Command{
+			Name: "bitop", Arity: -4, Flags: FlagWrite, FirstKey: 2, LastKey: -1, Step: 1,
+			Group: "bitmap", Summary: "Performs bitwise operations on multiple strings, and stores the result.", Since: "2.6.0", Complexity: "O(N)",
+			Handler: (*Server).ProcessRespCommandBitOp,
+		},
+		&Command{
+			Name: "bitfield", Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Performs arbitrary bitfield integer operations on strings.", Since: "3.2.0", Complexity: "O(1) for each subcommand specified",
+			Handler: (*Server).ProcessRespCommandBitField,
+		},
+		&Command{
+			Name: "xadd", Arity: -5, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Appends a new message to a stream. Creates the key if it doesn't exist.", Since: "5.0.0", Complexity: "O(1) when adding a new entry, O(N) when trimming where N being the number of entries evicted.",
+			Handler: (*Server).ProcessRespCommandXAdd,
+		},
+		&Command{
+			Name: "xlen", Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Return the number of messages in a stream.", Since: "5.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandXLen,
+		},
+		&Command{
+			Name: "xrange", Arity: -4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Returns the messages from a stream within a range of IDs.", Since: "5.0.0", Complexity: "O(log(N)+M) with N being the number of elements in the stream and M the number of elements being returned.",
+			Handler: (*Server).ProcessRespCommandXRange,
+		},
+		&Command{
+			Name: "xrevrange", Arity: -4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Returns the messages from a stream within a range of IDs in reverse order.", Since: "5.0.0", Complexity: "O(log(N)+M) with N being the number of elements in the stream and M the number of elements being returned.",
+			Handler: (*Server).ProcessRespCommandXRevRange,
+		},
+		&Command{
+			Name: "xdel", Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Returns the number of messages after removing them from a stream.", Since: "5.0.0", Complexity: "O(1) for each single item to delete in the stream, regardless of the stream size.",
+			Handler: (*Server).ProcessRespCommandXDel,
+		},
+		&Command{
+			Name: "xtrim", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Deletes messages from the beginning of a stream.", Since: "5.0.0", Complexity: "O(N), with N being the number of evicted entries.",
+			Handler: (*Server).ProcessRespCommandXTrim,
+		},
+		&Command{
+			Name: "xread", Arity: -4, Flags: FlagReadonly | FlagBlocking, MovableKeys: xreadKeys,
+			Group: "stream", Summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.", Since: "5.0.0", Complexity: "O(N) with N being the number of returned entries.",
+			Handler: (*Server).ProcessRespCommandXRead,
+		},
+		&Command{
+			Name: "pfadd", Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hyperloglog", Summary: "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist.", Since: "2.8.9", Complexity: "O(1) to add every element.",
+			Handler: (*Server).ProcessRespCommandPFAdd,
+		},
+		&Command{
+			Name: "pfcount", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "hyperloglog", Summary: "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s).", Since: "2.8.9", Complexity: "O(1) with a very small average constant time when called with a single key. O(N) with N being the number of keys, and much bigger constant times, when called with multiple keys.",
+			Handler: (*Server).ProcessRespCommandPFCount,
+		},
+		&Command{
+			Name: "pfmerge", Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "hyperloglog", Summary: "Merges one or more HyperLogLog values into a single key.", Since: "2.8.9", Complexity: "O(N) to merge N HyperLogLogs, but with high constant times.",
+			Handler: (*Server).ProcessRespCommandPFMerge,
+		},
+		&Command{
+			Name: "geoadd", Arity: -5, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "geo", Summary: "Adds one or more members to a geospatial index. The key is created if it doesn't exist.", Since: "3.2.0", Complexity: "O(log(N)) for each item added, where N is the number of elements in the sorted set.",
+			Handler: (*Server).ProcessRespCommandGeoAdd,
+		},
+		&Command{
+			Name: "geopos", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "geo", Summary: "Returns the longitude and latitude of members from a geospatial index.", Since: "3.2.0", Complexity: "O(1) for each member requested.",
+			Handler: (*Server).ProcessRespCommandGeoPos,
+		},
+		&Command{
+			Name: "geodist", Arity: -4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "geo", Summary: "Returns the distance between two members of a geospatial index.", Since: "3.2.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandGeoDist,
+		},
+		&Command{
+			Name: "geohash", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "geo", Summary: "Returns members from a geospatial index as geohash strings.", Since: "3.2.0", Complexity: "O(1) for each member requested.",
+			Handler: (*Server).ProcessRespCommandGeoHash,
+		},
+		&Command{
+			Name: "geosearch", Arity: -7, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "geo", Summary: "Queries a geospatial index for members inside an area of a box or a circle.", Since: "6.2.0", Complexity: "O(N+log(M)) where N is the number of elements in the grid-aligned bounding box area around the shape provided as the filter and M is the number of items inside the shape",
+			Handler: (*Server).ProcessRespCommandGeoSearch,
+		},
+		&Command{
+			Name: "geosearchstore", Arity: -8, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "geo", Summary: "Queries a geospatial index for members inside an area of a box or a circle, optionally stores the result.", Since: "6.2.0", Complexity: "O(N+log(M)) where N is the number of elements in the grid-aligned bounding box area around the shape provided as the filter and M is the number of items inside the shape",
+			Handler: (*Server).ProcessRespCommandGeoSearchStore,
+		},
+		&Command{
+			Name: "bf.reserve", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Creates a new Bloom Filter", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandBFReserve,
+		},
+		&Command{
+			Name: "bf.add", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Adds an item to a Bloom Filter", Since: "1.0.0", Complexity: "O(k), where k is the number of hash functions used by the last sub-filter",
+			Handler: (*Server).ProcessRespCommandBFAdd,
+		},
+		&Command{
+			Name: "bf.madd", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Adds one or more items to a Bloom Filter. A filter will be created if it does not exist", Since: "1.0.0", Complexity: "O(k * n), where k is the number of hash functions and n is the number of items",
+			Handler: (*Server).ProcessRespCommandBFMAdd,
+		},
+		&Command{
+			Name: "bf.exists", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Checks whether an item exists in a Bloom Filter", Since: "1.0.0", Complexity: "O(k), where k is the number of hash functions used by the last sub-filter",
+			Handler: (*Server).ProcessRespCommandBFExists,
+		},
+		&Command{
+			Name: "bf.mexists", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Checks whether one or more items exist in a Bloom Filter", Since: "1.0.0", Complexity: "O(k * n), where k is the number of hash functions and n is the number of items",
+			Handler: (*Server).ProcessRespCommandBFMExists,
+		},
+		&Command{
+			Name: "bf.info", Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Returns information about a Bloom Filter", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandBFInfo,
+		},
+		&Command{
+			Name: "cf.add", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Summary: "Adds an item to a Cuckoo Filter", Since: "1.0.0", Complexity: "O(k + i), where k is the number of sub-filters and i is maxIterations",
+			Handler: (*Server).ProcessRespCommandCFAdd,
+		},
+		&Command{
+			Name: "cf.exists", Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Summary: "Checks if one item exists in a Cuckoo Filter", Since: "1.0.0", Complexity: "O(k), where k is the number of sub-filters",
+			Handler: (*Server).ProcessRespCommandCFExists,
+		},
+		&Command{
+			Name: "cf.del", Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cf", Summary: "Deletes an item from a Cuckoo Filter", Since: "1.0.0", Complexity: "O(k), where k is the number of sub-filters",
+			Handler: (*Server).ProcessRespCommandCFDel,
+		},
+		&Command{
+			Name: "cms.initbydim", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Summary: "Initializes a Count-Min Sketch to dimensions specified by user", Since: "2.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandCMSInitByDim,
+		},
+		&Command{
+			Name: "cms.initbyprob", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Summary: "Initializes a Count-Min Sketch to accommodate requested tolerances.", Since: "2.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandCMSInitByProb,
+		},
+		&Command{
+			Name: "cms.incrby", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Summary: "Increases the count of one or more items by increment", Since: "2.0.0", Complexity: "O(n) where n is the number of items",
+			Handler: (*Server).ProcessRespCommandCMSIncrBy,
+		},
+		&Command{
+			Name: "cms.query", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Summary: "Returns the count for one or more items in a sketch", Since: "2.0.0", Complexity: "O(n) where n is the number of items",
+			Handler: (*Server).ProcessRespCommandCMSQuery,
+		},
+		&Command{
+			Name: "cms.merge", Arity: -4, Flags: FlagWrite, MovableKeys: storeKeysAt(2),
+			Group: "cms", Summary: "Merges several sketches into one sketch", Since: "2.0.0", Complexity: "O(n) where n is the number of sketches",
+			Handler: (*Server).ProcessRespCommandCMSMerge,
+		},
+		&Command{
+			Name: "topk.reserve", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "topk", Summary: "Initializes a TopK with specified parameters", Since: "2.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandTopKReserve,
+		},
+		&Command{
+			Name: "topk.add", Arity: -3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "topk", Summary: "Increases the count of one or more items by increment", Since: "2.0.0", Complexity: "O(n * k) where n is the number of items and k is the depth",
+			Handler: (*Server).ProcessRespCommandTopKAdd,
+		},
+		&Command{
+			Name: "topk.incrby", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "topk", Summary: "Increases the count of one or more items by increment", Since: "2.0.0", Complexity: "O(n * k * incr) where n is the number of items, k is the depth and incr is the increment",
+			Handler: (*Server).ProcessRespCommandTopKIncrBy,
+		},
+		&Command{
+			Name: "topk.query", Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "topk", Summary: "Checks whether one or more items are in a sketch", Since: "2.0.0", Complexity: "O(n) where n is the number of items",
+			Handler: (*Server).ProcessRespCommandTopKQuery,
+		},
+		&Command{
+			Name: "topk.list", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "topk", Summary: "Return full list of items in Top K list", Since: "2.0.0", Complexity: "O(k*log(k)) where k is the value of top-k",
+			Handler: (*Server).ProcessRespCommandTopKList,
+		},
+		&Command{
+			Name: "json.set", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Sets or updates the JSON value at a path", Since: "1.0.0", Complexity: "O(M+N) when path is evaluated to a single value where M is the size of the original value (if it exists) and N is the size of the new value",
+			Handler: (*Server).ProcessRespCommandJSONSet,
+		},
+		&Command{
+			Name: "json.get", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Gets the value at one or more paths in JSON serialized form", Since: "1.0.0", Complexity: "O(N) when path is evaluated to a single value where N is the size of the value",
+			Handler: (*Server).ProcessRespCommandJSONGet,
+		},
+		&Command{
+			Name: "json.del", Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Deletes a value", Since: "1.0.0", Complexity: "O(N) when path is evaluated to a single value where N is the size of the deleted value",
+			Handler: (*Server).ProcessRespCommandJSONDel,
+		},
+		&Command{
+			Name: "json.numincrby", Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Increments the numeric value at path by a value", Since: "1.0.0", Complexity: "O(1) when path is evaluated to a single value",
+			Handler: (*Server).ProcessRespCommandJSONNumIncrBy,
+		},
+		&Command{
+			Name: "json.arrappend", Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Append one or more json values into the array at path after the last element in it.", Since: "1.0.0", Complexity: "O(1) when path is evaluated to a single value",
+			Handler: (*Server).ProcessRespCommandJSONArrAppend,
+		},
+		&Command{
+			Name: "json.arrpop", Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Removes and returns the element at the specified index in the array at path", Since: "1.0.0", Complexity: "O(N) when path is evaluated to a single value where N is the size of the array and the specified index is not the last element",
+			Handler: (*Server).ProcessRespCommandJSONArrPop,
+		},
+		&Command{
+			Name: "json.type", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Returns the type of the JSON value at path", Since: "1.0.0", Complexity: "O(1) when path is evaluated to a single value",
+			Handler: (*Server).ProcessRespCommandJSONType,
+		},
+		&Command{
+			Name: "json.objlen", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Returns the number of keys of the object at path", Since: "1.0.0", Complexity: "O(1) when path is evaluated to a single value",
+			Handler: (*Server).ProcessRespCommandJSONObjLen,
+		},
+		&Command{
+			Name: "json.arrlen", Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "json", Summary: "Returns the length of the array at path", Since: "1.0.0", Complexity: "O(1) when path is evaluated to a single value",
+			Handler: (*Server).ProcessRespCommandJSONArrLen,
+		},
+		&Command{
+			Name: "object", Arity: -2, Flags: FlagReadonly, FirstKey: 2, LastKey: 2, Step: 1,
+			Group: "generic", Summary: "Returns the internal encoding of a Redis object.", Since: "2.2.3", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandObject,
+		},
+		&Command{
+			Name: "waitkey", Arity: -3, Flags: FlagReadonly | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1,
+			Group: "string", Summary: "Returns the value of the first of the keys which exists or blocks until one of them is set.", Complexity: "O(N) where N is the number of provided keys.",
+			Handler: (*Server).ProcessRespCommandWaitKey,
+		},
+		&Command{
+			Name: "hello", Arity: -1, Flags: FlagFast,
+			Group: "connection", Summary: "Handshakes with the server.", Since: "6.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandHello,
+		},
+		&Command{
+			Name: "quit", Arity: -1, Flags: FlagFast,
+			Group: "connection", Summary: "Closes the connection.", Since: "1.0.0", Complexity: "O(1)",
+			Handler: (*Server).ProcessRespCommandQuit,
+		},
+		&Command{
+			Name: "command", Arity: -1,
+			Group: "server", Summary: "Returns detailed information about all commands.", Since: "2.8.13", Complexity: "O(N) where N is the total number of commands.",
+			Handler: (*Server).ProcessRespCommandCommand,
+		},
+	)
+}
+
+// LookupCommand function returns the command with the given name, names
+// are case insensitive. nil is returned if there is no such command
+func LookupCommand(name string) *Command {
+	return commandTable[strings.ToLower(name)]
+}
+
+// CheckArity function validates the number of arguments of a command
+func (cmd *Command) CheckArity(commands []string) error {
+	if (cmd.Arity > 0 && len(commands) != cmd.Arity) || len(commands) < -cmd.Arity {
+		return errors.New(fmt.Sprintf("ERR wrong number of arguments for '%s' command", cmd.Name))
+	}
+	return nil
+}
+
+// Keys function returns the key arguments of a command based on the key
+// positions declared by it
+func (cmd *Command) Keys(commands []string) []Key {
+	if cmd.MovableKeys != nil {
+		return cmd.MovableKeys(commands)
+	}
+	result := []Key{}
+	if cmd.FirstKey == 0 {
+		return result
+	}
+	last := cmd.LastKey
+	if last < 0 {
+		last = len(commands) + last
+	}
+	for i := cmd.FirstKey; i <= last && i < len(commands); i += cmd.Step {
+		result = append(result, Key(commands[i]))
+	}
+	return result
+}
+
+// Info function returns the description of the command in the format of
+// the COMMAND INFO reply: name, arity, flags, key positions, ACL
+// categories, tips, key specifications and subcommands
+func (cmd *Command) Info() []interface{} {
+	flags := SetReply{}
+	for _, name := range cmd.Flags.Names() {
+		flags = append(flags, SimpleString(name))
+	}
+	if cmd.MovableKeys != nil {
+		flags = append(flags, SimpleString("movablekeys"))
+	}
+	return []interface{}{
+		cmd.Name,
+		int64(cmd.Arity),
+		flags,
+		int64(cmd.FirstKey),
+		int64(cmd.LastKey),
+		int64(cmd.Step),
+		cmd.categories(),
+		SetReply{},
+		[]interface{}{},
+		[]interface{}{},
+	}
+}
+
+// categories function returns the ACL categories of the command which
+// are derived from its flags and group
+func (cmd *Command) categories() SetReply {
+	result := SetReply{}
+	if cmd.Flags&FlagWrite != 0 {
+		result = append(result, SimpleString("@write"))
+	}
+	if cmd.Flags&FlagReadonly != 0 {
+		result = append(result, SimpleString("@read"))
+	}
+	if cmd.Group == "generic" {
+		result = append(result, SimpleString("@keyspace"))
+	} else if cmd.Group != "server" {
+		result = append(result, SimpleString("@"+cmd.Group))
+	}
+	if cmd.Flags&FlagFast != 0 {
+		result = append(result, SimpleString("@fast"))
+	} else {
+		result = append(result, SimpleString("@slow"))
+	}
+	if cmd.Flags&FlagAdmin != 0 {
+		result = append(result, SimpleString("@admin"), SimpleString("@dangerous"))
+	}
+	if cmd.Flags&FlagPubsub != 0 {
+		result = append(result, SimpleString("@pubsub"))
+	}
+	return result
+}
+
+// Docs function returns the documentation of the command in the format
+// of the COMMAND DOCS reply, since is omitted for the commands which are
+// not part of redis
+func (cmd *Command) Docs() MapReply {
+	result := MapReply{"summary", cmd.Summary}
+	if cmd.Since != "" {
+		result = append(result, "since", cmd.Since)
+	}
+	return append(result, "group", cmd.Group, "complexity", cmd.Complexity)
+}
+
+// sortedCommands function returns all the commands in the command table
+// sorted by name
+func sortedCommands() []*Command {
+	result := make([]*Command, 0, len(commandTable))
+	for _, cmd := range commandTable {
+		result = append(result, cmd)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// ProcessRespCommandCommand function processes redis command COMMAND and
+// its subcommands COUNT, INFO, DOCS and GETKEYS which describe the commands
+// supported by the server
+func (s *Server) ProcessRespCommandCommand(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) == 1 {
+		result := []interface{}{}
+		for _, cmd := range sortedCommands() {
+			result = append(result, cmd.Info())
+		}
+		return result, nil
+	}
+
+	switch strings.ToUpper(commands[1]) {
+	case "COUNT":
+		if len(commands) != 2 {
+			return nil, errors.New("ERR wrong number of arguments for 'command|count' command")
+		}
+		return int64(len(commandTable)), nil
+
+	case "INFO":
+		result := []interface{}{}
+		if len(commands) == 2 {
+			for _, cmd := range sortedCommands() {
+				result = append(result, cmd.Info())
+			}
+			return result, nil
+		}
+		for _, name := range commands[2:] {
+			if cmd := LookupCommand(name); cmd != nil {
+				result = append(result, cmd.Info())
+			} else {
+				result = append(result, nil)
+			}
+		}
+		return result, nil
+
+	case "DOCS":
+		result := MapReply{}
+		if len(commands) == 2 {
+			for _, cmd := range sortedCommands() {
+				result = append(result, cmd.Name, cmd.Docs())
+			}
+			return result, nil
+		}
+		for _, name := range commands[2:] {
+			if cmd := LookupCommand(name); cmd != nil {
+				result = append(result, cmd.Name, cmd.Docs())
+			}
+		}
+		return result, nil
+
+	case "GETKEYS":
+		if len(commands) < 3 {
+			return nil, errors.New("ERR wrong number of arguments for 'command|getkeys' command")
+		}
+		cmd := LookupCommand(commands[2])
+		if cmd == nil {
+			return nil, errors.New("ERR Invalid command specified")
+		}
+		if cmd.CheckArity(commands[2:]) != nil {
+			return nil, errors.New("ERR Invalid number of arguments specified for command")
+		}
+		keys := cmd.Keys(commands[2:])
+		if len(keys) == 0 {
+			return nil, errors.New("ERR The command has no key arguments")
+		}
+		return keys, nil
+
+	default:
+		return nil, errors.New(fmt.Sprintf("ERR unknown subcommand '%s'. Try COMMAND HELP.", commands[1]))
+	}
+}
+
+// unknownCommandError function returns the error redis replies with for
+// commands it does not support
+func unknownCommandError(commands []string) error {
+	args := ""
+	for _, arg := range commands[1:] {
+		if len(args) >= 128 {
+			break
+		}
+		args += fmt.Sprintf("'%s' ", arg)
+	}
+	return errors.New(fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", commands[0], args))
+}
@@ -0,0 +1,80 @@
+package microredis
+
+import (
+	"bufio"
+	"net"
+)
+
+// RedisVersion is the version of redis whose behaviour the server mimics,
+// it is reported to clients which negotiate features based on it
+const RedisVersion = "7.0.0"
+
+// maxBatchLen is the maximum number of pipelined commands executed as a
+// single batch, so that one client can't hold the server lock for too long
+const maxBatchLen = 1024
+
+// Connection struct holds the state the server keeps for each connected
+// client. id uniquely identifies the connection for the lifetime of the
+// server, proto is the RESP version negotiated through HELLO which
+// determines how replies are encoded and name is set by the client
+// Requests are read through reader and replies are buffered in writer
+// until they are flushed to the client
+type Connection struct {
+	conn   net.Conn
+	reader *RespReader
+	writer *bufio.Writer
+	id     int64
+	proto  int
+	name   string
+}
+
+// NewConnection function creates and initializes the state for a newly
+// accepted connection. Every connection starts off talking RESP2
+func NewConnection(conn net.Conn, id int64) *Connection {
+	result := Connection{
+		conn:   conn,
+		reader: NewRespReader(bufio.NewReader(conn)),
+		writer: bufio.NewWriter(conn),
+		id:     id,
+		proto:  2,
+	}
+	return &result
+}
+
+// Marshal function encodes a reply in the protocol version negotiated
+// by the connection
+func (c *Connection) Marshal(i interface{}) string {
+	return MarshalRespProto(i, c.proto)
+}
+
+// ReadBatch function blocks until at least one command is received and
+// then keeps reading the commands which have already been received
+// without waiting for more. Empty requests are skipped. If reading fails
+// the commands read before the failure are returned along with the error
+func (c *Connection) ReadBatch() ([][]string, error) {
+	batch := make([][]string, 0, 1)
+	for len(batch) < maxBatchLen {
+		commands, err := c.reader.ReadCommand()
+		if err != nil {
+			return batch, err
+		}
+		if len(commands) > 0 {
+			batch = append(batch, commands)
+		}
+		if len(batch) > 0 && c.reader.Buffered() == 0 {
+			break
+		}
+	}
+	return batch, nil
+}
+
+// WriteReply function encodes a reply and buffers it to be sent on the
+// next Flush
+func (c *Connection) WriteReply(i interface{}) {
+	c.writer.WriteString(c.Marshal(i))
+}
+
+// Flush function sends all the buffered replies to the client
+func (c *Connection) Flush() error {
+	return c.writer.Flush()
+}
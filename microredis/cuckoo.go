@@ -0,0 +1,290 @@
+package microredis
+
+import (
+	"errors"
+)
+
+const (
+	// cuckooBucketSize is the number of fingerprints each bucket holds
+	cuckooBucketSize = 2
+	// cuckooMaxIterations is the number of fingerprints moved to make room
+	// for a new one before the filter is considered full
+	cuckooMaxIterations = 20
+	// cuckooDefaultCapacity is the capacity of the filters CF.ADD creates,
+	// a full filter is followed by another filter of the same capacity
+	cuckooDefaultCapacity  = 1024
+	cuckooDefaultExpansion = 1
+	// cuckooMaxLayers and cuckooMaxSize bound the number of tables of a
+	// filter and their total number of fingerprints, which take a byte
+	// each, so that a filter can't take unbounded memory nor time to look
+	// up an item
+	cuckooMaxLayers = 32
+	cuckooMaxSize   = maxStringLen
+)
+
+// errCuckooFull is returned when an item can't be added to a filter
+// without expanding it past its bounds
+var errCuckooFull = errors.New("ERR Filter is full")
+
+// cuckooLayer struct denotes one of the fixed size tables a Cuckoo filter
+// is made of, the number of buckets is a power of two so that the
+// alternate bucket of a fingerprint can be found without its item
+type cuckooLayer struct {
+	buckets [][cuckooBucketSize]uint8
+	mask    uint64
+}
+
+// cuckooLayerBuckets function returns the number of buckets of a table
+// with room for at least capacity fingerprints
+func cuckooLayerBuckets(capacity int64) uint64 {
+	n := uint64(1)
+	for n*cuckooBucketSize < uint64(capacity) {
+		n <<= 1
+	}
+	return n
+}
+
+// newCuckooLayer function creates a table with room for at least capacity
+// fingerprints
+func newCuckooLayer(capacity int64) *cuckooLayer {
+	n := cuckooLayerBuckets(capacity)
+	return &cuckooLayer{buckets: make([][cuckooBucketSize]uint8, n), mask: n - 1}
+}
+
+// cuckooHash function returns the fingerprint of item along with its
+// primary bucket hash, a fingerprint is never 0 which denotes a free slot
+func cuckooHash(item string) (uint8, uint64) {
+	hash := murmurHash64A([]byte(item), 0)
+	return uint8(hash%255 + 1), hash
+}
+
+// altIndex function returns the other bucket fp can be stored in
+func altIndex(index uint64, fp uint8) uint64 {
+	return index ^ (uint64(fp) * 0x5bd1e995)
+}
+
+// find function returns the bucket and the slot fp is stored at if it is
+// stored in either of the buckets of hash
+func (l *cuckooLayer) find(fp uint8, hash uint64) (uint64, int, bool) {
+	for _, index := range []uint64{hash & l.mask, altIndex(hash, fp) & l.mask} {
+		for slot, cur := range l.buckets[index] {
+			if cur == fp {
+				return index, slot, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// place function stores fp in a free slot of bucket index, it returns false
+// if the bucket is full
+func (l *cuckooLayer) place(index uint64, fp uint8) bool {
+	for slot, cur := range l.buckets[index] {
+		if cur == 0 {
+			l.buckets[index][slot] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// insert function stores fp in one of the buckets of hash, moving other
+// fingerprints to their alternate bucket to make room if both are full.
+// It returns false and leaves the table unchanged if no room is found
+func (l *cuckooLayer) insert(fp uint8, hash uint64) bool {
+	index := hash & l.mask
+	if l.place(index, fp) || l.place(altIndex(index, fp)&l.mask, fp) {
+		return true
+	}
+	type swap struct {
+		index uint64
+		slot  int
+	}
+	swaps := []swap{}
+	for i := 0; i < cuckooMaxIterations; i++ {
+		slot := i % cuckooBucketSize
+		fp, l.buckets[index][slot] = l.buckets[index][slot], fp
+		swaps = append(swaps, swap{index, slot})
+		index = altIndex(index, fp) & l.mask
+		if l.place(index, fp) {
+			return true
+		}
+	}
+	// undo the moves so that no fingerprint is lost
+	for i := len(swaps) - 1; i >= 0; i-- {
+		sw := swaps[i]
+		fp, l.buckets[sw.index][sw.slot] = l.buckets[sw.index][sw.slot], fp
+	}
+	return false
+}
+
+// CuckooFilter struct denotes the Cuckoo filter datatype, which like a
+// Bloom filter tests whether items were added to it with false positives
+// but also allows to delete them. Items are stored as fingerprints in
+// tables of capacity fingerprints, once they are all full a table
+// expansion times larger than the last one is added, up to cuckooMaxLayers
+type CuckooFilter struct {
+	layers    []*cuckooLayer
+	capacity  int64
+	expansion int64
+	items     int64
+}
+
+// NewCuckooFilter function creates an empty Cuckoo filter for capacity
+// items
+func NewCuckooFilter(capacity int64, expansion int64) *CuckooFilter {
+	return &CuckooFilter{
+		layers:    []*cuckooLayer{newCuckooLayer(capacity)},
+		capacity:  capacity,
+		expansion: expansion,
+	}
+}
+
+// Add function adds item to the filter, an item can be added several times
+// and has to be deleted as many times. It is stored in the first table
+// with room for it, errCuckooFull is returned if there is none and the
+// filter can't expand
+func (cf *CuckooFilter) Add(item string) error {
+	fp, hash := cuckooHash(item)
+	for _, l := range cf.layers {
+		if l.insert(fp, hash) {
+			cf.items++
+			return nil
+		}
+	}
+	if cf.expansion == 0 || len(cf.layers) >= cuckooMaxLayers {
+		return errCuckooFull
+	}
+	size := int64(0)
+	for _, l := range cf.layers {
+		size += int64(len(l.buckets)) * cuckooBucketSize
+	}
+	last := int64(len(cf.layers[len(cf.layers)-1].buckets)) * cuckooBucketSize
+	if last > cuckooMaxSize/cf.expansion {
+		return errCuckooFull
+	}
+	capacity := last * cf.expansion
+	if size+int64(cuckooLayerBuckets(capacity))*cuckooBucketSize > cuckooMaxSize {
+		return errCuckooFull
+	}
+	layer := newCuckooLayer(capacity)
+	cf.layers = append(cf.layers, layer)
+	layer.insert(fp, hash)
+	cf.items++
+	return nil
+}
+
+// Exists function returns whether item may have been added to the filter
+func (cf *CuckooFilter) Exists(item string) bool {
+	fp, hash := cuckooHash(item)
+	for _, l := range cf.layers {
+		if _, _, ok := l.find(fp, hash); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete function deletes one occurrence of item from the filter, it
+// returns false if the item was not found
+func (cf *CuckooFilter) Delete(item string) bool {
+	fp, hash := cuckooHash(item)
+	for i := len(cf.layers) - 1; i >= 0; i-- {
+		if index, slot, ok := cf.layers[i].find(fp, hash); ok {
+			cf.layers[i].buckets[index][slot] = 0
+			cf.items--
+			return true
+		}
+	}
+	return false
+}
+
+// getCuckooFilter function returns the Cuckoo filter stored at key. If the
+// key does not exist nil is returned, unless create is set in which case a
+// new filter with the default capacity is stored at key. ErrWrongType is
+// returned if the key holds another datatype
+func (s *Storage) getCuckooFilter(key Key, create bool) (*CuckooFilter, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		if !create {
+			return nil, nil
+		}
+		result := NewCuckooFilter(cuckooDefaultCapacity, cuckooDefaultExpansion)
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	result, ok := val.val.(*CuckooFilter)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// CFAdd function adds item to the Cuckoo filter stored at key, creating it
+// if it does not exist
+func (s *Storage) CFAdd(key Key, item string) error {
+	cf, err := s.getCuckooFilter(key, true)
+	if err != nil {
+		return err
+	}
+	if err := cf.Add(item); err != nil {
+		return err
+	}
+	s.keyModified(key)
+	return nil
+}
+
+// CFExists function returns whether item may have been added to the Cuckoo
+// filter stored at key
+func (s *Storage) CFExists(key Key, item string) (bool, error) {
+	cf, err := s.getCuckooFilter(key, false)
+	if cf == nil {
+		return false, err
+	}
+	return cf.Exists(item), nil
+}
+
+// CFDel function deletes one occurrence of item from the Cuckoo filter
+// stored at key, failing if the key does not exist. Returns whether the
+// item was found. Like in RedisBloom the key is kept once the filter is
+// empty
+func (s *Storage) CFDel(key Key, item string) (bool, error) {
+	cf, err := s.getCuckooFilter(key, false)
+	if err != nil {
+		return false, err
+	}
+	if cf == nil {
+		return false, errors.New("ERR Not found")
+	}
+	deleted := cf.Delete(item)
+	if deleted {
+		s.keyModified(key)
+	}
+	return deleted, nil
+}
+
+// ProcessRespCommandCFAdd function processes command CF.ADD
+func (s *Server) ProcessRespCommandCFAdd(c *Connection, commands []string) (interface{}, error) {
+	if err := s.db.CFAdd(Key(commands[1]), commands[2]); err != nil {
+		return nil, err
+	}
+	return 1, nil
+}
+
+// ProcessRespCommandCFExists function processes command CF.EXISTS
+func (s *Server) ProcessRespCommandCFExists(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.CFExists(Key(commands[1]), commands[2])
+	if err != nil {
+		return nil, err
+	}
+	return boolsReply([]bool{result})[0], nil
+}
+
+// ProcessRespCommandCFDel function processes command CF.DEL
+func (s *Server) ProcessRespCommandCFDel(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.CFDel(Key(commands[1]), commands[2])
+	if err != nil {
+		return nil, err
+	}
+	return boolsReply([]bool{result})[0], nil
+}
@@ -0,0 +1,114 @@
+package microredis_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestCuckooFilter(t *testing.T) {
+	cf := m.NewCuckooFilter(1024, 1)
+	for i := 0; i < 5000; i++ {
+		assert.Nil(t, cf.Add("item:"+strconv.Itoa(i)))
+	}
+
+	// a full table is followed by another one so that no item is lost
+	positives := 0
+	for i := 0; i < 5000; i++ {
+		assert.True(t, cf.Exists("item:"+strconv.Itoa(i)))
+		if cf.Exists("other:" + strconv.Itoa(i)) {
+			positives++
+		}
+	}
+	assert.Less(t, positives, 500)
+
+	for i := 0; i < 5000; i++ {
+		assert.True(t, cf.Delete("item:"+strconv.Itoa(i)))
+	}
+	for i := 0; i < 5000; i++ {
+		assert.False(t, cf.Exists("item:"+strconv.Itoa(i)))
+	}
+
+	// items added twice have to be deleted twice
+	cf = m.NewCuckooFilter(4, 0)
+	assert.Nil(t, cf.Add("a"))
+	assert.Nil(t, cf.Add("a"))
+	assert.True(t, cf.Delete("a"))
+	assert.True(t, cf.Exists("a"))
+	assert.True(t, cf.Delete("a"))
+	assert.False(t, cf.Exists("a"))
+	assert.False(t, cf.Delete("a"))
+
+	full := false
+	for i := 0; i < 100 && !full; i++ {
+		full = cf.Add(strconv.Itoa(i)) != nil
+	}
+	assert.True(t, full)
+
+	// the same item fills its buckets in every table, the number of tables
+	// is bounded and one deleted makes room again in its table
+	cf = m.NewCuckooFilter(1024, 1)
+	var err error
+	adds := 0
+	for ; adds < 1000 && err == nil; adds++ {
+		err = cf.Add("a")
+	}
+	assert.Equal(t, "ERR Filter is full", err.Error())
+	assert.LessOrEqual(t, adds, 4*32+1)
+	assert.True(t, cf.Delete("a"))
+	assert.Nil(t, cf.Add("a"))
+	assert.Equal(t, "ERR Filter is full", cf.Add("a").Error())
+}
+
+func TestCuckooFilterStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	assert.Nil(t, s.CFAdd(m.Key("cf"), "a"))
+	assert.Equal(t, "MBbloomCF", s.Type(m.Key("cf")))
+	exists, err := s.CFExists(m.Key("cf"), "a")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	exists, err = s.CFExists(m.Key("missing"), "a")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	deleted, err := s.CFDel(m.Key("cf"), "a")
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	deleted, _ = s.CFDel(m.Key("cf"), "a")
+	assert.False(t, deleted)
+	assert.Equal(t, "MBbloomCF", s.Type(m.Key("cf")))
+	_, err = s.CFDel(m.Key("missing"), "a")
+	assert.Equal(t, "ERR Not found", err.Error())
+
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	assert.Equal(t, m.ErrWrongType, s.CFAdd(m.Key("str"), "a"))
+}
+
+func TestCuckooFilterCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"add, exists and delete", []step{
+			{args: []string{"CF.ADD", "cf", "a"}, reply: ":1\r\n"},
+			{args: []string{"CF.ADD", "cf", "a"}, reply: ":1\r\n"},
+			{args: []string{"CF.EXISTS", "cf", "a"}, reply: ":1\r\n"},
+			{args: []string{"CF.EXISTS", "cf", "b"}, reply: ":0\r\n"},
+			{args: []string{"CF.EXISTS", "missing", "b"}, reply: ":0\r\n"},
+			{args: []string{"CF.DEL", "cf", "a"}, reply: ":1\r\n"},
+			{args: []string{"CF.DEL", "cf", "a"}, reply: ":1\r\n"},
+			{args: []string{"CF.DEL", "cf", "a"}, reply: ":0\r\n"},
+			{args: []string{"CF.EXISTS", "cf", "a"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "cf"}, reply: "+MBbloomCF\r\n"},
+		}},
+		{"errors", []step{
+			{args: []string{"CF.DEL", "missing", "a"}, reply: "-ERR Not found\r\n"},
+			{args: []string{"CF.ADD", "cf"}, reply: "-ERR wrong number of arguments for 'cf.add' command\r\n"},
+			{args: []string{"CF.ADD", "cf", "a"}, reply: ":1\r\n"},
+			{args: []string{"BF.ADD", "cf", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"SET", "str", "v"}, reply: "+OK\r\n"},
+			{args: []string{"CF.ADD", "str", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"CF.DEL", "str", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}
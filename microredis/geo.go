@@ -0,0 +1,622 @@
+package microredis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Geospatial indexes are sorted sets like in redis, the score of a member
+// is the 52 bit geohash of its position interleaving 26 bits of latitude
+// and 26 bits of longitude, so that nearby positions get nearby scores
+const (
+	geoStep   = 26
+	geoLatMin = -85.05112878
+	geoLatMax = 85.05112878
+	geoLonMin = -180.0
+	geoLonMax = 180.0
+	// geoEarthRadius is the earth radius in meters used by redis
+	geoEarthRadius = 6372797.560856
+	geoAlphabet    = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// geoUnits maps the units of distance accepted by the geo commands to
+// meters
+var geoUnits = map[string]float64{"m": 1, "km": 1000, "ft": 0.3048, "mi": 1609.34}
+
+// GeoPoint struct denotes a position given by its longitude and latitude
+// in degrees
+type GeoPoint struct {
+	Lon float64
+	Lat float64
+}
+
+// interleave function interleaves the bits of x and y, x in the even bits
+// and y in the odd ones
+func interleave(x uint32, y uint32) uint64 {
+	result := uint64(0)
+	for i := 0; i < 32; i++ {
+		result |= uint64(x>>i&1)<<(2*i) | uint64(y>>i&1)<<(2*i+1)
+	}
+	return result
+}
+
+// deinterleave function is the reverse of interleave
+func deinterleave(bits uint64) (uint32, uint32) {
+	x, y := uint32(0), uint32(0)
+	for i := 0; i < 32; i++ {
+		x |= uint32(bits>>(2*i)&1) << i
+		y |= uint32(bits>>(2*i+1)&1) << i
+	}
+	return x, y
+}
+
+// geohashEncode function returns the geohash of p with step bits for each
+// coordinate, where the latitude spans from lat_min to lat_max
+func geohashEncode(p GeoPoint, lat_min float64, lat_max float64, step uint) uint64 {
+	lat_offset := (p.Lat - lat_min) / (lat_max - lat_min) * float64(uint64(1)<<step)
+	lon_offset := (p.Lon - geoLonMin) / (geoLonMax - geoLonMin) * float64(uint64(1)<<step)
+	// the maximum of each range belongs to the last cell
+	lat_cell := math.Min(lat_offset, float64(uint64(1)<<step-1))
+	lon_cell := math.Min(lon_offset, float64(uint64(1)<<step-1))
+	return interleave(uint32(lat_cell), uint32(lon_cell))
+}
+
+// geoScore function returns the score of the position p
+func geoScore(p GeoPoint) float64 {
+	return float64(geohashEncode(p, geoLatMin, geoLatMax, geoStep))
+}
+
+// geoDecode function returns the position a score denotes, the center of
+// the area of its geohash
+func geoDecode(score float64) GeoPoint {
+	lat_cell, lon_cell := deinterleave(uint64(score))
+	cells := float64(uint64(1) << geoStep)
+	// computed the way redis does for positions to be replied identically
+	lat_min := geoLatMin + (float64(lat_cell)/cells)*(geoLatMax-geoLatMin)
+	lat_max := geoLatMin + (float64(lat_cell+1)/cells)*(geoLatMax-geoLatMin)
+	lon_min := geoLonMin + (float64(lon_cell)/cells)*(geoLonMax-geoLonMin)
+	lon_max := geoLonMin + (float64(lon_cell+1)/cells)*(geoLonMax-geoLonMin)
+	lat, lon := (lat_min+lat_max)/2, (lon_min+lon_max)/2
+	return GeoPoint{
+		math.Max(geoLonMin, math.Min(geoLonMax, lon)),
+		math.Max(geoLatMin, math.Min(geoLatMax, lat)),
+	}
+}
+
+// geohashString function returns the standard 11 characters geohash of p,
+// which unlike scores is computed over the whole latitude range
+func geohashString(p GeoPoint) string {
+	bits := geohashEncode(p, -90, 90, geoStep)
+	result := make([]byte, 11)
+	for i := range result {
+		idx := uint64(0)
+		// 52 bits only fill 10 characters and a bit
+		if i < 10 {
+			idx = bits >> (52 - (i+1)*5) & 0x1f
+		}
+		result[i] = geoAlphabet[idx]
+	}
+	return string(result)
+}
+
+// geoDistance function returns the distance in meters between a and b
+// along the surface of the earth, using the haversine formula
+func geoDistance(a GeoPoint, b GeoPoint) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	v := math.Sin((b.Lon - a.Lon) * math.Pi / 360)
+	if v == 0 {
+		return geoEarthRadius * math.Abs(lat2-lat1)
+	}
+	u := math.Sin((lat2 - lat1) / 2)
+	return 2 * geoEarthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1)*math.Cos(lat2)*v*v))
+}
+
+// GeoShape struct denotes the area searched by GEOSEARCH around Center,
+// a circle of Radius meters, or a box of Width by Height meters if ByBox
+// is set
+type GeoShape struct {
+	Center GeoPoint
+	Radius float64
+	ByBox  bool
+	Width  float64
+	Height float64
+}
+
+// contains function returns whether p is within the shape along with its
+// distance in meters from the center
+func (shape GeoShape) contains(p GeoPoint) (float64, bool) {
+	if shape.ByBox {
+		if geoEarthRadius*math.Abs(p.Lat-shape.Center.Lat)*math.Pi/180 > shape.Height/2 {
+			return 0, false
+		}
+		if geoDistance(GeoPoint{shape.Center.Lon, p.Lat}, p) > shape.Width/2 {
+			return 0, false
+		}
+		return geoDistance(shape.Center, p), true
+	}
+	dist := geoDistance(shape.Center, p)
+	return dist, dist <= shape.Radius
+}
+
+// extent function returns how far the shape reaches from its center in
+// degrees of latitude and longitude, the longitude is infinite when the
+// shape reaches a pole
+func (shape GeoShape) extent() (float64, float64) {
+	half_height, half_width := shape.Radius, shape.Radius
+	if shape.ByBox {
+		half_height, half_width = shape.Height/2, shape.Width/2
+	}
+	lat := half_height / geoEarthRadius * 180 / math.Pi
+	if shape.ByBox {
+		// the width of the box is measured at the latitude of each point,
+		// the widest in degrees being the one closest to a pole
+		max_lat := (math.Abs(shape.Center.Lat) + lat) * math.Pi / 180
+		sin := math.Sin(half_width/geoEarthRadius/2) / math.Cos(max_lat)
+		if max_lat >= math.Pi/2 || sin >= 1 {
+			return lat, math.Inf(1)
+		}
+		return lat, 2 * math.Asin(sin) * 180 / math.Pi
+	}
+	sin := math.Sin(half_width/geoEarthRadius) / math.Cos(shape.Center.Lat*math.Pi/180)
+	if sin >= 1 {
+		return lat, math.Inf(1)
+	}
+	return lat, math.Asin(sin) * 180 / math.Pi
+}
+
+// cells function returns the score ranges to scan to find the members
+// within the shape. Like redis it picks the smallest geohash cells still
+// larger than the extent of the shape so that the cell of the center and
+// its eight neighbours cover the whole shape
+func (shape GeoShape) cells() []ScoreRange {
+	lat_extent, lon_extent := shape.extent()
+	step := uint(geoStep)
+	for step > 1 {
+		lat_cell := (geoLatMax - geoLatMin) / float64(uint64(1)<<step)
+		lon_cell := (geoLonMax - geoLonMin) / float64(uint64(1)<<step)
+		if lat_cell >= lat_extent && lon_cell >= lon_extent {
+			break
+		}
+		step--
+	}
+	lat_cell := (geoLatMax - geoLatMin) / float64(uint64(1)<<step)
+	lon_cell := (geoLonMax - geoLonMin) / float64(uint64(1)<<step)
+	shift := 2 * (geoStep - step)
+	result := []ScoreRange{}
+	seen := map[uint64]bool{}
+	for _, dlat := range []float64{0, -1, 1} {
+		lat := shape.Center.Lat + dlat*lat_cell
+		if lat < geoLatMin || lat > geoLatMax {
+			continue
+		}
+		for _, dlon := range []float64{0, -1, 1} {
+			lon := shape.Center.Lon + dlon*lon_cell
+			if lon < geoLonMin {
+				lon += 360
+			} else if lon > geoLonMax {
+				lon -= 360
+			}
+			cell := geohashEncode(GeoPoint{lon, lat}, geoLatMin, geoLatMax, geoStep) >> shift
+			if seen[cell] {
+				continue
+			}
+			seen[cell] = true
+			result = append(result, ScoreRange{
+				Min: float64(cell << shift), Max: float64((cell + 1) << shift), MaxExclusive: true,
+			})
+		}
+	}
+	return result
+}
+
+// GeoResult struct denotes a member found by GEOSEARCH with its position,
+// its distance in meters from the center of the search and its score
+type GeoResult struct {
+	Member string
+	Score  float64
+	Point  GeoPoint
+	Dist   float64
+}
+
+// GeoAdd function adds the members at the given positions to the
+// geospatial index stored at key following flags, creating it if it does
+// not exist. Returns the number of members added, see ZAdd
+func (s *Storage) GeoAdd(key Key, members []string, points []GeoPoint, flags ZAddFlags) (int, error) {
+	scored := make([]ZMember, len(members))
+	for i, member := range members {
+		scored[i] = ZMember{member, geoScore(points[i])}
+	}
+	return s.ZAdd(key, scored, flags)
+}
+
+// GeoPos function returns the positions of the members of the geospatial
+// index stored at key, nil for those which are not members
+func (s *Storage) GeoPos(key Key, members []string) ([]*GeoPoint, error) {
+	z, err := s.getZSet(key, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*GeoPoint, len(members))
+	if z == nil {
+		return result, nil
+	}
+	for i, member := range members {
+		if score, prs := z.Score(member); prs {
+			p := geoDecode(score)
+			result[i] = &p
+		}
+	}
+	return result, nil
+}
+
+// GeoSearch function returns the members of the geospatial index stored at
+// key within shape. The search stops once count members are found, unless
+// count is zero in which case all of them are returned
+func (s *Storage) GeoSearch(key Key, shape GeoShape, count int) ([]GeoResult, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return []GeoResult{}, err
+	}
+	result := []GeoResult{}
+	for _, r := range shape.cells() {
+		for _, m := range z.rangeByBounds(r, false, 0, -1) {
+			p := geoDecode(m.Score)
+			if dist, ok := shape.contains(p); ok {
+				result = append(result, GeoResult{m.Member, m.Score, p, dist})
+				if len(result) == count {
+					return result, nil
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// parseGeoPoint function parses a longitude and a latitude, checking they
+// can be indexed
+func parseGeoPoint(lon_arg string, lat_arg string) (GeoPoint, error) {
+	lon, err := parseScore(lon_arg)
+	if err != nil {
+		return GeoPoint{}, err
+	}
+	lat, err := parseScore(lat_arg)
+	if err != nil {
+		return GeoPoint{}, err
+	}
+	if lon < geoLonMin || lon > geoLonMax || lat < geoLatMin || lat > geoLatMax {
+		return GeoPoint{}, errors.New(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", lon, lat))
+	}
+	return GeoPoint{lon, lat}, nil
+}
+
+// parseGeoUnit function returns the number of meters of a unit of distance
+func parseGeoUnit(arg string) (float64, error) {
+	unit, ok := geoUnits[strings.ToLower(arg)]
+	if !ok {
+		return 0, errors.New("ERR unsupported unit provided. please use M, KM, FT, MI")
+	}
+	return unit, nil
+}
+
+// formatGeoDistance function formats a distance the way redis replies it,
+// with four decimals
+func formatGeoDistance(dist float64) string {
+	return strconv.FormatFloat(dist, 'f', 4, 64)
+}
+
+// geoPointReply function converts a position into a reply
+func geoPointReply(p *GeoPoint) interface{} {
+	if p == nil {
+		return NullArray{}
+	}
+	return []interface{}{p.Lon, p.Lat}
+}
+
+// ProcessRespCommandGeoAdd function processes redis command GEOADD
+func (s *Server) ProcessRespCommandGeoAdd(c *Connection, commands []string) (interface{}, error) {
+	flags := ZAddFlags{}
+	i := 2
+options:
+	for ; i < len(commands); i++ {
+		switch strings.ToUpper(commands[i]) {
+		case "NX":
+			flags.NX = true
+		case "XX":
+			flags.XX = true
+		case "CH":
+			flags.CH = true
+		default:
+			break options
+		}
+	}
+	triplets := commands[i:]
+	if len(triplets) == 0 || len(triplets)%3 != 0 || (flags.NX && flags.XX) {
+		return nil, ErrSyntax
+	}
+	members := make([]string, len(triplets)/3)
+	points := make([]GeoPoint, len(members))
+	for j := range members {
+		p, err := parseGeoPoint(triplets[3*j], triplets[3*j+1])
+		if err != nil {
+			return nil, err
+		}
+		members[j], points[j] = triplets[3*j+2], p
+	}
+	return s.db.GeoAdd(Key(commands[1]), members, points, flags)
+}
+
+// ProcessRespCommandGeoPos function processes redis command GEOPOS
+func (s *Server) ProcessRespCommandGeoPos(c *Connection, commands []string) (interface{}, error) {
+	points, err := s.db.GeoPos(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(points))
+	for i, p := range points {
+		result[i] = geoPointReply(p)
+	}
+	return result, nil
+}
+
+// ProcessRespCommandGeoDist function processes redis command GEODIST
+func (s *Server) ProcessRespCommandGeoDist(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) > 5 {
+		return nil, ErrSyntax
+	}
+	unit := 1.0
+	if len(commands) == 5 {
+		val, err := parseGeoUnit(commands[4])
+		if err != nil {
+			return nil, err
+		}
+		unit = val
+	}
+	points, err := s.db.GeoPos(Key(commands[1]), commands[2:4])
+	if err != nil {
+		return nil, err
+	}
+	if points[0] == nil || points[1] == nil {
+		return nil, nil
+	}
+	return formatGeoDistance(geoDistance(*points[0], *points[1]) / unit), nil
+}
+
+// ProcessRespCommandGeoHash function processes redis command GEOHASH
+func (s *Server) ProcessRespCommandGeoHash(c *Connection, commands []string) (interface{}, error) {
+	points, err := s.db.GeoPos(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(points))
+	for i, p := range points {
+		if p != nil {
+			result[i] = geohashString(*p)
+		}
+	}
+	return result, nil
+}
+
+// geoSearchArgs struct holds the options of GEOSEARCH and GEOSEARCHSTORE
+// following the key. The center is either the position of from_member or
+// the one given, unit is the number of meters of the unit distances are
+// given and replied in
+type geoSearchArgs struct {
+	shape       GeoShape
+	from_member *string
+	from_lonlat bool
+	by_radius   bool
+	unit        float64
+	sort        string
+	count       int
+	any         bool
+	withcoord   bool
+	withdist    bool
+	withhash    bool
+	storedist   bool
+}
+
+// parseGeoSearchArgs function parses the options of GEOSEARCH, or those of
+// GEOSEARCHSTORE if store is set
+func parseGeoSearchArgs(name string, args []string, store bool) (geoSearchArgs, error) {
+	result := geoSearchArgs{}
+	exactly_one_from := errors.New(fmt.Sprintf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", name))
+	exactly_one_by := errors.New(fmt.Sprintf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", name))
+	for i := 0; i < len(args); i++ {
+		left := len(args) - i - 1
+		switch option := strings.ToUpper(args[i]); {
+		case option == "FROMMEMBER" && left >= 1:
+			if result.from_member != nil || result.from_lonlat {
+				return result, exactly_one_from
+			}
+			result.from_member = &args[i+1]
+			i++
+		case option == "FROMLONLAT" && left >= 2:
+			if result.from_member != nil || result.from_lonlat {
+				return result, exactly_one_from
+			}
+			p, err := parseGeoPoint(args[i+1], args[i+2])
+			if err != nil {
+				return result, err
+			}
+			result.shape.Center, result.from_lonlat = p, true
+			i += 2
+		case option == "BYRADIUS" && left >= 2:
+			if result.by_radius || result.shape.ByBox {
+				return result, exactly_one_by
+			}
+			radius, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || math.IsNaN(radius) || math.IsInf(radius, 0) {
+				return result, errors.New("ERR need numeric radius")
+			}
+			if radius < 0 {
+				return result, errors.New("ERR radius cannot be negative")
+			}
+			if result.unit, err = parseGeoUnit(args[i+2]); err != nil {
+				return result, err
+			}
+			result.shape.Radius, result.by_radius = radius*result.unit, true
+			i += 2
+		case option == "BYBOX" && left >= 3:
+			if result.by_radius || result.shape.ByBox {
+				return result, exactly_one_by
+			}
+			width, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || math.IsNaN(width) || math.IsInf(width, 0) {
+				return result, errors.New("ERR need numeric width")
+			}
+			height, err := strconv.ParseFloat(args[i+2], 64)
+			if err != nil || math.IsNaN(height) || math.IsInf(height, 0) {
+				return result, errors.New("ERR need numeric height")
+			}
+			if width < 0 || height < 0 {
+				return result, errors.New("ERR height or width cannot be negative")
+			}
+			if result.unit, err = parseGeoUnit(args[i+3]); err != nil {
+				return result, err
+			}
+			result.shape.ByBox = true
+			result.shape.Width, result.shape.Height = width*result.unit, height*result.unit
+			i += 3
+		case option == "ASC" || option == "DESC":
+			result.sort = option
+		case option == "COUNT" && left >= 1:
+			count, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return result, ErrNotInteger
+			}
+			if count <= 0 {
+				return result, errors.New("ERR COUNT must be > 0")
+			}
+			result.count = int(count)
+			i++
+			if i+1 < len(args) && strings.ToUpper(args[i+1]) == "ANY" {
+				result.any = true
+				i++
+			}
+		case option == "WITHCOORD" && !store:
+			result.withcoord = true
+		case option == "WITHDIST" && !store:
+			result.withdist = true
+		case option == "WITHHASH" && !store:
+			result.withhash = true
+		case option == "STOREDIST" && store:
+			result.storedist = true
+		default:
+			return result, ErrSyntax
+		}
+	}
+	if result.from_member == nil && !result.from_lonlat {
+		return result, exactly_one_from
+	}
+	if !result.by_radius && !result.shape.ByBox {
+		return result, exactly_one_by
+	}
+	return result, nil
+}
+
+// geoSearch function runs the search described by args over the
+// geospatial index stored at key, returning the members sorted and
+// limited as requested
+func (s *Server) geoSearch(key Key, args geoSearchArgs) ([]GeoResult, error) {
+	if args.from_member != nil {
+		points, err := s.db.GeoPos(key, []string{*args.from_member})
+		if err != nil {
+			return nil, err
+		}
+		if points[0] == nil {
+			if _, prs := s.db.lookup(key); !prs {
+				return []GeoResult{}, nil
+			}
+			return nil, errors.New("ERR could not decode requested zset member")
+		}
+		args.shape.Center = *points[0]
+	}
+	limit := 0
+	if args.any {
+		limit = args.count
+	}
+	result, err := s.db.GeoSearch(key, args.shape, limit)
+	if err != nil {
+		return nil, err
+	}
+	// like redis COUNT without ANY returns the closest members
+	if args.count > 0 && args.sort == "" && !args.any {
+		args.sort = "ASC"
+	}
+	switch args.sort {
+	case "ASC":
+		sort.SliceStable(result, func(i, j int) bool { return result[i].Dist < result[j].Dist })
+	case "DESC":
+		sort.SliceStable(result, func(i, j int) bool { return result[i].Dist > result[j].Dist })
+	}
+	if args.count > 0 && len(result) > args.count {
+		result = result[:args.count]
+	}
+	return result, nil
+}
+
+// ProcessRespCommandGeoSearch function processes redis command GEOSEARCH.
+// Each member is replied along with its distance, hash and position in
+// this order when WITHDIST, WITHHASH and WITHCOORD are given
+func (s *Server) ProcessRespCommandGeoSearch(c *Connection, commands []string) (interface{}, error) {
+	args, err := parseGeoSearchArgs(strings.ToLower(commands[0]), commands[2:], false)
+	if err != nil {
+		return nil, err
+	}
+	if args.any && args.count == 0 {
+		return nil, errors.New("ERR the ANY argument requires COUNT argument")
+	}
+	found, err := s.geoSearch(Key(commands[1]), args)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(found))
+	for i, r := range found {
+		if !args.withdist && !args.withhash && !args.withcoord {
+			result[i] = r.Member
+			continue
+		}
+		item := []interface{}{r.Member}
+		if args.withdist {
+			item = append(item, formatGeoDistance(r.Dist/args.unit))
+		}
+		if args.withhash {
+			item = append(item, int64(r.Score))
+		}
+		if args.withcoord {
+			item = append(item, geoPointReply(&r.Point))
+		}
+		result[i] = item
+	}
+	return result, nil
+}
+
+// ProcessRespCommandGeoSearchStore function processes redis command
+// GEOSEARCHSTORE. The members found are stored with their geohash as score
+// so that the destination is a geospatial index as well, or with their
+// distance if STOREDIST is given
+func (s *Server) ProcessRespCommandGeoSearchStore(c *Connection, commands []string) (interface{}, error) {
+	args, err := parseGeoSearchArgs(strings.ToLower(commands[0]), commands[3:], true)
+	if err != nil {
+		return nil, err
+	}
+	if args.any && args.count == 0 {
+		return nil, errors.New("ERR the ANY argument requires COUNT argument")
+	}
+	found, err := s.geoSearch(Key(commands[2]), args)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ZMember, len(found))
+	for i, r := range found {
+		members[i] = ZMember{r.Member, r.Score}
+		if args.storedist {
+			members[i].Score = r.Dist / args.unit
+		}
+	}
+	return s.db.ZStore(Key(commands[1]), members), nil
+}
@@ -0,0 +1,145 @@
+package microredis_test
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestGeoSearchStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	rng := rand.New(rand.NewSource(1))
+	for _, center := range []m.GeoPoint{{Lon: 2.35, Lat: 48.85}, {Lon: 179.9, Lat: 0}, {Lon: 0, Lat: 84.9}} {
+		members, points := []string{}, []m.GeoPoint{}
+		for i := 0; i < 2000; i++ {
+			lon := center.Lon + rng.Float64()*4 - 2
+			if lon > 180 {
+				lon -= 360
+			}
+			lat := math.Min(center.Lat+rng.Float64()*2-1, 85)
+			members = append(members, strconv.Itoa(i))
+			points = append(points, m.GeoPoint{Lon: lon, Lat: lat})
+		}
+		s.Del([]m.Key{"g"})
+		added, err := s.GeoAdd(m.Key("g"), members, points, m.ZAddFlags{})
+		assert.Nil(t, err)
+		assert.Equal(t, 2000, added)
+		stored, _ := s.GeoPos(m.Key("g"), members)
+
+		// the cells scanned find every member within the shape
+		for _, shape := range []m.GeoShape{
+			{Center: center, Radius: 3000},
+			{Center: center, Radius: 40000},
+			{Center: center, ByBox: true, Width: 20000, Height: 5000},
+			{Center: center, ByBox: true, Width: 300000, Height: 300000},
+		} {
+			expected := []string{}
+			for i, p := range stored {
+				lat_dist := math.Abs(p.Lat-center.Lat) * math.Pi / 180 * 6372797.560856
+				if (shape.ByBox && lat_dist <= shape.Height/2 && geoDist(m.GeoPoint{Lon: center.Lon, Lat: p.Lat}, *p) <= shape.Width/2) ||
+					(!shape.ByBox && geoDist(center, *p) <= shape.Radius) {
+					expected = append(expected, members[i])
+				}
+			}
+			found, err := s.GeoSearch(m.Key("g"), shape, 0)
+			assert.Nil(t, err)
+			names := []string{}
+			for _, r := range found {
+				names = append(names, r.Member)
+				assert.InDelta(t, geoDist(center, r.Point), r.Dist, 1e-6)
+			}
+			assert.ElementsMatch(t, expected, names)
+		}
+	}
+
+	found, _ := s.GeoSearch(m.Key("g"), m.GeoShape{Center: m.GeoPoint{Lon: 0, Lat: 84.9}, Radius: 100000}, 5)
+	assert.Equal(t, 5, len(found))
+	found, err := s.GeoSearch(m.Key("missing"), m.GeoShape{Radius: 40000}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []m.GeoResult{}, found)
+
+	points, err := s.GeoPos(m.Key("g"), []string{"missing"})
+	assert.Nil(t, err)
+	assert.Nil(t, points[0])
+}
+
+// geoDist function is the haversine distance redis computes
+func geoDist(a m.GeoPoint, b m.GeoPoint) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	u := math.Sin((lat2 - lat1) / 2)
+	v := math.Sin((b.Lon - a.Lon) * math.Pi / 360)
+	return 2 * 6372797.560856 * math.Asin(math.Sqrt(u*u+math.Cos(lat1)*math.Cos(lat2)*v*v))
+}
+
+func TestGeoCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"add and query", []step{
+			{args: []string{"GEOADD", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"}, reply: ":2\r\n"},
+			{args: []string{"GEOADD", "Sicily", "XX", "CH", "13.361389", "38.115556", "Palermo"}, reply: ":0\r\n"},
+			{args: []string{"GEOADD", "Sicily", "NX", "0", "0", "Palermo"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "Sicily"}, reply: "+zset\r\n"},
+			{args: []string{"ZSCORE", "Sicily", "Palermo"}, reply: "$21\r\n3.479099956230698e+15\r\n"},
+			{args: []string{"ZSCORE", "Sicily", "Catania"}, reply: "$21\r\n3.479447370796909e+15\r\n"},
+			{args: []string{"GEODIST", "Sicily", "Palermo", "Catania"}, reply: "$11\r\n166274.1516\r\n"},
+			{args: []string{"GEODIST", "Sicily", "Palermo", "Catania", "km"}, reply: "$8\r\n166.2742\r\n"},
+			{args: []string{"GEODIST", "Sicily", "Palermo", "Catania", "MI"}, reply: "$8\r\n103.3182\r\n"},
+			{args: []string{"GEODIST", "Sicily", "Palermo", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"GEOHASH", "Sicily", "Palermo", "Catania", "missing"}, reply: "*3\r\n$11\r\nsqc8b49rny0\r\n$11\r\nsqdtr74hyu0\r\n$-1\r\n"},
+			{args: []string{"GEOPOS", "Sicily", "Palermo", "missing"}, reply: "*2\r\n*2\r\n$18\r\n13.361389338970184\r\n$16\r\n38.1155563954963\r\n*-1\r\n"},
+			{args: []string{"GEOPOS", "missing", "Palermo"}, reply: "*1\r\n*-1\r\n"},
+		}},
+		{"search", []step{
+			{args: []string{"GEOADD", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"}, reply: ":2\r\n"},
+			{args: []string{"GEOADD", "Sicily", "12.758489", "38.788135", "edge1", "17.241510", "38.788135", "edge2"}, reply: ":2\r\n"},
+			{args: []string{"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC"}, reply: "*2\r\n$7\r\nCatania\r\n$7\r\nPalermo\r\n"},
+			{args: []string{"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "DESC", "WITHDIST"}, reply: "*2\r\n" +
+				"*2\r\n$7\r\nPalermo\r\n$8\r\n190.4424\r\n*2\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n"},
+			{args: []string{"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC", "WITHDIST"}, reply: "*4\r\n" +
+				"*2\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n*2\r\n$7\r\nPalermo\r\n$8\r\n190.4424\r\n" +
+				"*2\r\n$5\r\nedge2\r\n$8\r\n279.7403\r\n*2\r\n$5\r\nedge1\r\n$8\r\n279.7405\r\n"},
+			{args: []string{"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "100", "km", "WITHHASH", "WITHCOORD"}, reply: "*2\r\n" +
+				"*3\r\n$7\r\nPalermo\r\n:3479099956230698\r\n*2\r\n$18\r\n13.361389338970184\r\n$16\r\n38.1155563954963\r\n" +
+				"*3\r\n$5\r\nedge1\r\n:3479273021651468\r\n*2\r\n$17\r\n12.75848776102066\r\n$17\r\n38.78813451624225\r\n"},
+			{args: []string{"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "COUNT", "1"}, reply: "*1\r\n$7\r\nCatania\r\n"},
+			{args: []string{"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "COUNT", "2", "ANY"}, pattern: `^\*2\r\n`},
+			{args: []string{"GEOSEARCH", "Sicily", "FROMLONLAT", "0", "0", "BYRADIUS", "10", "m"}, reply: "*0\r\n"},
+			{args: []string{"GEOSEARCH", "missing", "FROMMEMBER", "x", "BYRADIUS", "10", "m"}, reply: "*0\r\n"},
+			{args: []string{"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km"}, reply: ":2\r\n"},
+			{args: []string{"GEODIST", "dst", "Palermo", "Catania", "km"}, reply: "$8\r\n166.2742\r\n"},
+			{args: []string{"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC", "COUNT", "1", "STOREDIST"}, reply: ":1\r\n"},
+			{args: []string{"ZRANGE", "dst", "0", "-1", "WITHSCORES"}, pattern: `^\*2\r\n\$7\r\nCatania\r\n\$\d+\r\n56\.441`},
+			{args: []string{"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "0", "0", "BYRADIUS", "10", "m"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "dst"}, reply: "+none\r\n"},
+		}},
+		{"errors", []step{
+			{args: []string{"GEOADD", "g", "181", "0", "a"}, reply: "-ERR invalid longitude,latitude pair 181.000000,0.000000\r\n"},
+			{args: []string{"GEOADD", "g", "0", "86", "a"}, reply: "-ERR invalid longitude,latitude pair 0.000000,86.000000\r\n"},
+			{args: []string{"GEOADD", "g", "0", "0", "a", "1"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"GEOADD", "g", "NX", "XX", "0", "0", "a"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"GEOADD", "g", "x", "0", "a"}, reply: "-ERR value is not a valid float\r\n"},
+			{args: []string{"GEOADD", "g", "0", "0", "a"}, reply: ":1\r\n"},
+			{args: []string{"GEODIST", "g", "a", "a", "yd"}, reply: "-ERR unsupported unit provided. please use M, KM, FT, MI\r\n"},
+			{args: []string{"GEOSEARCH", "g", "BYRADIUS", "1", "m", "ASC", "WITHDIST"}, reply: "-ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for geosearch\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "FROMLONLAT", "0", "0"}, reply: "-ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for geosearch\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "ASC", "WITHDIST", "WITHHASH"}, reply: "-ERR exactly one of BYRADIUS and BYBOX can be specified for geosearch\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "BYRADIUS", "-1", "m"}, reply: "-ERR radius cannot be negative\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "BYBOX", "1", "x", "m"}, reply: "-ERR need numeric height\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "BYBOX", "inf", "1", "m"}, reply: "-ERR need numeric width\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "BYBOX", "1", "nan", "m"}, reply: "-ERR need numeric height\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "BYRADIUS", "nan", "m"}, reply: "-ERR need numeric radius\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "BYRADIUS", "+inf", "m"}, reply: "-ERR need numeric radius\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "BYRADIUS", "1", "m", "COUNT", "0"}, reply: "-ERR COUNT must be > 0\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "a", "BYRADIUS", "1", "m", "ANY"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"GEOSEARCH", "g", "FROMMEMBER", "b", "BYRADIUS", "1", "m"}, reply: "-ERR could not decode requested zset member\r\n"},
+			{args: []string{"GEOSEARCHSTORE", "d", "g", "FROMMEMBER", "a", "BYRADIUS", "1", "m", "WITHDIST"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"SET", "str", "v"}, reply: "+OK\r\n"},
+			{args: []string{"GEOADD", "str", "0", "0", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"GEOSEARCH", "str", "FROMLONLAT", "0", "0", "BYRADIUS", "1", "m"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}
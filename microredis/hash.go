@@ -0,0 +1,413 @@
+package microredis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// maxRandCount is the highest number of fields HRANDFIELD and members
+// SRANDMEMBER reply with given a negative count. They may then repeat so
+// the reply is not bounded by the size of the key, it is bounded like the
+// number of arguments of a command by DefaultMaxMultiBulkLen
+const maxRandCount = DefaultMaxMultiBulkLen
+
+// errRandCountRange is returned for a count out of the range HRANDFIELD
+// and SRANDMEMBER accept
+var errRandCountRange = errors.New("ERR value is out of range")
+
+// Hash type denotes the hash datatype, a map of fields to their values
+type Hash map[string]string
+
+// getHash function returns the hash stored at key. If the key does not
+// exist nil is returned, unless create is set in which case a new empty
+// hash is stored at key. ErrWrongType is returned if the key holds another
+// datatype
+func (s *Storage) getHash(key Key, create bool) (Hash, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		if !create {
+			return nil, nil
+		}
+		result := Hash{}
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	result, ok := val.val.(Hash)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// HSet function sets the fields of the hash stored at key to the values
+// in pairs, creating the hash if it does not exist. If set_if_not_exists
+// is set fields which already exist are left as is. Returns the number of
+// fields which were added
+func (s *Storage) HSet(key Key, fields []string, vals []string, set_if_not_exists bool) (int, error) {
+	h, err := s.getHash(key, true)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for i, field := range fields {
+		if _, prs := h[field]; prs {
+			if set_if_not_exists {
+				continue
+			}
+		} else {
+			added++
+		}
+		h[field] = vals[i]
+	}
+	if len(h) == 0 {
+		// nothing was set on a newly created hash
+		delete(s.data, key)
+	} else if added > 0 || !set_if_not_exists {
+		s.keyModified(key)
+	}
+	return added, nil
+}
+
+// HGet function returns the value of field in the hash stored at key, nil
+// if the key or the field does not exist
+func (s *Storage) HGet(key Key, field string) (*string, error) {
+	h, err := s.getHash(key, false)
+	if h == nil {
+		return nil, err
+	}
+	if val, prs := h[field]; prs {
+		return &val, nil
+	}
+	return nil, nil
+}
+
+// HMGet function returns the values of all the fields in the same order,
+// nil is returned for fields which do not exist
+func (s *Storage) HMGet(key Key, fields []string) ([]*string, error) {
+	h, err := s.getHash(key, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*string, len(fields))
+	for i, field := range fields {
+		if val, prs := h[field]; prs {
+			result[i] = &val
+		}
+	}
+	return result, nil
+}
+
+// HGetAll function returns the hash stored at key, an empty hash if the
+// key does not exist. The returned hash must not be modified
+func (s *Storage) HGetAll(key Key) (Hash, error) {
+	h, err := s.getHash(key, false)
+	if h == nil {
+		return Hash{}, err
+	}
+	return h, nil
+}
+
+// HDel function removes the fields from the hash stored at key and returns
+// the number of fields removed. The key is deleted once the hash is empty
+func (s *Storage) HDel(key Key, fields []string) (int, error) {
+	h, err := s.getHash(key, false)
+	if h == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, field := range fields {
+		if _, prs := h[field]; prs {
+			delete(h, field)
+			removed++
+		}
+	}
+	if removed > 0 {
+		if len(h) == 0 {
+			delete(s.data, key)
+		}
+		s.keyModified(key)
+	}
+	return removed, nil
+}
+
+// HExists function returns whether field exists in the hash stored at key
+func (s *Storage) HExists(key Key, field string) (bool, error) {
+	val, err := s.HGet(key, field)
+	return val != nil, err
+}
+
+// HLen function returns the number of fields in the hash stored at key
+func (s *Storage) HLen(key Key) (int, error) {
+	h, err := s.getHash(key, false)
+	return len(h), err
+}
+
+// HIncrBy function increments the integer stored in field of the hash at
+// key by delta and returns the new value. A field which does not exist is
+// treated as 0
+func (s *Storage) HIncrBy(key Key, field string, delta int64) (int64, error) {
+	h, err := s.getHash(key, true)
+	if err != nil {
+		return 0, err
+	}
+	cur := int64(0)
+	if val, prs := h[field]; prs {
+		cur, err = strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, errors.New("ERR hash value is not an integer")
+		}
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return 0, errors.New("ERR increment or decrement would overflow")
+	}
+	cur += delta
+	h[field] = strconv.FormatInt(cur, 10)
+	s.keyModified(key)
+	return cur, nil
+}
+
+// HIncrByFloat function increments the floating point number stored in
+// field of the hash at key by delta and returns the new value. A field
+// which does not exist is treated as 0
+func (s *Storage) HIncrByFloat(key Key, field string, delta float64) (float64, error) {
+	h, err := s.getHash(key, true)
+	if err != nil {
+		return 0, err
+	}
+	cur := float64(0)
+	if val, prs := h[field]; prs {
+		cur, err = parseFloat(val)
+		if err != nil {
+			return 0, errors.New("ERR hash value is not a float")
+		}
+	}
+	cur += delta
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return 0, errors.New("ERR increment would produce NaN or Infinity")
+	}
+	h[field] = formatFloat(cur)
+	s.keyModified(key)
+	return cur, nil
+}
+
+// HRandField function returns random fields of the hash stored at key
+// along with their values. A positive count returns up to count distinct
+// fields while a negative one returns exactly -count fields which may
+// repeat, up to maxRandCount of them. nil is returned if the key does not
+// exist
+func (s *Storage) HRandField(key Key, count int64) ([]string, []string, error) {
+	if count < -maxRandCount {
+		return nil, nil, errRandCountRange
+	}
+	h, err := s.getHash(key, false)
+	if h == nil {
+		return nil, nil, err
+	}
+	all := make([]string, 0, len(h))
+	for field := range h {
+		all = append(all, field)
+	}
+	var fields []string
+	if count >= 0 {
+		if count > int64(len(all)) {
+			count = int64(len(all))
+		}
+		rand.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+		fields = all[:count]
+	} else {
+		fields = make([]string, -count)
+		for i := range fields {
+			fields[i] = all[rand.Intn(len(all))]
+		}
+	}
+	vals := make([]string, len(fields))
+	for i, field := range fields {
+		vals[i] = h[field]
+	}
+	return fields, vals, nil
+}
+
+// fieldValuePairs function splits the arguments of HSET into the fields
+// and their values
+func fieldValuePairs(commands []string) ([]string, []string, error) {
+	if len(commands)%2 != 0 {
+		return nil, nil, errors.New(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(commands[0])))
+	}
+	fields := make([]string, 0, (len(commands)-2)/2)
+	vals := make([]string, 0, (len(commands)-2)/2)
+	for i := 2; i < len(commands); i += 2 {
+		fields = append(fields, commands[i])
+		vals = append(vals, commands[i+1])
+	}
+	return fields, vals, nil
+}
+
+// ProcessRespCommandHSet function processes redis command HSET
+func (s *Server) ProcessRespCommandHSet(c *Connection, commands []string) (interface{}, error) {
+	fields, vals, err := fieldValuePairs(commands)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.HSet(Key(commands[1]), fields, vals, false)
+}
+
+// ProcessRespCommandHSetNX function processes redis command HSETNX
+func (s *Server) ProcessRespCommandHSetNX(c *Connection, commands []string) (interface{}, error) {
+	return s.db.HSet(Key(commands[1]), commands[2:3], commands[3:4], true)
+}
+
+// ProcessRespCommandHGet function processes redis command HGET
+func (s *Server) ProcessRespCommandHGet(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.HGet(Key(commands[1]), commands[2])
+	if result == nil {
+		return nil, err
+	}
+	return *result, nil
+}
+
+// ProcessRespCommandHMGet function processes redis command HMGET
+func (s *Server) ProcessRespCommandHMGet(c *Connection, commands []string) (interface{}, error) {
+	vals, err := s.db.HMGet(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(vals))
+	for i, val := range vals {
+		if val != nil {
+			result[i] = *val
+		}
+	}
+	return result, nil
+}
+
+// ProcessRespCommandHGetAll function processes redis command HGETALL
+func (s *Server) ProcessRespCommandHGetAll(c *Connection, commands []string) (interface{}, error) {
+	h, err := s.db.HGetAll(Key(commands[1]))
+	if err != nil {
+		return nil, err
+	}
+	result := make(MapReply, 0, 2*len(h))
+	for field, val := range h {
+		result = append(result, field, val)
+	}
+	return result, nil
+}
+
+// ProcessRespCommandHKeys function processes redis command HKEYS
+func (s *Server) ProcessRespCommandHKeys(c *Connection, commands []string) (interface{}, error) {
+	h, err := s.db.HGetAll(Key(commands[1]))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(h))
+	for field := range h {
+		result = append(result, field)
+	}
+	return result, nil
+}
+
+// ProcessRespCommandHVals function processes redis command HVALS
+func (s *Server) ProcessRespCommandHVals(c *Connection, commands []string) (interface{}, error) {
+	h, err := s.db.HGetAll(Key(commands[1]))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(h))
+	for _, val := range h {
+		result = append(result, val)
+	}
+	return result, nil
+}
+
+// ProcessRespCommandHDel function processes redis command HDEL
+func (s *Server) ProcessRespCommandHDel(c *Connection, commands []string) (interface{}, error) {
+	return s.db.HDel(Key(commands[1]), commands[2:])
+}
+
+// ProcessRespCommandHExists function processes redis command HEXISTS
+func (s *Server) ProcessRespCommandHExists(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.HExists(Key(commands[1]), commands[2])
+	if err != nil {
+		return nil, err
+	}
+	if result {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// ProcessRespCommandHLen function processes redis command HLEN
+func (s *Server) ProcessRespCommandHLen(c *Connection, commands []string) (interface{}, error) {
+	return s.db.HLen(Key(commands[1]))
+}
+
+// ProcessRespCommandHIncrBy function processes redis command HINCRBY
+func (s *Server) ProcessRespCommandHIncrBy(c *Connection, commands []string) (interface{}, error) {
+	delta, err := strconv.ParseInt(commands[3], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	return s.db.HIncrBy(Key(commands[1]), commands[2], delta)
+}
+
+// ProcessRespCommandHIncrByFloat function processes redis command
+// HINCRBYFLOAT. The new value is replied as a bulk string the same way it
+// is stored
+func (s *Server) ProcessRespCommandHIncrByFloat(c *Connection, commands []string) (interface{}, error) {
+	delta, err := parseFloat(commands[3])
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.HIncrByFloat(Key(commands[1]), commands[2], delta)
+	if err != nil {
+		return nil, err
+	}
+	return formatFloat(result), nil
+}
+
+// ProcessRespCommandHRandField function processes redis command HRANDFIELD
+// Without count a single field is replied. With WITHVALUES each field is
+// followed by its value, in RESP3 every field and value are replied as a
+// pair of their own
+func (s *Server) ProcessRespCommandHRandField(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) > 4 || (len(commands) == 4 && strings.ToUpper(commands[3]) != "WITHVALUES") {
+		return nil, ErrSyntax
+	}
+	count := int64(1)
+	if len(commands) >= 3 {
+		val, err := strconv.ParseInt(commands[2], 10, 64)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		count = val
+	}
+	fields, vals, err := s.db.HRandField(Key(commands[1]), count)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 2 {
+		if len(fields) == 0 {
+			return nil, nil
+		}
+		return fields[0], nil
+	}
+	if len(commands) == 3 {
+		if fields == nil {
+			return []string{}, nil
+		}
+		return fields, nil
+	}
+	result := []interface{}{}
+	for i, field := range fields {
+		if c.proto >= 3 {
+			result = append(result, []string{field, vals[i]})
+		} else {
+			result = append(result, field, vals[i])
+		}
+	}
+	return result, nil
+}
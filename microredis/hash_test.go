@@ -0,0 +1,136 @@
+package microredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestHashStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	added, err := s.HSet(m.Key("h"), []string{"a", "b"}, []string{"1", "2"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added)
+	added, _ = s.HSet(m.Key("h"), []string{"b", "c"}, []string{"3", "4"}, false)
+	assert.Equal(t, 1, added)
+	added, _ = s.HSet(m.Key("h"), []string{"a"}, []string{"5"}, true)
+	assert.Equal(t, 0, added)
+	assert.Equal(t, "hash", s.Type(m.Key("h")))
+
+	h, err := s.HGetAll(m.Key("h"))
+	assert.NoError(t, err)
+	assert.Equal(t, m.Hash{"a": "1", "b": "3", "c": "4"}, h)
+
+	// HSETNX on a missing key does not leave an empty hash behind
+	added, _ = s.HSet(m.Key("empty"), []string{}, []string{}, true)
+	assert.Equal(t, 0, added)
+	assert.Equal(t, "none", s.Type(m.Key("empty")))
+
+	removed, err := s.HDel(m.Key("h"), []string{"a", "b", "c", "d"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	assert.Equal(t, "none", s.Type(m.Key("h")))
+
+	val, err := s.HIncrByFloat(m.Key("f"), "x", 1e308)
+	assert.NoError(t, err)
+	assert.Equal(t, 1e308, val)
+	_, err = s.HIncrByFloat(m.Key("f"), "x", 1e308)
+	assert.EqualError(t, err, "ERR increment would produce NaN or Infinity")
+
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, err = s.HSet(m.Key("str"), []string{"a"}, []string{"1"}, false)
+	assert.Equal(t, m.ErrWrongType, err)
+	_, err = s.HLen(m.Key("str"))
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestHRandField(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	s.HSet(m.Key("h"), []string{"a", "b", "c"}, []string{"1", "2", "3"}, false)
+
+	fields, vals, err := s.HRandField(m.Key("h"), 2)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 2)
+	assert.NotEqual(t, fields[0], fields[1])
+	for i, field := range fields {
+		assert.Equal(t, map[string]string{"a": "1", "b": "2", "c": "3"}[field], vals[i])
+	}
+
+	fields, _, _ = s.HRandField(m.Key("h"), 10)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, fields)
+
+	// negative counts may repeat fields
+	fields, _, _ = s.HRandField(m.Key("h"), -10)
+	assert.Len(t, fields, 10)
+	for _, field := range fields {
+		assert.Contains(t, []string{"a", "b", "c"}, field)
+	}
+
+	fields, _, err = s.HRandField(m.Key("missing"), 5)
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+}
+
+func TestHashCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"set and get", []step{
+			{args: []string{"HSET", "h", "a", "1", "b", "2"}, reply: ":2\r\n"},
+			{args: []string{"HSET", "h", "a", "3"}, reply: ":0\r\n"},
+			{args: []string{"HSET", "h", "a", "3", "b"}, reply: "-ERR wrong number of arguments for 'hset' command\r\n"},
+			{args: []string{"HSETNX", "h", "a", "4"}, reply: ":0\r\n"},
+			{args: []string{"HSETNX", "h", "c", "4"}, reply: ":1\r\n"},
+			{args: []string{"HGET", "h", "a"}, reply: "$1\r\n3\r\n"},
+			{args: []string{"HGET", "h", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"HMGET", "h", "a", "missing", "c"}, reply: "*3\r\n$1\r\n3\r\n$-1\r\n$1\r\n4\r\n"},
+			{args: []string{"HMGET", "missing", "a"}, reply: "*1\r\n$-1\r\n"},
+			{args: []string{"HLEN", "h"}, reply: ":3\r\n"},
+			{args: []string{"HEXISTS", "h", "b"}, reply: ":1\r\n"},
+			{args: []string{"HEXISTS", "h", "z"}, reply: ":0\r\n"},
+			{args: []string{"HGETALL", "missing"}, reply: "*0\r\n"},
+			{args: []string{"HKEYS", "missing"}, reply: "*0\r\n"},
+			{args: []string{"HDEL", "h", "a", "z"}, reply: ":1\r\n"},
+			{args: []string{"HDEL", "h", "b", "c"}, reply: ":2\r\n"},
+			{args: []string{"TYPE", "h"}, reply: "+none\r\n"},
+		}},
+		{"single field replies", []step{
+			{args: []string{"HSET", "h", "a", "1"}, reply: ":1\r\n"},
+			{args: []string{"HGETALL", "h"}, reply: "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+			{args: []string{"HKEYS", "h"}, reply: "*1\r\n$1\r\na\r\n"},
+			{args: []string{"HVALS", "h"}, reply: "*1\r\n$1\r\n1\r\n"},
+			{args: []string{"HRANDFIELD", "h"}, reply: "$1\r\na\r\n"},
+			{args: []string{"HRANDFIELD", "h", "-2"}, reply: "*2\r\n$1\r\na\r\n$1\r\na\r\n"},
+			{args: []string{"HRANDFIELD", "h", "5", "WITHVALUES"}, reply: "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+			{args: []string{"HRANDFIELD", "h", "5", "VALUES"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"HRANDFIELD", "h", "-1048577"}, reply: "-ERR value is out of range\r\n"},
+			{args: []string{"HRANDFIELD", "h", "-9223372036854775808"}, reply: "-ERR value is out of range\r\n"},
+			{args: []string{"HRANDFIELD", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"HRANDFIELD", "missing", "2"}, reply: "*0\r\n"},
+			{args: []string{"HELLO", "3"}, pattern: `^%7`},
+			{args: []string{"HGETALL", "h"}, reply: "%1\r\n$1\r\na\r\n$1\r\n1\r\n"},
+			{args: []string{"HRANDFIELD", "h", "1", "withvalues"}, reply: "*1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		}},
+		{"counters", []step{
+			{args: []string{"HINCRBY", "h", "n", "5"}, reply: ":5\r\n"},
+			{args: []string{"HINCRBY", "h", "n", "-7"}, reply: ":-2\r\n"},
+			{args: []string{"HINCRBY", "h", "n", "x"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"HINCRBY", "h", "n", "9223372036854775807"}, reply: ":9223372036854775805\r\n"},
+			{args: []string{"HINCRBY", "h", "n", "5"}, reply: "-ERR increment or decrement would overflow\r\n"},
+			{args: []string{"HINCRBYFLOAT", "h", "f", "10.5"}, reply: "$4\r\n10.5\r\n"},
+			{args: []string{"HINCRBYFLOAT", "h", "f", "0.1"}, reply: "$4\r\n10.6\r\n"},
+			{args: []string{"HSET", "h", "s", "abc"}, reply: ":1\r\n"},
+			{args: []string{"HINCRBY", "h", "s", "1"}, reply: "-ERR hash value is not an integer\r\n"},
+			{args: []string{"HINCRBYFLOAT", "h", "s", "1"}, reply: "-ERR hash value is not a float\r\n"},
+		}},
+		{"wrongtype", []step{
+			{args: []string{"SET", "s", "v"}, reply: "+OK\r\n"},
+			{args: []string{"HSET", "s", "a", "1"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"HGETALL", "s"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"HSET", "h", "a", "1"}, reply: ":1\r\n"},
+			{args: []string{"GET", "h"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"TYPE", "h"}, reply: "+hash\r\n"},
+		}},
+	})
+}
@@ -0,0 +1,355 @@
+package microredis
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// hllP is the number of bits of the hash used to pick a register, the
+	// 2^14 registers give a standard error of 1.04/sqrt(2^14) = 0.81%
+	hllP = 14
+	// hllRegisters is the number of registers of a HyperLogLog
+	hllRegisters = 1 << hllP
+	// hllQ is the number of bits of the hash left to count zeros in
+	hllQ = 64 - hllP
+	// hllSparseMaxCount is the highest count a sparse register can hold,
+	// as the VAL opcode of the redis sparse encoding
+	hllSparseMaxCount = 32
+	// hllSparseMaxRegisters is the number of non-zero registers above which
+	// a HyperLogLog is converted to the dense representation
+	hllSparseMaxRegisters = 750
+)
+
+// hllRegister struct denotes a non-zero register of a sparse HyperLogLog
+type hllRegister struct {
+	index uint16
+	count uint8
+}
+
+// HyperLogLog struct denotes the HyperLogLog datatype which estimates the
+// number of unique elements added to it using a fixed amount of memory.
+// Like in redis it starts sparse, holding only its non-zero registers
+// sorted by index, and turns dense, holding every register, once it has
+// too many of them or one of them gets a count too high for the sparse
+// representation. card caches the last estimate until a register changes
+type HyperLogLog struct {
+	sparse []hllRegister
+	dense  []uint8
+	card   int64
+	cached bool
+}
+
+// NewHyperLogLog function creates and initializes an empty sparse
+// HyperLogLog
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{sparse: []hllRegister{}, cached: true}
+}
+
+// Encoding function returns the representation of the HyperLogLog, sparse
+// or dense
+func (h *HyperLogLog) Encoding() string {
+	if h.dense != nil {
+		return "dense"
+	}
+	return "sparse"
+}
+
+// murmurHash64A function is the MurmurHash2 64 bit variant redis hashes
+// HyperLogLog elements with
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(data)) * m)
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * uint(i))
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllPosition function returns the register element falls in and the
+// count to store in it, the length of the run of zeros of the rest of its
+// hash plus one
+func hllPosition(element string) (uint16, uint8) {
+	hash := murmurHash64A([]byte(element), 0xadc83b19)
+	index := uint16(hash & (hllRegisters - 1))
+	// the sentinel bit makes sure the count is at most hllQ+1
+	hash = hash>>hllP | 1<<hllQ
+	return index, uint8(bits.TrailingZeros64(hash) + 1)
+}
+
+// get function returns the count of register index
+func (h *HyperLogLog) get(index uint16) uint8 {
+	if h.dense != nil {
+		return h.dense[index]
+	}
+	i := sort.Search(len(h.sparse), func(i int) bool { return h.sparse[i].index >= index })
+	if i < len(h.sparse) && h.sparse[i].index == index {
+		return h.sparse[i].count
+	}
+	return 0
+}
+
+// set function raises register index to count, it returns false if the
+// register already held a count as high
+func (h *HyperLogLog) set(index uint16, count uint8) bool {
+	if h.dense != nil {
+		if h.dense[index] >= count {
+			return false
+		}
+		h.dense[index] = count
+		h.cached = false
+		return true
+	}
+	i := sort.Search(len(h.sparse), func(i int) bool { return h.sparse[i].index >= index })
+	if i < len(h.sparse) && h.sparse[i].index == index {
+		if h.sparse[i].count >= count {
+			return false
+		}
+		h.sparse[i].count = count
+	} else {
+		h.sparse = append(h.sparse, hllRegister{})
+		copy(h.sparse[i+1:], h.sparse[i:])
+		h.sparse[i] = hllRegister{index, count}
+	}
+	h.cached = false
+	if count > hllSparseMaxCount || len(h.sparse) > hllSparseMaxRegisters {
+		h.toDense()
+	}
+	return true
+}
+
+// toDense function converts the HyperLogLog to the dense representation
+func (h *HyperLogLog) toDense() {
+	h.dense = make([]uint8, hllRegisters)
+	for _, reg := range h.sparse {
+		h.dense[reg.index] = reg.count
+	}
+	h.sparse = nil
+}
+
+// Add function adds element to the HyperLogLog, it returns true if a
+// register was altered and so the estimate may have changed
+func (h *HyperLogLog) Add(element string) bool {
+	return h.set(hllPosition(element))
+}
+
+// Merge function merges other into the HyperLogLog, which afterwards
+// estimates the cardinality of the union of both. It returns true if a
+// register was altered
+func (h *HyperLogLog) Merge(other *HyperLogLog) bool {
+	altered := false
+	if other.dense != nil {
+		for i, count := range other.dense {
+			if count > 0 && h.set(uint16(i), count) {
+				altered = true
+			}
+		}
+		return altered
+	}
+	for _, reg := range other.sparse {
+		if h.set(reg.index, reg.count) {
+			altered = true
+		}
+	}
+	return altered
+}
+
+// hllSigma function is the sigma function of the estimator of
+// "New cardinality estimation algorithms for HyperLogLog sketches" by
+// Otmar Ertl, which redis uses
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+// hllTau function is the tau function of the estimator by Otmar Ertl
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+// Count function returns the estimated number of unique elements added to
+// the HyperLogLog
+func (h *HyperLogLog) Count() int64 {
+	if h.cached {
+		return h.card
+	}
+	histogram := [hllQ + 2]int{}
+	if h.dense != nil {
+		for _, count := range h.dense {
+			histogram[count]++
+		}
+	} else {
+		histogram[0] = hllRegisters - len(h.sparse)
+		for _, reg := range h.sparse {
+			histogram[reg.count]++
+		}
+	}
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	h.card = int64(math.Round(0.5 / math.Ln2 * m * m / z))
+	h.cached = true
+	return h.card
+}
+
+// getHyperLogLog function returns the HyperLogLog stored at key. If the key
+// does not exist nil is returned, unless create is set in which case a new
+// empty HyperLogLog is stored at key. ErrWrongType is returned if the key
+// holds another datatype
+func (s *Storage) getHyperLogLog(key Key, create bool) (*HyperLogLog, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		if !create {
+			return nil, nil
+		}
+		result := NewHyperLogLog()
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	result, ok := val.val.(*HyperLogLog)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// PFAdd function adds the elements to the HyperLogLog stored at key,
+// creating it if it does not exist. Returns true if the key was created or
+// the estimate may have changed
+func (s *Storage) PFAdd(key Key, elements []string) (bool, error) {
+	_, prs := s.lookup(key)
+	h, err := s.getHyperLogLog(key, true)
+	if err != nil {
+		return false, err
+	}
+	altered := !prs
+	for _, element := range elements {
+		if h.Add(element) {
+			altered = true
+		}
+	}
+	if altered {
+		s.keyModified(key)
+	}
+	return altered, nil
+}
+
+// PFCount function returns the estimated number of unique elements added
+// to the HyperLogLogs stored at keys, the cardinality of their union if
+// there are several of them. A key which does not exist counts as empty
+func (s *Storage) PFCount(keys []Key) (int64, error) {
+	if len(keys) == 1 {
+		h, err := s.getHyperLogLog(keys[0], false)
+		if h == nil {
+			return 0, err
+		}
+		return h.Count(), nil
+	}
+	union := NewHyperLogLog()
+	for _, key := range keys {
+		h, err := s.getHyperLogLog(key, false)
+		if err != nil {
+			return 0, err
+		}
+		if h != nil {
+			union.Merge(h)
+		}
+	}
+	return union.Count(), nil
+}
+
+// PFMerge function merges the HyperLogLogs stored at keys into the one
+// stored at dest, creating it if it does not exist
+func (s *Storage) PFMerge(dest Key, keys []Key) error {
+	sources := make([]*HyperLogLog, 0, len(keys))
+	for _, key := range keys {
+		h, err := s.getHyperLogLog(key, false)
+		if err != nil {
+			return err
+		}
+		if h != nil {
+			sources = append(sources, h)
+		}
+	}
+	h, err := s.getHyperLogLog(dest, true)
+	if err != nil {
+		return err
+	}
+	for _, source := range sources {
+		if source != h {
+			h.Merge(source)
+		}
+	}
+	s.keyModified(dest)
+	return nil
+}
+
+// ProcessRespCommandPFAdd function processes redis command PFADD
+func (s *Server) ProcessRespCommandPFAdd(c *Connection, commands []string) (interface{}, error) {
+	altered, err := s.db.PFAdd(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	if altered {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// ProcessRespCommandPFCount function processes redis command PFCOUNT
+func (s *Server) ProcessRespCommandPFCount(c *Connection, commands []string) (interface{}, error) {
+	return s.db.PFCount(toKeys(commands[1:]))
+}
+
+// ProcessRespCommandPFMerge function processes redis command PFMERGE
+func (s *Server) ProcessRespCommandPFMerge(c *Connection, commands []string) (interface{}, error) {
+	if err := s.db.PFMerge(Key(commands[1]), toKeys(commands[2:])); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
@@ -0,0 +1,133 @@
+package microredis_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	h := m.NewHyperLogLog()
+	assert.Equal(t, int64(0), h.Count())
+	assert.True(t, h.Add("a"))
+	assert.False(t, h.Add("a"))
+	assert.Equal(t, int64(1), h.Count())
+	assert.Equal(t, "sparse", h.Encoding())
+
+	// the estimate stays within a few standard errors of the cardinality
+	for _, card := range []int{100, 1000, 10000, 100000} {
+		h = m.NewHyperLogLog()
+		for i := 0; i < card; i++ {
+			h.Add("element:" + strconv.Itoa(i))
+		}
+		assert.InEpsilon(t, card, h.Count(), 0.03, "cardinality %d", card)
+	}
+	assert.Equal(t, "dense", h.Encoding())
+
+	// elements added again don't change the estimate
+	count := h.Count()
+	for i := 0; i < 1000; i++ {
+		assert.False(t, h.Add("element:"+strconv.Itoa(i)))
+	}
+	assert.Equal(t, count, h.Count())
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	h1, h2 := m.NewHyperLogLog(), m.NewHyperLogLog()
+	for i := 0; i < 5000; i++ {
+		h1.Add(strconv.Itoa(i))
+		h2.Add(strconv.Itoa(i + 2500))
+	}
+	assert.True(t, h1.Merge(h2))
+	assert.False(t, h1.Merge(h2))
+	assert.InEpsilon(t, 7500, h1.Count(), 0.03)
+
+	// a sparse HyperLogLog merges into a dense one and the other way round
+	small := m.NewHyperLogLog()
+	small.Add("x")
+	assert.Equal(t, "sparse", small.Encoding())
+	h1.Merge(small)
+	assert.InEpsilon(t, 7501, h1.Count(), 0.03)
+	small.Merge(h1)
+	assert.Equal(t, "dense", small.Encoding())
+	assert.Equal(t, h1.Count(), small.Count())
+}
+
+func TestHyperLogLogStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	altered, err := s.PFAdd(m.Key("h"), []string{})
+	assert.Nil(t, err)
+	assert.True(t, altered)
+	altered, _ = s.PFAdd(m.Key("h"), []string{})
+	assert.False(t, altered)
+	altered, _ = s.PFAdd(m.Key("h"), []string{"a", "b", "c"})
+	assert.True(t, altered)
+	altered, _ = s.PFAdd(m.Key("h"), []string{"a"})
+	assert.False(t, altered)
+	s.PFAdd(m.Key("h2"), []string{"c", "d"})
+	assert.Equal(t, "hyperloglog", s.Type(m.Key("h")))
+
+	count, err := s.PFCount([]m.Key{"h"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	count, _ = s.PFCount([]m.Key{"h", "h2", "missing"})
+	assert.Equal(t, int64(4), count)
+	count, _ = s.PFCount([]m.Key{"missing"})
+	assert.Equal(t, int64(0), count)
+
+	assert.Nil(t, s.PFMerge(m.Key("dst"), []m.Key{"h", "h2", "missing"}))
+	count, _ = s.PFCount([]m.Key{"dst"})
+	assert.Equal(t, int64(4), count)
+	assert.Nil(t, s.PFMerge(m.Key("h2"), []m.Key{"h2", "h"}))
+	count, _ = s.PFCount([]m.Key{"h2"})
+	assert.Equal(t, int64(4), count)
+
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, err = s.PFAdd(m.Key("str"), []string{"a"})
+	assert.Equal(t, m.ErrWrongType, err)
+	_, err = s.PFCount([]m.Key{"h", "str"})
+	assert.Equal(t, m.ErrWrongType, err)
+	assert.Equal(t, m.ErrWrongType, s.PFMerge(m.Key("str"), []m.Key{"h"}))
+	assert.Equal(t, m.ErrWrongType, s.PFMerge(m.Key("h"), []m.Key{"str"}))
+}
+
+func TestHyperLogLogCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"add and count", []step{
+			{args: []string{"PFADD", "h", "a", "b", "c", "d", "e", "f", "g"}, reply: ":1\r\n"},
+			{args: []string{"PFADD", "h", "a", "b"}, reply: ":0\r\n"},
+			{args: []string{"PFCOUNT", "h"}, reply: ":7\r\n"},
+			{args: []string{"PFADD", "h2", "g", "h", "i"}, reply: ":1\r\n"},
+			{args: []string{"PFCOUNT", "h", "h2"}, reply: ":9\r\n"},
+			{args: []string{"PFCOUNT", "missing"}, reply: ":0\r\n"},
+			{args: []string{"PFADD", "empty"}, reply: ":1\r\n"},
+			{args: []string{"PFADD", "empty"}, reply: ":0\r\n"},
+			{args: []string{"PFCOUNT", "empty"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "h"}, reply: "+hyperloglog\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "h"}, reply: "$6\r\nsparse\r\n"},
+		}},
+		{"merge", []step{
+			{args: []string{"PFADD", "h1", "a", "b"}, reply: ":1\r\n"},
+			{args: []string{"PFADD", "h2", "b", "c"}, reply: ":1\r\n"},
+			{args: []string{"PFMERGE", "dst", "h1", "h2"}, reply: "+OK\r\n"},
+			{args: []string{"PFCOUNT", "dst"}, reply: ":3\r\n"},
+			{args: []string{"PFMERGE", "h1", "h2"}, reply: "+OK\r\n"},
+			{args: []string{"PFCOUNT", "h1"}, reply: ":3\r\n"},
+			{args: []string{"PFMERGE", "new"}, reply: "+OK\r\n"},
+			{args: []string{"TYPE", "new"}, reply: "+hyperloglog\r\n"},
+		}},
+		{"wrong type", []step{
+			{args: []string{"PFADD", "h", "a"}, reply: ":1\r\n"},
+			{args: []string{"GET", "h"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"APPEND", "h", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"SET", "str", "v"}, reply: "+OK\r\n"},
+			{args: []string{"PFADD", "str", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"PFCOUNT", "h", "str"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"PFMERGE", "h", "str"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"PFCOUNT"}, reply: "-ERR wrong number of arguments for 'pfcount' command\r\n"},
+		}},
+	})
+}
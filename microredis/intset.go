@@ -0,0 +1,60 @@
+package microredis
+
+import (
+	"sort"
+	"strconv"
+)
+
+// IntSet type denotes a compact set of integers, similar to the intset
+// encoding of redis. The integers are kept sorted in a slice so lookups
+// are a binary search and there is no per member overhead
+type IntSet []int64
+
+// find function returns the position of v in the set, or the position it
+// would be inserted at if it is not a member, along with whether v is a
+// member
+func (is IntSet) find(v int64) (int, bool) {
+	i := sort.Search(len(is), func(i int) bool { return is[i] >= v })
+	return i, i < len(is) && is[i] == v
+}
+
+// Contains function returns whether v is a member of the set
+func (is IntSet) Contains(v int64) bool {
+	_, found := is.find(v)
+	return found
+}
+
+// Add function adds v to the set, it returns false if v was already
+// a member
+func (is *IntSet) Add(v int64) bool {
+	i, found := is.find(v)
+	if found {
+		return false
+	}
+	*is = append(*is, 0)
+	copy((*is)[i+1:], (*is)[i:])
+	(*is)[i] = v
+	return true
+}
+
+// Remove function removes v from the set, it returns false if v was not
+// a member
+func (is *IntSet) Remove(v int64) bool {
+	i, found := is.find(v)
+	if !found {
+		return false
+	}
+	*is = append((*is)[:i], (*is)[i+1:]...)
+	return true
+}
+
+// parseCanonicalInt function parses s as an integer only if s is the
+// canonical representation of the integer, i.e. the integer can be stored
+// instead of s and be formatted back into the same s when read
+func parseCanonicalInt(s string) (int64, bool) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(v, 10) != s {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,904 @@
+package microredis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Documents are held as trees of nil, bool, int64, float64, string,
+// *jsonArray and *jsonObject values. Integers and floats are kept apart
+// like RedisJSON does so that JSON.TYPE and JSON.NUMINCRBY can tell them
+// apart, and objects keep the order their keys were added in
+
+// errJSONNoKey is returned by the commands modifying a document which
+// does not exist
+var errJSONNoKey = errors.New("ERR could not perform this operation on a key that doesn't exist")
+
+// jsonArray struct denotes a JSON array, it is referenced by pointer so that
+// it can be modified in place wherever it sits in the document
+type jsonArray struct {
+	elems []interface{}
+}
+
+// jsonObject struct denotes a JSON object, keys holds the keys in the order
+// they were added
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{keys: []string{}, values: map[string]interface{}{}}
+}
+
+// set function sets the value of key, a new key is added last
+func (o *jsonObject) set(key string, val interface{}) {
+	if _, prs := o.values[key]; !prs {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = val
+}
+
+// remove function removes key from the object
+func (o *jsonObject) remove(key string) {
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// JSON struct denotes the JSON document datatype
+type JSON struct {
+	root interface{}
+}
+
+// ParseJSON function parses a JSON document
+func ParseJSON(text string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	result, err := parseJSONValue(dec)
+	if err == nil {
+		if _, err = dec.Token(); err == io.EOF {
+			return result, nil
+		}
+		err = errors.New("trailing characters")
+	}
+	return nil, errors.New(fmt.Sprintf("ERR invalid JSON document: %s", err))
+}
+
+// parseJSONValue function parses the next value from dec
+func parseJSONValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch v := token.(type) {
+	case json.Delim:
+		if v == '[' {
+			arr := &jsonArray{elems: []interface{}{}}
+			for dec.More() {
+				elem, err := parseJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr.elems = append(arr.elems, elem)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		obj := newJSONObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), val)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	default:
+		return v, nil
+	}
+}
+
+// jsonCopy function returns a deep copy of val
+func jsonCopy(val interface{}) interface{} {
+	switch v := val.(type) {
+	case *jsonArray:
+		result := &jsonArray{elems: make([]interface{}, len(v.elems))}
+		for i, elem := range v.elems {
+			result.elems[i] = jsonCopy(elem)
+		}
+		return result
+	case *jsonObject:
+		result := newJSONObject()
+		for _, key := range v.keys {
+			result.set(key, jsonCopy(v.values[key]))
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// jsonTypeName function returns the name of the type of val the way
+// JSON.TYPE reports it
+func jsonTypeName(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *jsonArray:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonFormat struct holds the INDENT, NEWLINE and SPACE options of JSON.GET,
+// by default documents are serialized without any whitespace
+type jsonFormat struct {
+	indent  string
+	newline string
+	space   string
+}
+
+// SerializeJSON function serializes val compactly
+func SerializeJSON(val interface{}) string {
+	b := &strings.Builder{}
+	writeJSON(b, val, jsonFormat{}, 0)
+	return b.String()
+}
+
+// writeJSON function writes the serialization of val following format into
+// b, where level is the depth of val in the document
+func writeJSON(b *strings.Builder, val interface{}, format jsonFormat, level int) {
+	switch v := val.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			// keep floats which happen to be whole numbers floats
+			s += ".0"
+		}
+		b.WriteString(s)
+	case string:
+		writeJSONString(b, v)
+	case *jsonArray:
+		if len(v.elems) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteString("[")
+		for i, elem := range v.elems {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(format.newline + strings.Repeat(format.indent, level+1))
+			writeJSON(b, elem, format, level+1)
+		}
+		b.WriteString(format.newline + strings.Repeat(format.indent, level) + "]")
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteString("{")
+		for i, key := range v.keys {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(format.newline + strings.Repeat(format.indent, level+1))
+			writeJSONString(b, key)
+			b.WriteString(":" + format.space)
+			writeJSON(b, v.values[key], format, level+1)
+		}
+		b.WriteString(format.newline + strings.Repeat(format.indent, level) + "}")
+	}
+}
+
+// writeJSONString function writes s as a JSON string
+func writeJSONString(b *strings.Builder, s string) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	b.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+const (
+	jsonStepChild = iota
+	jsonStepIndex
+	jsonStepWildcard
+)
+
+// jsonPathStep struct denotes a step of a JSONPath, selecting the child
+// name of objects, the element index of arrays or every child with a
+// wildcard. A recursive step applies to the value and all its descendants
+type jsonPathStep struct {
+	kind      int
+	name      string
+	index     int
+	recursive bool
+}
+
+// JSONPath struct denotes a path within a JSON document. The subset of
+// JSONPath supported is made of the root $, children .name or ['name'],
+// array elements [index] where negative indexes count from the end,
+// wildcards .* and [*], and recursive descent ..name or ..*
+//
+// Like RedisJSON a path which does not start with $ is a legacy path such
+// as . or .a.b[0], which selects a single value and is replied as such
+// rather than as an array of every value the path selects
+type JSONPath struct {
+	raw    string
+	legacy bool
+	steps  []jsonPathStep
+}
+
+// ParseJSONPath function parses a JSONPath or a legacy path
+func ParseJSONPath(raw string) (*JSONPath, error) {
+	result := &JSONPath{raw: raw, steps: []jsonPathStep{}}
+	path := raw
+	if !strings.HasPrefix(path, "$") {
+		result.legacy = true
+		if path == "." {
+			path = ""
+		} else if path != "" && path[0] != '.' && path[0] != '[' {
+			path = "." + path
+		}
+		path = "$" + path
+	}
+	invalid := errors.New(fmt.Sprintf("ERR invalid JSONPath '%s'", raw))
+	for i := 1; i < len(path); {
+		step := jsonPathStep{}
+		if strings.HasPrefix(path[i:], "..") {
+			step.recursive = true
+			i += 2
+		} else if path[i] == '.' {
+			i++
+		} else if path[i] != '[' {
+			return nil, invalid
+		}
+		if i < len(path) && path[i] == '[' {
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, invalid
+			}
+			inner := path[i+1 : i+end]
+			i += end + 1
+			switch {
+			case inner == "*":
+				step.kind = jsonStepWildcard
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				step.kind, step.name = jsonStepChild, inner[1:len(inner)-1]
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, invalid
+				}
+				step.kind, step.index = jsonStepIndex, index
+			}
+		} else {
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			name := path[i : i+end]
+			i += end
+			switch name {
+			case "":
+				return nil, invalid
+			case "*":
+				step.kind = jsonStepWildcard
+			default:
+				step.kind, step.name = jsonStepChild, name
+			}
+		}
+		result.steps = append(result.steps, step)
+	}
+	return result, nil
+}
+
+// isRoot function returns whether the path selects the whole document
+func (p *JSONPath) isRoot() bool {
+	return len(p.steps) == 0
+}
+
+// jsonMatch struct denotes a value selected by a path along with where it
+// sits, the key of its parent object or the index within its parent array.
+// The parent of the root is nil
+type jsonMatch struct {
+	parent interface{}
+	key    string
+	index  int
+	value  interface{}
+}
+
+// children function returns the matches for the children of m selected by
+// step, ignoring whether it is recursive
+func (step jsonPathStep) children(m jsonMatch) []jsonMatch {
+	result := []jsonMatch{}
+	switch v := m.value.(type) {
+	case *jsonObject:
+		for _, key := range v.keys {
+			if step.kind == jsonStepWildcard || (step.kind == jsonStepChild && step.name == key) {
+				result = append(result, jsonMatch{parent: v, key: key, value: v.values[key]})
+			}
+		}
+	case *jsonArray:
+		if step.kind == jsonStepWildcard {
+			for i, elem := range v.elems {
+				result = append(result, jsonMatch{parent: v, index: i, value: elem})
+			}
+		} else if step.kind == jsonStepIndex {
+			index := step.index
+			if index < 0 {
+				index += len(v.elems)
+			}
+			if index >= 0 && index < len(v.elems) {
+				result = append(result, jsonMatch{parent: v, index: index, value: v.elems[index]})
+			}
+		}
+	}
+	return result
+}
+
+// descendants function returns m followed by all the values below it
+func descendants(m jsonMatch) []jsonMatch {
+	result := []jsonMatch{m}
+	for _, child := range (jsonPathStep{kind: jsonStepWildcard}).children(m) {
+		result = append(result, descendants(child)...)
+	}
+	return result
+}
+
+// evalJSONPath function returns the values the steps select from root
+func evalJSONPath(steps []jsonPathStep, root interface{}) []jsonMatch {
+	matches := []jsonMatch{{value: root}}
+	for _, step := range steps {
+		next := []jsonMatch{}
+		for _, m := range matches {
+			if step.recursive {
+				for _, d := range descendants(m) {
+					next = append(next, step.children(d)...)
+				}
+			} else {
+				next = append(next, step.children(m)...)
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// replace function replaces the value of the match in the document
+func (doc *JSON) replace(m jsonMatch, val interface{}) {
+	switch parent := m.parent.(type) {
+	case nil:
+		doc.root = val
+	case *jsonObject:
+		parent.set(m.key, val)
+	case *jsonArray:
+		parent.elems[m.index] = val
+	}
+}
+
+// getJSON function returns the document stored at key, nil if the key does
+// not exist. ErrWrongType is returned if the key holds another datatype
+func (s *Storage) getJSON(key Key) (*JSON, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		return nil, nil
+	}
+	result, ok := val.val.(*JSON)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// JSONSet function sets the values path selects in the document stored at
+// key to val. When the last step of the path names a child missing from
+// an object, the child is added. A document which does not exist can only
+// be created at the root. With nx only missing values are added and with
+// xx only existing ones are replaced. Returns whether anything was set
+func (s *Storage) JSONSet(key Key, path *JSONPath, val interface{}, nx bool, xx bool) (bool, error) {
+	doc, err := s.getJSON(key)
+	if err != nil {
+		return false, err
+	}
+	if doc == nil {
+		if !path.isRoot() {
+			return false, errors.New("ERR new objects must be created at the root")
+		}
+		if xx {
+			return false, nil
+		}
+		s.data[key] = Value{val: &JSON{root: val}}
+		s.keyModified(key)
+		return true, nil
+	}
+	set := false
+	if matches := evalJSONPath(path.steps, doc.root); len(matches) > 0 {
+		if nx {
+			return false, nil
+		}
+		for _, m := range matches {
+			doc.replace(m, jsonCopy(val))
+			set = true
+		}
+	} else if last := path.steps[len(path.steps)-1]; !xx && last.kind == jsonStepChild && !last.recursive {
+		for _, m := range evalJSONPath(path.steps[:len(path.steps)-1], doc.root) {
+			if obj, ok := m.value.(*jsonObject); ok {
+				obj.set(last.name, jsonCopy(val))
+				set = true
+			}
+		}
+	}
+	if set {
+		s.keyModified(key)
+	}
+	return set, nil
+}
+
+// JSONGet function returns the values path selects in the document stored
+// at key, nil if the key does not exist
+func (s *Storage) JSONGet(key Key, path *JSONPath) ([]interface{}, error) {
+	doc, err := s.getJSON(key)
+	if doc == nil {
+		return nil, err
+	}
+	matches := evalJSONPath(path.steps, doc.root)
+	result := make([]interface{}, len(matches))
+	for i, m := range matches {
+		result[i] = m.value
+	}
+	return result, nil
+}
+
+// JSONDel function deletes the values path selects from the document
+// stored at key and returns the number of values deleted. The key is
+// deleted along with the root
+func (s *Storage) JSONDel(key Key, path *JSONPath) (int, error) {
+	doc, err := s.getJSON(key)
+	if doc == nil {
+		return 0, err
+	}
+	if path.isRoot() {
+		delete(s.data, key)
+		s.keyModified(key)
+		return 1, nil
+	}
+	matches := evalJSONPath(path.steps, doc.root)
+	// elements are deleted from the last one so that indexes stay valid
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].index > matches[j].index })
+	deleted := 0
+	for _, m := range matches {
+		switch parent := m.parent.(type) {
+		case *jsonObject:
+			if _, prs := parent.values[m.key]; prs {
+				parent.remove(m.key)
+				deleted++
+			}
+		case *jsonArray:
+			if m.index < len(parent.elems) {
+				parent.elems = append(parent.elems[:m.index], parent.elems[m.index+1:]...)
+				deleted++
+			}
+		}
+	}
+	if deleted > 0 {
+		s.keyModified(key)
+	}
+	return deleted, nil
+}
+
+// errJSONType function returns the error for a value of the wrong type
+func errJSONType(expected string, found interface{}) error {
+	return errors.New(fmt.Sprintf("ERR wrong type of path value - expected %s but found %s", expected, jsonTypeName(found)))
+}
+
+// jsonUpdate function applies update to each of the values path selects in
+// the document stored at key, replacing them with the value returned and
+// collecting the results. A value update can't be applied to is left as
+// is and its result is the error returned
+func (s *Storage) jsonUpdate(key Key, path *JSONPath, update func(val interface{}) (interface{}, interface{}, error)) ([]interface{}, error) {
+	doc, err := s.getJSON(key)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errJSONNoKey
+	}
+	matches := evalJSONPath(path.steps, doc.root)
+	result := make([]interface{}, len(matches))
+	modified := false
+	for i, m := range matches {
+		val, res, err := update(m.value)
+		if err != nil {
+			result[i] = err
+			continue
+		}
+		doc.replace(m, val)
+		result[i] = res
+		modified = true
+	}
+	if modified {
+		s.keyModified(key)
+	}
+	return result, nil
+}
+
+// JSONNumIncrBy function increments the numbers path selects in the
+// document stored at key by incr and returns their new values. The sum of
+// two integers is an integer unless it overflows, otherwise it is a float
+func (s *Storage) JSONNumIncrBy(key Key, path *JSONPath, incr interface{}) ([]interface{}, error) {
+	return s.jsonUpdate(key, path, func(val interface{}) (interface{}, interface{}, error) {
+		var cur float64
+		switch v := val.(type) {
+		case int64:
+			if i, ok := incr.(int64); ok {
+				sum := v + i
+				if (sum > v) == (i > 0) {
+					return sum, sum, nil
+				}
+			}
+			cur = float64(v)
+		case float64:
+			cur = v
+		default:
+			return nil, nil, errJSONType("a number", val)
+		}
+		sum := cur
+		switch i := incr.(type) {
+		case int64:
+			sum += float64(i)
+		case float64:
+			sum += i
+		}
+		if math.IsInf(sum, 0) || math.IsNaN(sum) {
+			return nil, nil, errors.New("ERR result is not a number or infinity")
+		}
+		return sum, sum, nil
+	})
+}
+
+// JSONArrAppend function appends vals to the arrays path selects in the
+// document stored at key and returns their new lengths
+func (s *Storage) JSONArrAppend(key Key, path *JSONPath, vals []interface{}) ([]interface{}, error) {
+	return s.jsonUpdate(key, path, func(val interface{}) (interface{}, interface{}, error) {
+		arr, ok := val.(*jsonArray)
+		if !ok {
+			return nil, nil, errJSONType("an array", val)
+		}
+		for _, v := range vals {
+			arr.elems = append(arr.elems, jsonCopy(v))
+		}
+		return arr, int64(len(arr.elems)), nil
+	})
+}
+
+// JSONArrPop function removes the element at index of the arrays path
+// selects in the document stored at key, where negative indexes count from
+// the end and out of range ones pop the first or last element. Returns
+// the elements popped, nil for the empty arrays
+func (s *Storage) JSONArrPop(key Key, path *JSONPath, index int) ([]interface{}, error) {
+	return s.jsonUpdate(key, path, func(val interface{}) (interface{}, interface{}, error) {
+		arr, ok := val.(*jsonArray)
+		if !ok {
+			return nil, nil, errJSONType("an array", val)
+		}
+		if len(arr.elems) == 0 {
+			return arr, nil, nil
+		}
+		i := index
+		if i < 0 {
+			i += len(arr.elems)
+		}
+		if i < 0 {
+			i = 0
+		} else if i >= len(arr.elems) {
+			i = len(arr.elems) - 1
+		}
+		popped := arr.elems[i]
+		arr.elems = append(arr.elems[:i], arr.elems[i+1:]...)
+		return arr, SerializeJSON(popped), nil
+	})
+}
+
+// parseJSONPathArg function parses the optional path at index i of
+// commands, the root if it is missing
+func parseJSONPathArg(commands []string, i int) (*JSONPath, error) {
+	if i >= len(commands) {
+		return ParseJSONPath(".")
+	}
+	return ParseJSONPath(commands[i])
+}
+
+// jsonPathReply function converts the results of a command for each of the
+// values path selected into a reply. A JSONPath is replied with every
+// result, errors being replied as nil. A legacy path is replied with its
+// single result, an error if it did not select any value
+func jsonPathReply(path *JSONPath, results []interface{}) (interface{}, error) {
+	if path.legacy {
+		if len(results) == 0 {
+			return nil, errors.New(fmt.Sprintf("ERR Path '%s' does not exist", path.raw))
+		}
+		if err, ok := results[0].(error); ok {
+			return nil, err
+		}
+		return results[0], nil
+	}
+	reply := make([]interface{}, len(results))
+	for i, res := range results {
+		if _, ok := res.(error); !ok {
+			reply[i] = res
+		}
+	}
+	return reply, nil
+}
+
+// ProcessRespCommandJSONSet function processes command JSON.SET
+func (s *Server) ProcessRespCommandJSONSet(c *Connection, commands []string) (interface{}, error) {
+	path, err := ParseJSONPath(commands[2])
+	if err != nil {
+		return nil, err
+	}
+	val, err := ParseJSON(commands[3])
+	if err != nil {
+		return nil, err
+	}
+	nx, xx := false, false
+	for _, arg := range commands[4:] {
+		switch strings.ToUpper(arg) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	if nx && xx {
+		return nil, ErrSyntax
+	}
+	set, err := s.db.JSONSet(Key(commands[1]), path, val, nx, xx)
+	if !set {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
+
+// ProcessRespCommandJSONGet function processes command JSON.GET. With a
+// single path its values are replied serialized, with several paths an
+// object of the values of each path is replied
+func (s *Server) ProcessRespCommandJSONGet(c *Connection, commands []string) (interface{}, error) {
+	format := jsonFormat{}
+	i := 2
+options:
+	for ; i+1 < len(commands); i += 2 {
+		switch strings.ToUpper(commands[i]) {
+		case "INDENT":
+			format.indent = commands[i+1]
+		case "NEWLINE":
+			format.newline = commands[i+1]
+		case "SPACE":
+			format.space = commands[i+1]
+		default:
+			break options
+		}
+	}
+	args := commands[i:]
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	paths := make([]*JSONPath, len(args))
+	legacy := true
+	for j, arg := range args {
+		path, err := ParseJSONPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		paths[j] = path
+		legacy = legacy && path.legacy
+	}
+	reply := newJSONObject()
+	for _, path := range paths {
+		vals, err := s.db.JSONGet(Key(commands[1]), path)
+		if vals == nil {
+			return nil, err
+		}
+		if !legacy {
+			reply.set(path.raw, &jsonArray{elems: vals})
+			continue
+		}
+		if len(vals) == 0 {
+			return nil, errors.New(fmt.Sprintf("ERR Path '%s' does not exist", path.raw))
+		}
+		reply.set(path.raw, vals[0])
+	}
+	b := &strings.Builder{}
+	if len(paths) == 1 {
+		writeJSON(b, reply.values[paths[0].raw], format, 0)
+	} else {
+		writeJSON(b, reply, format, 0)
+	}
+	return b.String(), nil
+}
+
+// ProcessRespCommandJSONDel function processes command JSON.DEL
+func (s *Server) ProcessRespCommandJSONDel(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) > 3 {
+		return nil, ErrSyntax
+	}
+	path, err := parseJSONPathArg(commands, 2)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.JSONDel(Key(commands[1]), path)
+}
+
+// ProcessRespCommandJSONNumIncrBy function processes command
+// JSON.NUMINCRBY, the new values are replied serialized
+func (s *Server) ProcessRespCommandJSONNumIncrBy(c *Connection, commands []string) (interface{}, error) {
+	path, err := ParseJSONPath(commands[2])
+	if err != nil {
+		return nil, err
+	}
+	incr, err := ParseJSON(commands[3])
+	if err != nil {
+		return nil, ErrNotFloat
+	}
+	if _, ok := incr.(int64); !ok {
+		if _, ok := incr.(float64); !ok {
+			return nil, ErrNotFloat
+		}
+	}
+	results, err := s.db.JSONNumIncrBy(Key(commands[1]), path, incr)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := jsonPathReply(path, results)
+	if err != nil {
+		return nil, err
+	}
+	if path.legacy {
+		return SerializeJSON(reply), nil
+	}
+	return SerializeJSON(&jsonArray{elems: reply.([]interface{})}), nil
+}
+
+// ProcessRespCommandJSONArrAppend function processes command
+// JSON.ARRAPPEND
+func (s *Server) ProcessRespCommandJSONArrAppend(c *Connection, commands []string) (interface{}, error) {
+	path, err := ParseJSONPath(commands[2])
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]interface{}, len(commands)-3)
+	for i, arg := range commands[3:] {
+		if vals[i], err = ParseJSON(arg); err != nil {
+			return nil, err
+		}
+	}
+	results, err := s.db.JSONArrAppend(Key(commands[1]), path, vals)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPathReply(path, results)
+}
+
+// ProcessRespCommandJSONArrPop function processes command JSON.ARRPOP,
+// the last element is popped unless an index is given
+func (s *Server) ProcessRespCommandJSONArrPop(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) > 4 {
+		return nil, ErrSyntax
+	}
+	path, err := parseJSONPathArg(commands, 2)
+	if err != nil {
+		return nil, err
+	}
+	index := int64(-1)
+	if len(commands) == 4 {
+		if index, err = strconv.ParseInt(commands[3], 10, 64); err != nil {
+			return nil, ErrNotInteger
+		}
+	}
+	results, err := s.db.JSONArrPop(Key(commands[1]), path, int(index))
+	if err != nil {
+		return nil, err
+	}
+	return jsonPathReply(path, results)
+}
+
+// processJSONRead function implements the commands replying a property
+// of the values path selects, nil if the key does not exist
+func (s *Server) processJSONRead(commands []string, property func(val interface{}) interface{}) (interface{}, error) {
+	if len(commands) > 3 {
+		return nil, ErrSyntax
+	}
+	path, err := parseJSONPathArg(commands, 2)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := s.db.JSONGet(Key(commands[1]), path)
+	if vals == nil {
+		return nil, err
+	}
+	results := make([]interface{}, len(vals))
+	for i, val := range vals {
+		results[i] = property(val)
+	}
+	return jsonPathReply(path, results)
+}
+
+// ProcessRespCommandJSONType function processes command JSON.TYPE
+func (s *Server) ProcessRespCommandJSONType(c *Connection, commands []string) (interface{}, error) {
+	reply, err := s.processJSONRead(commands, func(val interface{}) interface{} {
+		return jsonTypeName(val)
+	})
+	// like RedisJSON a legacy path is replied with a simple string
+	if name, ok := reply.(string); ok {
+		return SimpleString(name), err
+	}
+	return reply, err
+}
+
+// ProcessRespCommandJSONObjLen function processes command JSON.OBJLEN
+func (s *Server) ProcessRespCommandJSONObjLen(c *Connection, commands []string) (interface{}, error) {
+	return s.processJSONRead(commands, func(val interface{}) interface{} {
+		if obj, ok := val.(*jsonObject); ok {
+			return int64(len(obj.keys))
+		}
+		return errJSONType("an object", val)
+	})
+}
+
+// ProcessRespCommandJSONArrLen function processes command JSON.ARRLEN
+func (s *Server) ProcessRespCommandJSONArrLen(c *Connection, commands []string) (interface{}, error) {
+	return s.processJSONRead(commands, func(val interface{}) interface{} {
+		if arr, ok := val.(*jsonArray); ok {
+			return int64(len(arr.elems))
+		}
+		return errJSONType("an array", val)
+	})
+}
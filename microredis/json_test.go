@@ -0,0 +1,169 @@
+package microredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestJSONParse(t *testing.T) {
+	for _, text := range []string{
+		`{"b":1,"a":[true,false,null],"c":{"d":"x\"y<z>"}}`,
+		`[1,-2.5,1.0,1e+21,"",[],{}]`,
+		`"str"`,
+		`9223372036854775807`,
+	} {
+		val, err := m.ParseJSON(text)
+		assert.Nil(t, err)
+		assert.Equal(t, text, m.SerializeJSON(val))
+	}
+	val, err := m.ParseJSON(" { \"a\" : 1.50 ,\n\"b\":1e2 } ")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":1.5,"b":100.0}`, m.SerializeJSON(val))
+	for _, text := range []string{``, `{`, `[1,]`, `{"a":1}x`, `nul`, `1 2`} {
+		_, err := m.ParseJSON(text)
+		assert.NotNil(t, err, text)
+	}
+
+	for _, path := range []string{"$", "$.a", "$['a b'][0]", "$..a", "$.*[-1]", "$..*", ".", "", ".a.b", "a[1]", "[0]"} {
+		_, err := m.ParseJSONPath(path)
+		assert.Nil(t, err, path)
+	}
+	for _, path := range []string{"$a", "$.", "$[", "$[x]", "$..", ".a..", "a.", "$.a[1"} {
+		_, err := m.ParseJSONPath(path)
+		assert.NotNil(t, err, path)
+	}
+}
+
+func TestJSONStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	root, _ := m.ParseJSONPath("$")
+	path, _ := m.ParseJSONPath("$..n")
+	doc, _ := m.ParseJSON(`{"n":1,"a":{"n":2.5},"b":[{"n":"x"}]}`)
+
+	set, err := s.JSONSet(m.Key("j"), path, doc, false, false)
+	assert.Equal(t, "ERR new objects must be created at the root", err.Error())
+	assert.False(t, set)
+	set, err = s.JSONSet(m.Key("j"), root, doc, false, true)
+	assert.Nil(t, err)
+	assert.False(t, set)
+	set, err = s.JSONSet(m.Key("j"), root, doc, true, false)
+	assert.Nil(t, err)
+	assert.True(t, set)
+	assert.Equal(t, "ReJSON-RL", s.Type(m.Key("j")))
+
+	vals, err := s.JSONGet(m.Key("j"), path)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{int64(1), 2.5, "x"}, vals)
+	results, err := s.JSONNumIncrBy(m.Key("j"), path, int64(2))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), results[0])
+	assert.Equal(t, 4.5, results[1])
+	assert.Equal(t, "ERR wrong type of path value - expected a number but found string", results[2].(error).Error())
+
+	deleted, err := s.JSONDel(m.Key("j"), path)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, deleted)
+	vals, _ = s.JSONGet(m.Key("j"), root)
+	assert.Equal(t, `{"a":{},"b":[{}]}`, m.SerializeJSON(vals[0]))
+	deleted, _ = s.JSONDel(m.Key("j"), root)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, "none", s.Type(m.Key("j")))
+
+	vals, err = s.JSONGet(m.Key("j"), root)
+	assert.Nil(t, err)
+	assert.Nil(t, vals)
+	_, err = s.JSONArrAppend(m.Key("j"), root, []interface{}{int64(1)})
+	assert.Equal(t, "ERR could not perform this operation on a key that doesn't exist", err.Error())
+
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, err = s.JSONGet(m.Key("str"), root)
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestJSONCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"set and get", []step{
+			{args: []string{"JSON.SET", "j", "$", `{"a":1,"b":{"c":[1,2]}}`}, reply: "+OK\r\n"},
+			{args: []string{"JSON.GET", "j"}, reply: "$23\r\n{\"a\":1,\"b\":{\"c\":[1,2]}}\r\n"},
+			{args: []string{"JSON.GET", "j", "$.b.c[-1]"}, reply: "$3\r\n[2]\r\n"},
+			{args: []string{"JSON.GET", "j", ".b.c"}, reply: "$5\r\n[1,2]\r\n"},
+			{args: []string{"JSON.GET", "j", "$.a", "$..c"}, reply: "$26\r\n{\"$.a\":[1],\"$..c\":[[1,2]]}\r\n"},
+			{args: []string{"JSON.GET", "j", ".a", "b"}, reply: "$24\r\n{\".a\":1,\"b\":{\"c\":[1,2]}}\r\n"},
+			{args: []string{"JSON.GET", "j", "INDENT", "  ", "NEWLINE", "\n", "SPACE", " ", "$.b"}, reply: "$45\r\n[\n  {\n    \"c\": [\n      1,\n      2\n    ]\n  }\n]\r\n"},
+			{args: []string{"JSON.GET", "j", "$.x"}, reply: "$2\r\n[]\r\n"},
+			{args: []string{"JSON.GET", "j", ".x"}, reply: "-ERR Path '.x' does not exist\r\n"},
+			{args: []string{"JSON.GET", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"JSON.SET", "j", "$.b.d", `"new"`}, reply: "+OK\r\n"},
+			{args: []string{"JSON.SET", "j", "$.a", "true", "NX"}, reply: "$-1\r\n"},
+			{args: []string{"JSON.SET", "j", "$.e", "true", "XX"}, reply: "$-1\r\n"},
+			{args: []string{"JSON.SET", "j", "$.a", "null", "XX"}, reply: "+OK\r\n"},
+			{args: []string{"JSON.SET", "j", "$.x.y", "1"}, reply: "$-1\r\n"},
+			{args: []string{"JSON.SET", "j", "$.b.*", "0"}, reply: "+OK\r\n"},
+			{args: []string{"JSON.GET", "j"}, reply: "$28\r\n{\"a\":null,\"b\":{\"c\":0,\"d\":0}}\r\n"},
+			{args: []string{"JSON.SET", "j", ".", `"root"`}, reply: "+OK\r\n"},
+			{args: []string{"JSON.GET", "j"}, reply: "$6\r\n\"root\"\r\n"},
+			{args: []string{"TYPE", "j"}, reply: "+ReJSON-RL\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "j"}, reply: "$3\r\nraw\r\n"},
+		}},
+		{"delete", []step{
+			{args: []string{"JSON.SET", "j", "$", `{"a":[1,2,3,4],"b":{"a":1}}`}, reply: "+OK\r\n"},
+			{args: []string{"JSON.DEL", "j", "$.a[*]"}, reply: ":4\r\n"},
+			{args: []string{"JSON.DEL", "j", "$.x"}, reply: ":0\r\n"},
+			{args: []string{"JSON.DEL", "j", "b.a"}, reply: ":1\r\n"},
+			{args: []string{"JSON.GET", "j"}, reply: "$15\r\n{\"a\":[],\"b\":{}}\r\n"},
+			{args: []string{"JSON.DEL", "j"}, reply: ":1\r\n"},
+			{args: []string{"TYPE", "j"}, reply: "+none\r\n"},
+			{args: []string{"JSON.DEL", "j"}, reply: ":0\r\n"},
+		}},
+		{"numbers", []step{
+			{args: []string{"JSON.SET", "j", "$", `{"i":1,"f":1.5,"s":"x"}`}, reply: "+OK\r\n"},
+			{args: []string{"JSON.NUMINCRBY", "j", "$.i", "2"}, reply: "$3\r\n[3]\r\n"},
+			{args: []string{"JSON.NUMINCRBY", "j", ".i", "0.5"}, reply: "$3\r\n3.5\r\n"},
+			{args: []string{"JSON.NUMINCRBY", "j", "$.*", "0.5"}, reply: "$14\r\n[4.0,2.0,null]\r\n"},
+			{args: []string{"JSON.NUMINCRBY", "j", ".s", "1"}, reply: "-ERR wrong type of path value - expected a number but found string\r\n"},
+			{args: []string{"JSON.NUMINCRBY", "j", ".i", "x"}, reply: "-ERR value is not a valid float\r\n"},
+			{args: []string{"JSON.NUMINCRBY", "missing", ".i", "1"}, reply: "-ERR could not perform this operation on a key that doesn't exist\r\n"},
+		}},
+		{"arrays", []step{
+			{args: []string{"JSON.SET", "j", "$", `{"a":[1],"b":{"a":[]},"c":"x"}`}, reply: "+OK\r\n"},
+			{args: []string{"JSON.ARRAPPEND", "j", "$..a", "2", `"3"`}, reply: "*2\r\n:3\r\n:2\r\n"},
+			{args: []string{"JSON.ARRAPPEND", "j", ".a", "[4]"}, reply: ":4\r\n"},
+			{args: []string{"JSON.ARRAPPEND", "j", "$.c", "1"}, reply: "*1\r\n$-1\r\n"},
+			{args: []string{"JSON.ARRAPPEND", "j", ".c", "1"}, reply: "-ERR wrong type of path value - expected an array but found string\r\n"},
+			{args: []string{"JSON.ARRAPPEND", "j", ".a", "{"}, pattern: `^-ERR invalid JSON document`},
+			{args: []string{"JSON.ARRLEN", "j", "$.*"}, reply: "*3\r\n:4\r\n$-1\r\n$-1\r\n"},
+			{args: []string{"JSON.ARRLEN", "j", ".a"}, reply: ":4\r\n"},
+			{args: []string{"JSON.ARRPOP", "j", ".a"}, reply: "$3\r\n[4]\r\n"},
+			{args: []string{"JSON.ARRPOP", "j", "$.a", "0"}, reply: "*1\r\n$1\r\n1\r\n"},
+			{args: []string{"JSON.ARRPOP", "j", ".a", "-10"}, reply: "$1\r\n2\r\n"},
+			{args: []string{"JSON.ARRPOP", "j", "$..a", "99"}, reply: "*2\r\n$3\r\n\"3\"\r\n$3\r\n\"3\"\r\n"},
+			{args: []string{"JSON.ARRPOP", "j", ".a"}, reply: "$-1\r\n"},
+			{args: []string{"JSON.ARRPOP", "j", ".a", "x"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"JSON.ARRLEN", "missing"}, reply: "$-1\r\n"},
+		}},
+		{"type and objlen", []step{
+			{args: []string{"JSON.SET", "j", "$", `{"a":{"x":1,"y":2.5},"b":[true,null,"s"]}`}, reply: "+OK\r\n"},
+			{args: []string{"JSON.TYPE", "j"}, reply: "+object\r\n"},
+			{args: []string{"JSON.TYPE", "j", "$..*"}, reply: "*7\r\n$6\r\nobject\r\n$5\r\narray\r\n$7\r\ninteger\r\n$6\r\nnumber\r\n$7\r\nboolean\r\n$4\r\nnull\r\n$6\r\nstring\r\n"},
+			{args: []string{"JSON.TYPE", "j", ".x"}, reply: "-ERR Path '.x' does not exist\r\n"},
+			{args: []string{"JSON.TYPE", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"JSON.OBJLEN", "j"}, reply: ":2\r\n"},
+			{args: []string{"JSON.OBJLEN", "j", "$.*"}, reply: "*2\r\n:2\r\n$-1\r\n"},
+			{args: []string{"JSON.OBJLEN", "j", ".b"}, reply: "-ERR wrong type of path value - expected an object but found array\r\n"},
+		}},
+		{"errors", []step{
+			{args: []string{"JSON.SET", "j", "$.a", "1"}, reply: "-ERR new objects must be created at the root\r\n"},
+			{args: []string{"JSON.SET", "j", "$", "{"}, pattern: `^-ERR invalid JSON document`},
+			{args: []string{"JSON.SET", "j", "$", "1", "NX", "XX"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"JSON.SET", "j", "$", "1", "EX"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"JSON.GET", "j", "$["}, reply: "-ERR invalid JSONPath '$['\r\n"},
+			{args: []string{"JSON.DEL", "j", "$", "$"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"JSON.SET", "j"}, reply: "-ERR wrong number of arguments for 'json.set' command\r\n"},
+			{args: []string{"SET", "str", "v"}, reply: "+OK\r\n"},
+			{args: []string{"JSON.GET", "str"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}
@@ -0,0 +1,657 @@
+package microredis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// listNodeSize is the maximum number of entries kept in a single node of
+// a List
+const listNodeSize = 128
+
+// listNode struct is a chunk of consecutive entries of a List
+type listNode struct {
+	entries []string
+	prev    *listNode
+	next    *listNode
+}
+
+// List struct denotes the list datatype. Similar to the quicklist of redis
+// it is a doubly linked list of nodes where each node holds a chunk of up
+// to listNodeSize entries, which keeps pushes and pops at both ends cheap
+// while avoiding the overhead of a node per entry
+type List struct {
+	head   *listNode
+	tail   *listNode
+	length int
+}
+
+// NewList function creates and initializes an empty List
+func NewList() *List {
+	return &List{}
+}
+
+// Len function returns the number of entries in the list
+func (l *List) Len() int {
+	return l.length
+}
+
+// PushLeft function inserts val at the head of the list
+func (l *List) PushLeft(val string) {
+	if l.head == nil || len(l.head.entries) >= listNodeSize {
+		node := &listNode{entries: make([]string, 0, 1), next: l.head}
+		if l.head != nil {
+			l.head.prev = node
+		} else {
+			l.tail = node
+		}
+		l.head = node
+	}
+	l.head.entries = append(l.head.entries, "")
+	copy(l.head.entries[1:], l.head.entries)
+	l.head.entries[0] = val
+	l.length++
+}
+
+// PushRight function inserts val at the tail of the list
+func (l *List) PushRight(val string) {
+	if l.tail == nil || len(l.tail.entries) >= listNodeSize {
+		node := &listNode{entries: make([]string, 0, 1), prev: l.tail}
+		if l.tail != nil {
+			l.tail.next = node
+		} else {
+			l.head = node
+		}
+		l.tail = node
+	}
+	l.tail.entries = append(l.tail.entries, val)
+	l.length++
+}
+
+// PopLeft function removes and returns the entry at the head of the list
+// The second return value is false if the list is empty
+func (l *List) PopLeft() (string, bool) {
+	if l.length == 0 {
+		return "", false
+	}
+	result := l.head.entries[0]
+	l.removeAt(l.head, 0)
+	return result, true
+}
+
+// PopRight function removes and returns the entry at the tail of the list
+// The second return value is false if the list is empty
+func (l *List) PopRight() (string, bool) {
+	if l.length == 0 {
+		return "", false
+	}
+	result := l.tail.entries[len(l.tail.entries)-1]
+	l.removeAt(l.tail, len(l.tail.entries)-1)
+	return result, true
+}
+
+// normalizeIndex function converts a possibly negative index, counted from
+// the tail, into an index from the head. ok is false if it is out of range
+func (l *List) normalizeIndex(index int) (int, bool) {
+	if index < 0 {
+		index = l.length + index
+	}
+	return index, index >= 0 && index < l.length
+}
+
+// locate function returns the node holding the entry at index, which has
+// to be in range, along with the position of the entry in the node. The
+// list is walked from whichever end is closer
+func (l *List) locate(index int) (*listNode, int) {
+	if index < l.length/2 {
+		node := l.head
+		for index >= len(node.entries) {
+			index -= len(node.entries)
+			node = node.next
+		}
+		return node, index
+	}
+	index = l.length - 1 - index
+	node := l.tail
+	for index >= len(node.entries) {
+		index -= len(node.entries)
+		node = node.prev
+	}
+	return node, len(node.entries) - 1 - index
+}
+
+// removeAt function removes the entry at position i of node, unlinking the
+// node if it becomes empty
+func (l *List) removeAt(node *listNode, i int) {
+	node.entries = append(node.entries[:i], node.entries[i+1:]...)
+	l.length--
+	if len(node.entries) == 0 {
+		l.unlink(node)
+	}
+}
+
+// unlink function removes node from the list
+func (l *List) unlink(node *listNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		l.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		l.tail = node.prev
+	}
+}
+
+// insertAt function inserts val at position i of node, splitting the node
+// in two halves if it grows beyond listNodeSize
+func (l *List) insertAt(node *listNode, i int, val string) {
+	node.entries = append(node.entries, "")
+	copy(node.entries[i+1:], node.entries[i:])
+	node.entries[i] = val
+	l.length++
+	if len(node.entries) > listNodeSize {
+		half := len(node.entries) / 2
+		split := &listNode{prev: node, next: node.next}
+		split.entries = append(make([]string, 0, listNodeSize), node.entries[half:]...)
+		node.entries = node.entries[:half:half]
+		if node.next != nil {
+			node.next.prev = split
+		} else {
+			l.tail = split
+		}
+		node.next = split
+	}
+}
+
+// Index function returns the entry at index, negative indexes count from
+// the tail with -1 being the last entry
+func (l *List) Index(index int) (string, bool) {
+	index, ok := l.normalizeIndex(index)
+	if !ok {
+		return "", false
+	}
+	node, i := l.locate(index)
+	return node.entries[i], true
+}
+
+// Set function replaces the entry at index, negative indexes count from
+// the tail. It returns false if index is out of range
+func (l *List) Set(index int, val string) bool {
+	index, ok := l.normalizeIndex(index)
+	if !ok {
+		return false
+	}
+	node, i := l.locate(index)
+	node.entries[i] = val
+	return true
+}
+
+// rangeBounds function converts start and stop, both inclusive and
+// possibly negative, into a valid range of the list following the rules of
+// LRANGE. ok is false if the range is empty
+func (l *List) rangeBounds(start int, stop int) (int, int, bool) {
+	if start < 0 {
+		start = l.length + start
+	}
+	if stop < 0 {
+		stop = l.length + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= l.length {
+		stop = l.length - 1
+	}
+	return start, stop, start <= stop && start < l.length
+}
+
+// Range function returns the entries between start and stop, both
+// inclusive. Negative indexes count from the tail and out of range
+// indexes are clamped to the list
+func (l *List) Range(start int, stop int) []string {
+	start, stop, ok := l.rangeBounds(start, stop)
+	if !ok {
+		return []string{}
+	}
+	result := make([]string, 0, stop-start+1)
+	node, i := l.locate(start)
+	for len(result) < stop-start+1 {
+		take := len(node.entries) - i
+		if remaining := stop - start + 1 - len(result); take > remaining {
+			take = remaining
+		}
+		result = append(result, node.entries[i:i+take]...)
+		node = node.next
+		i = 0
+	}
+	return result
+}
+
+// Trim function keeps only the entries between start and stop, both
+// inclusive, following the same rules as Range
+func (l *List) Trim(start int, stop int) {
+	start, stop, ok := l.rangeBounds(start, stop)
+	if !ok {
+		*l = List{}
+		return
+	}
+	kept := l.Range(start, stop)
+	*l = List{}
+	for _, val := range kept {
+		l.PushRight(val)
+	}
+}
+
+// Remove function removes entries equal to val and returns the number of
+// entries removed. A positive count removes up to count entries starting
+// from the head, a negative one up to -count starting from the tail and
+// 0 removes all of them
+func (l *List) Remove(count int, val string) int {
+	removed := 0
+	if count >= 0 {
+		for node := l.head; node != nil; {
+			next := node.next
+			for i := 0; i < len(node.entries); {
+				if node.entries[i] == val && (count == 0 || removed < count) {
+					l.removeAt(node, i)
+					removed++
+					if len(node.entries) == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+			node = next
+		}
+	} else {
+		for node := l.tail; node != nil; {
+			prev := node.prev
+			for i := len(node.entries) - 1; i >= 0; i-- {
+				if node.entries[i] == val && removed < -count {
+					l.removeAt(node, i)
+					removed++
+				}
+			}
+			node = prev
+		}
+	}
+	return removed
+}
+
+// Insert function inserts val right before or after the first entry equal
+// to pivot and returns the length of the list after the insert, or -1 if
+// pivot was not found
+func (l *List) Insert(before bool, pivot string, val string) int {
+	for node := l.head; node != nil; node = node.next {
+		for i, entry := range node.entries {
+			if entry == pivot {
+				if !before {
+					i++
+				}
+				l.insertAt(node, i, val)
+				return l.length
+			}
+		}
+	}
+	return -1
+}
+
+// getList function returns the list stored at key. If the key does not
+// exist nil is returned, unless create is set in which case a new empty
+// list is stored at key. ErrWrongType is returned if the key holds another
+// datatype
+func (s *Storage) getList(key Key, create bool) (*List, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		if !create {
+			return nil, nil
+		}
+		result := NewList()
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	result, ok := val.val.(*List)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// deleteIfEmptyList function removes key if it holds an empty list, as
+// redis never keeps empty aggregate values around
+func (s *Storage) deleteIfEmptyList(key Key, l *List) {
+	if l.Len() == 0 {
+		delete(s.data, key)
+	}
+}
+
+// Push function inserts all the vals at the head of the list stored at
+// key if left is set, at its tail otherwise, one after the other. The list
+// is created if it does not exist. Returns the length of the list
+func (s *Storage) Push(key Key, vals []string, left bool) (int, error) {
+	l, err := s.getList(key, true)
+	if err != nil {
+		return 0, err
+	}
+	for _, val := range vals {
+		if left {
+			l.PushLeft(val)
+		} else {
+			l.PushRight(val)
+		}
+	}
+	s.keyModified(key)
+	return l.Len(), nil
+}
+
+// Pop function removes and returns up to count entries from the head of
+// the list stored at key if left is set, from its tail otherwise. nil is
+// returned if the key does not exist
+func (s *Storage) Pop(key Key, count int, left bool) ([]string, error) {
+	l, err := s.getList(key, false)
+	if l == nil {
+		return nil, err
+	}
+	result := []string{}
+	for len(result) < count {
+		var val string
+		var ok bool
+		if left {
+			val, ok = l.PopLeft()
+		} else {
+			val, ok = l.PopRight()
+		}
+		if !ok {
+			break
+		}
+		result = append(result, val)
+	}
+	if len(result) > 0 {
+		s.deleteIfEmptyList(key, l)
+		s.keyModified(key)
+	}
+	return result, nil
+}
+
+// LRange function returns the entries of the list stored at key between
+// start and stop as explained in List.Range
+func (s *Storage) LRange(key Key, start int, stop int) ([]string, error) {
+	l, err := s.getList(key, false)
+	if l == nil {
+		return []string{}, err
+	}
+	return l.Range(start, stop), nil
+}
+
+// LLen function returns the length of the list stored at key, 0 if the key
+// does not exist
+func (s *Storage) LLen(key Key) (int, error) {
+	l, err := s.getList(key, false)
+	if l == nil {
+		return 0, err
+	}
+	return l.Len(), nil
+}
+
+// LIndex function returns the entry at index of the list stored at key,
+// nil if the key does not exist or the index is out of range
+func (s *Storage) LIndex(key Key, index int) (*string, error) {
+	l, err := s.getList(key, false)
+	if l == nil {
+		return nil, err
+	}
+	if val, ok := l.Index(index); ok {
+		return &val, nil
+	}
+	return nil, nil
+}
+
+// LSet function replaces the entry at index of the list stored at key
+func (s *Storage) LSet(key Key, index int, val string) error {
+	l, err := s.getList(key, false)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return errors.New("ERR no such key")
+	}
+	if !l.Set(index, val) {
+		return errors.New("ERR index out of range")
+	}
+	s.keyModified(key)
+	return nil
+}
+
+// LRem function removes entries equal to val from the list stored at key
+// as explained in List.Remove and returns the number of removed entries
+func (s *Storage) LRem(key Key, count int, val string) (int, error) {
+	l, err := s.getList(key, false)
+	if l == nil {
+		return 0, err
+	}
+	result := l.Remove(count, val)
+	if result > 0 {
+		s.deleteIfEmptyList(key, l)
+		s.keyModified(key)
+	}
+	return result, nil
+}
+
+// LTrim function trims the list stored at key to the entries between
+// start and stop as explained in List.Trim
+func (s *Storage) LTrim(key Key, start int, stop int) error {
+	l, err := s.getList(key, false)
+	if l == nil {
+		return err
+	}
+	l.Trim(start, stop)
+	s.deleteIfEmptyList(key, l)
+	s.keyModified(key)
+	return nil
+}
+
+// LInsert function inserts val before or after pivot in the list stored at
+// key. It returns the new length of the list, -1 if pivot was not found
+// and 0 if the key does not exist
+func (s *Storage) LInsert(key Key, before bool, pivot string, val string) (int, error) {
+	l, err := s.getList(key, false)
+	if l == nil {
+		return 0, err
+	}
+	result := l.Insert(before, pivot, val)
+	if result > 0 {
+		s.keyModified(key)
+	}
+	return result, nil
+}
+
+// LMove function atomically pops an entry from the head of the list at
+// src if from_left is set, or its tail otherwise, and pushes it to the
+// head of the list at dst if to_left is set, or its tail otherwise. nil is
+// returned if src does not exist. src and dst may be the same list
+func (s *Storage) LMove(src Key, dst Key, from_left bool, to_left bool) (*string, error) {
+	l, err := s.getList(src, false)
+	if l == nil {
+		return nil, err
+	}
+	// validate the type of dst before modifying src
+	if _, err := s.getList(dst, false); err != nil {
+		return nil, err
+	}
+	popped, _ := s.Pop(src, 1, from_left)
+	if _, err := s.Push(dst, popped, to_left); err != nil {
+		return nil, err
+	}
+	return &popped[0], nil
+}
+
+// ProcessRespCommandLPush function processes redis command LPUSH
+func (s *Server) ProcessRespCommandLPush(c *Connection, commands []string) (interface{}, error) {
+	return s.db.Push(Key(commands[1]), commands[2:], true)
+}
+
+// ProcessRespCommandRPush function processes redis command RPUSH
+func (s *Server) ProcessRespCommandRPush(c *Connection, commands []string) (interface{}, error) {
+	return s.db.Push(Key(commands[1]), commands[2:], false)
+}
+
+// ProcessRespCommandLPop function processes redis command LPOP
+func (s *Server) ProcessRespCommandLPop(c *Connection, commands []string) (interface{}, error) {
+	return s.processPop(commands, true)
+}
+
+// ProcessRespCommandRPop function processes redis command RPOP
+func (s *Server) ProcessRespCommandRPop(c *Connection, commands []string) (interface{}, error) {
+	return s.processPop(commands, false)
+}
+
+// processPop function implements LPOP and RPOP. Without count a single
+// entry is replied, with count an array of up to count entries
+func (s *Server) processPop(commands []string, left bool) (interface{}, error) {
+	if len(commands) > 3 {
+		return nil, ErrSyntax
+	}
+	count := int64(1)
+	if len(commands) == 3 {
+		val, err := strconv.ParseInt(commands[2], 10, 64)
+		if err != nil || val < 0 {
+			return nil, errors.New("ERR value is out of range, must be positive")
+		}
+		count = val
+	}
+	result, err := s.db.Pop(Key(commands[1]), int(count), left)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 2 {
+		if len(result) == 0 {
+			return nil, nil
+		}
+		return result[0], nil
+	}
+	if result == nil {
+		return NullArray{}, nil
+	}
+	return result, nil
+}
+
+// parseInts function parses each of the arguments as an int
+func parseInts(args []string) ([]int, error) {
+	result := make([]int, len(args))
+	for i, arg := range args {
+		val, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		result[i] = int(val)
+	}
+	return result, nil
+}
+
+// ProcessRespCommandLRange function processes redis command LRANGE
+func (s *Server) ProcessRespCommandLRange(c *Connection, commands []string) (interface{}, error) {
+	bounds, err := parseInts(commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	return s.db.LRange(Key(commands[1]), bounds[0], bounds[1])
+}
+
+// ProcessRespCommandLLen function processes redis command LLEN
+func (s *Server) ProcessRespCommandLLen(c *Connection, commands []string) (interface{}, error) {
+	return s.db.LLen(Key(commands[1]))
+}
+
+// ProcessRespCommandLIndex function processes redis command LINDEX
+func (s *Server) ProcessRespCommandLIndex(c *Connection, commands []string) (interface{}, error) {
+	index, err := parseInts(commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.LIndex(Key(commands[1]), index[0])
+	if result == nil {
+		return nil, err
+	}
+	return *result, nil
+}
+
+// ProcessRespCommandLSet function processes redis command LSET
+func (s *Server) ProcessRespCommandLSet(c *Connection, commands []string) (interface{}, error) {
+	index, err := parseInts(commands[2:3])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.LSet(Key(commands[1]), index[0], commands[3]); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
+
+// ProcessRespCommandLRem function processes redis command LREM
+func (s *Server) ProcessRespCommandLRem(c *Connection, commands []string) (interface{}, error) {
+	count, err := parseInts(commands[2:3])
+	if err != nil {
+		return nil, err
+	}
+	return s.db.LRem(Key(commands[1]), count[0], commands[3])
+}
+
+// ProcessRespCommandLTrim function processes redis command LTRIM
+func (s *Server) ProcessRespCommandLTrim(c *Connection, commands []string) (interface{}, error) {
+	bounds, err := parseInts(commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.LTrim(Key(commands[1]), bounds[0], bounds[1]); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
+
+// ProcessRespCommandLInsert function processes redis command LINSERT
+func (s *Server) ProcessRespCommandLInsert(c *Connection, commands []string) (interface{}, error) {
+	var before bool
+	switch strings.ToUpper(commands[2]) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return nil, ErrSyntax
+	}
+	return s.db.LInsert(Key(commands[1]), before, commands[3], commands[4])
+}
+
+// parseListSide function parses the LEFT or RIGHT argument of LMOVE,
+// returning true for LEFT
+func parseListSide(arg string) (bool, error) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, nil
+	case "RIGHT":
+		return false, nil
+	default:
+		return false, ErrSyntax
+	}
+}
+
+// ProcessRespCommandLMove function processes redis command LMOVE
+func (s *Server) ProcessRespCommandLMove(c *Connection, commands []string) (interface{}, error) {
+	from_left, err := parseListSide(commands[3])
+	if err != nil {
+		return nil, err
+	}
+	to_left, err := parseListSide(commands[4])
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.LMove(Key(commands[1]), Key(commands[2]), from_left, to_left)
+	if result == nil {
+		return nil, err
+	}
+	return *result, nil
+}
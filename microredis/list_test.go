@@ -0,0 +1,190 @@
+package microredis_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+// numbers function returns the strings "0" to "n-1"
+func numbers(n int) []string {
+	result := make([]string, n)
+	for i := range result {
+		result[i] = strconv.Itoa(i)
+	}
+	return result
+}
+
+func TestList(t *testing.T) {
+	// use enough entries to span several nodes
+	l := m.NewList()
+	for _, val := range numbers(300) {
+		l.PushRight(val)
+	}
+	l.PushLeft("head")
+	assert.Equal(t, 301, l.Len())
+
+	val, ok := l.Index(0)
+	assert.True(t, ok)
+	assert.Equal(t, "head", val)
+	val, ok = l.Index(-1)
+	assert.True(t, ok)
+	assert.Equal(t, "299", val)
+	val, ok = l.Index(200)
+	assert.True(t, ok)
+	assert.Equal(t, "199", val)
+	_, ok = l.Index(301)
+	assert.False(t, ok)
+
+	assert.Equal(t, numbers(300), l.Range(1, -1))
+	assert.Equal(t, []string{"126", "127", "128", "129"}, l.Range(127, 130))
+	assert.Equal(t, []string{}, l.Range(5, 2))
+
+	assert.True(t, l.Set(-2, "x"))
+	assert.False(t, l.Set(500, "x"))
+	val, _ = l.PopRight()
+	assert.Equal(t, "299", val)
+	val, _ = l.PopRight()
+	assert.Equal(t, "x", val)
+
+	// insert into a full node splits it
+	assert.Equal(t, 300, l.Insert(true, "64", "y"))
+	assert.Equal(t, []string{"63", "y", "64"}, l.Range(64, 66))
+	assert.Equal(t, -1, l.Insert(false, "missing", "y"))
+
+	l.Trim(1, 10)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, l.Range(0, -1))
+	l.Trim(20, 30)
+	assert.Equal(t, 0, l.Len())
+	_, ok = l.PopLeft()
+	assert.False(t, ok)
+}
+
+func TestListRemove(t *testing.T) {
+	fill := func() *m.List {
+		l := m.NewList()
+		for _, val := range []string{"a", "b", "a", "c", "a"} {
+			l.PushRight(val)
+		}
+		return l
+	}
+
+	l := fill()
+	assert.Equal(t, 2, l.Remove(2, "a"))
+	assert.Equal(t, []string{"b", "c", "a"}, l.Range(0, -1))
+
+	l = fill()
+	assert.Equal(t, 2, l.Remove(-2, "a"))
+	assert.Equal(t, []string{"a", "b", "c"}, l.Range(0, -1))
+
+	l = fill()
+	assert.Equal(t, 3, l.Remove(0, "a"))
+	assert.Equal(t, []string{"b", "c"}, l.Range(0, -1))
+	assert.Equal(t, 0, l.Remove(0, "missing"))
+}
+
+func TestListStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	length, err := s.Push(m.Key("l"), []string{"a", "b", "c"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, length)
+	assert.Equal(t, "list", s.Type(m.Key("l")))
+
+	popped, err := s.Pop(m.Key("l"), 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, popped)
+
+	// popping the last entry deletes the key
+	popped, _ = s.Pop(m.Key("l"), 5, true)
+	assert.Equal(t, []string{"c"}, popped)
+	assert.Equal(t, "none", s.Type(m.Key("l")))
+	popped, err = s.Pop(m.Key("l"), 1, true)
+	assert.NoError(t, err)
+	assert.Nil(t, popped)
+
+	// list commands on a string key
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, err = s.Push(m.Key("str"), []string{"a"}, true)
+	assert.Equal(t, m.ErrWrongType, err)
+	_, err = s.LLen(m.Key("str"))
+	assert.Equal(t, m.ErrWrongType, err)
+
+	// LMOVE validates the destination before popping
+	s.Push(m.Key("src"), []string{"a"}, false)
+	_, err = s.LMove(m.Key("src"), m.Key("str"), true, true)
+	assert.Equal(t, m.ErrWrongType, err)
+	length, _ = s.LLen(m.Key("src"))
+	assert.Equal(t, 1, length)
+}
+
+func TestListCommands(t *testing.T) {
+	wrongtype := "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	runSteps(t, []stepCase{
+		{"push pop and range", []step{
+			{args: []string{"RPUSH", "l", "a", "b", "c"}, reply: ":3\r\n"},
+			{args: []string{"LPUSH", "l", "z"}, reply: ":4\r\n"},
+			{args: []string{"LRANGE", "l", "0", "-1"}, reply: "*4\r\n$1\r\nz\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+			{args: []string{"LRANGE", "l", "-2", "100"}, reply: "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+			{args: []string{"LLEN", "l"}, reply: ":4\r\n"},
+			{args: []string{"LPOP", "l"}, reply: "$1\r\nz\r\n"},
+			{args: []string{"RPOP", "l", "2"}, reply: "*2\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+			{args: []string{"RPOP", "l", "0"}, reply: "*0\r\n"},
+			{args: []string{"LPOP", "l", "-1"}, reply: "-ERR value is out of range, must be positive\r\n"},
+			{args: []string{"LPOP", "l"}, reply: "$1\r\na\r\n"},
+			{args: []string{"LPOP", "l"}, reply: "$-1\r\n"},
+			{args: []string{"LPOP", "l", "1"}, reply: "*-1\r\n"},
+			{args: []string{"TYPE", "l"}, reply: "+none\r\n"},
+		}},
+		{"index set and insert", []step{
+			{args: []string{"RPUSH", "l", "a", "b", "c"}, reply: ":3\r\n"},
+			{args: []string{"LINDEX", "l", "-1"}, reply: "$1\r\nc\r\n"},
+			{args: []string{"LINDEX", "l", "3"}, reply: "$-1\r\n"},
+			{args: []string{"LINDEX", "l", "x"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"LSET", "l", "1", "B"}, reply: "+OK\r\n"},
+			{args: []string{"LSET", "l", "5", "B"}, reply: "-ERR index out of range\r\n"},
+			{args: []string{"LSET", "missing", "0", "B"}, reply: "-ERR no such key\r\n"},
+			{args: []string{"LINSERT", "l", "before", "B", "x"}, reply: ":4\r\n"},
+			{args: []string{"LINSERT", "l", "AFTER", "c", "y"}, reply: ":5\r\n"},
+			{args: []string{"LINSERT", "l", "AFTER", "none", "y"}, reply: ":-1\r\n"},
+			{args: []string{"LINSERT", "missing", "AFTER", "c", "y"}, reply: ":0\r\n"},
+			{args: []string{"LINSERT", "l", "AROUND", "c", "y"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"LRANGE", "l", "0", "-1"}, reply: "*5\r\n$1\r\na\r\n$1\r\nx\r\n$1\r\nB\r\n$1\r\nc\r\n$1\r\ny\r\n"},
+		}},
+		{"rem and trim", []step{
+			{args: []string{"RPUSH", "l", "a", "b", "a", "c", "a"}, reply: ":5\r\n"},
+			{args: []string{"LREM", "l", "-1", "a"}, reply: ":1\r\n"},
+			{args: []string{"LREM", "l", "0", "a"}, reply: ":2\r\n"},
+			{args: []string{"LTRIM", "l", "1", "-1"}, reply: "+OK\r\n"},
+			{args: []string{"LRANGE", "l", "0", "-1"}, reply: "*1\r\n$1\r\nc\r\n"},
+			{args: []string{"LTRIM", "l", "1", "0"}, reply: "+OK\r\n"},
+			{args: []string{"LLEN", "l"}, reply: ":0\r\n"},
+		}},
+		{"move", []step{
+			{args: []string{"RPUSH", "src", "a", "b"}, reply: ":2\r\n"},
+			{args: []string{"LMOVE", "src", "dst", "LEFT", "RIGHT"}, reply: "$1\r\na\r\n"},
+			{args: []string{"LMOVE", "src", "src", "right", "left"}, reply: "$1\r\nb\r\n"},
+			{args: []string{"LMOVE", "src", "dst", "LEFT", "LEFT"}, reply: "$1\r\nb\r\n"},
+			{args: []string{"LRANGE", "dst", "0", "-1"}, reply: "*2\r\n$1\r\nb\r\n$1\r\na\r\n"},
+			{args: []string{"LMOVE", "src", "dst", "LEFT", "LEFT"}, reply: "$-1\r\n"},
+			{args: []string{"LMOVE", "dst", "dst", "UP", "LEFT"}, reply: "-ERR syntax error\r\n"},
+		}},
+		{"wrongtype", []step{
+			{args: []string{"SET", "s", "v"}, reply: "+OK\r\n"},
+			{args: []string{"RPUSH", "l", "a"}, reply: ":1\r\n"},
+			{args: []string{"TYPE", "s"}, reply: "+string\r\n"},
+			{args: []string{"TYPE", "l"}, reply: "+list\r\n"},
+			{args: []string{"LPUSH", "s", "a"}, reply: wrongtype},
+			{args: []string{"LRANGE", "s", "0", "-1"}, reply: wrongtype},
+			{args: []string{"GET", "l"}, reply: wrongtype},
+			{args: []string{"APPEND", "l", "x"}, reply: wrongtype},
+			{args: []string{"INCR", "l"}, reply: wrongtype},
+			{args: []string{"MGET", "s", "l"}, reply: "*2\r\n$1\r\nv\r\n$-1\r\n"},
+			{args: []string{"SET", "l", "v"}, reply: "+OK\r\n"},
+			{args: []string{"TYPE", "l"}, reply: "+string\r\n"},
+		}},
+	})
+}
@@ -1,96 +1,351 @@
 package microredis
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
 )
 
+// SimpleString type denotes a RESP simple string reply such as +OK
+// which is sent as is rather than as a length prefixed bulk string
+type SimpleString string
+
+// MapReply type denotes a RESP3 map reply, it holds the keys and values
+// flattened as key1, value1, key2, value2... In RESP2 it is sent as a
+// flat array in the same order
+type MapReply []interface{}
+
+// SetReply type denotes a RESP3 set reply, in RESP2 it is sent as an array
+type SetReply []interface{}
+
+// PushReply type denotes a RESP3 out of band push message, in RESP2 it is
+// sent as an array
+type PushReply []interface{}
+
+// VerbatimReply type denotes a RESP3 verbatim string where Format is the
+// three letter format of Text such as txt or mkd. In RESP2 only Text is
+// sent as a bulk string
+type VerbatimReply struct {
+	Format string
+	Text   string
+}
+
+// NullArray type denotes a nil array reply, sent as *-1 in RESP2 and as
+// the null type in RESP3
+type NullArray struct{}
+
+// Other than the types above, the reply encoder maps float64 to RESP3
+// doubles, bool to RESP3 booleans and *big.Int to RESP3 big numbers.
+// In RESP2 doubles and big numbers are sent as bulk strings and booleans
+// as the integers 1 and 0
+
 // MarshalResp function takes any valid object and based on its type
-// converts it into a string
-// Note: Since we are only dealing with String datatype as values and
-// limited return value types from Storage class functions this function
-// is minimial in its implementation
+// converts it into a RESP2 encoded string. Every element is terminated
+// by \r\n and strings are sent as length prefixed bulk strings so that
+// values are binary safe
 func MarshalResp(i interface{}) string {
-	switch i.(type) {
+	return MarshalRespProto(i, 2)
+}
+
+// MarshalRespProto function is same as MarshalResp but encodes i in the
+// given protocol version, either 2 for RESP2 or 3 for RESP3
+func MarshalRespProto(i interface{}, proto int) string {
+	var b strings.Builder
+	marshalResp(&b, i, proto)
+	return b.String()
+}
+
+// marshalResp function writes the RESP encoding of i into b, aggregate
+// types are encoded by recursively encoding each of their elements
+func marshalResp(b *strings.Builder, i interface{}, proto int) {
+	switch v := i.(type) {
 	case int64:
-		return fmt.Sprintf(":%d#", i)
+		b.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
 	case int:
-		return fmt.Sprintf(":%d#", i)
+		b.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case SimpleString:
+		b.WriteString("+" + sanitizeLine(string(v)) + "\r\n")
 	case string:
-		return fmt.Sprintf("$%d#%s#", len(i.(string)), i.(string))
+		writeBulkString(b, v)
 	case Key:
-		return fmt.Sprintf("$%d#%s#", len(i.(Key)), i.(Key))
+		writeBulkString(b, string(v))
+	case float64:
+		if proto >= 3 {
+			b.WriteString("," + formatDouble(v) + "\r\n")
+		} else {
+			writeBulkString(b, formatDouble(v))
+		}
+	case bool:
+		if proto >= 3 {
+			if v {
+				b.WriteString("#t\r\n")
+			} else {
+				b.WriteString("#f\r\n")
+			}
+		} else if v {
+			b.WriteString(":1\r\n")
+		} else {
+			b.WriteString(":0\r\n")
+		}
+	case *big.Int:
+		if proto >= 3 {
+			b.WriteString("(" + v.String() + "\r\n")
+		} else {
+			writeBulkString(b, v.String())
+		}
+	case VerbatimReply:
+		if proto >= 3 {
+			b.WriteString("=" + strconv.Itoa(len(v.Text)+4) + "\r\n")
+			b.WriteString(v.Format + ":" + v.Text + "\r\n")
+		} else {
+			writeBulkString(b, v.Text)
+		}
 	case []string:
-		result := fmt.Sprintf("*%d#", len(i.([]string)))
-		for _, k := range i.([]string) {
-			result = result + fmt.Sprintf("$%d#%s#", len(k), k)
+		b.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, k := range v {
+			writeBulkString(b, k)
 		}
-		return result
 	case []Key:
-		result := fmt.Sprintf("*%d#", len(i.([]Key)))
-		for _, k := range i.([]Key) {
-			result = result + fmt.Sprintf("$%d#%s#", len(k), k)
+		b.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, k := range v {
+			writeBulkString(b, string(k))
+		}
+	case []interface{}:
+		writeAggregate(b, "*", v, len(v), proto)
+	case MapReply:
+		if proto >= 3 {
+			writeAggregate(b, "%", v, len(v)/2, proto)
+		} else {
+			writeAggregate(b, "*", v, len(v), proto)
+		}
+	case SetReply:
+		if proto >= 3 {
+			writeAggregate(b, "~", v, len(v), proto)
+		} else {
+			writeAggregate(b, "*", v, len(v), proto)
+		}
+	case PushReply:
+		if proto >= 3 {
+			writeAggregate(b, ">", v, len(v), proto)
+		} else {
+			writeAggregate(b, "*", v, len(v), proto)
+		}
+	case NullArray:
+		if proto >= 3 {
+			b.WriteString("_\r\n")
+		} else {
+			b.WriteString("*-1\r\n")
 		}
-		return result
 	case error:
-		result := fmt.Sprintf("-%s#", i.(error).Error())
-		return result
+		b.WriteString("-" + sanitizeLine(v.Error()) + "\r\n")
 	default:
 		if i == nil {
-			return "$-1#"
+			if proto >= 3 {
+				b.WriteString("_\r\n")
+			} else {
+				b.WriteString("$-1\r\n")
+			}
 		} else {
-			return fmt.Sprintf("-%s:%s#", "Internal Server Error", fmt.Sprintf("Failed to perform marshalling to this type %v", i))
+			b.WriteString(fmt.Sprintf("-%s:%s\r\n", "Internal Server Error", sanitizeLine(fmt.Sprintf("Failed to perform marshalling to this type %v", i))))
 		}
 	}
 }
 
+// writeAggregate function writes the header of an aggregate type with
+// the given prefix and count followed by each of the elements
+func writeAggregate(b *strings.Builder, prefix string, elems []interface{}, count int, proto int) {
+	b.WriteString(prefix + strconv.Itoa(count) + "\r\n")
+	for _, e := range elems {
+		marshalResp(b, e, proto)
+	}
+}
+
+// formatDouble function formats a float the way redis does in replies
+// using the shortest representation and inf, -inf and nan for the
+// special values
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// writeBulkString function writes s as a length prefixed RESP bulk string
+func writeBulkString(b *strings.Builder, s string) {
+	b.WriteString("$" + strconv.Itoa(len(s)) + "\r\n")
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
+
+// sanitizeLine function replaces \r and \n in s with spaces as simple
+// strings and errors can't contain them in RESP
+func sanitizeLine(s string) string {
+	if strings.ContainsAny(s, "\r\n") {
+		return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+	}
+	return s
+}
+
 // UnmarshalResp function is opposite to MarshalResp function but rather
 // than returning an interface{} object it returns an array of strings or error
 // The values in these strings could be strings, int or nil (as these are the values)
-// returned by our storage function
+// returned by our storage function. Nested arrays are flattened and a nil
+// bulk string or array is returned as "nil"
 func UnmarshalResp(s string) ([]string, error) {
-	switch s[0] {
+	result, _, err := unmarshalResp(s, 0)
+	if err != nil {
+		return []string{}, err
+	}
+	return result, nil
+}
+
+// unmarshalResp function parses the RESP element starting at index pos
+// of s and returns its values along with the index just after it
+func unmarshalResp(s string, pos int) ([]string, int, error) {
+	if pos >= len(s) {
+		return nil, pos, errors.New("Unable parse resp msg: unexpected end of msg")
+	}
+	line, next, err := respLine(s, pos)
+	if err != nil {
+		return nil, pos, err
+	}
+	switch s[pos] {
+	case byte('+'), byte(','), byte('('), byte('#'):
+		return []string{line}, next, nil
+	case byte('_'):
+		return []string{"nil"}, next, nil
 	case byte(':'):
-		i := strings.Index(s, "#")
-		_, err := strconv.ParseInt(s[1:i], 10, 0)
+		_, err := strconv.ParseInt(line, 10, 64)
 		if err != nil {
-			return []string{}, errors.New(fmt.Sprintf("Unable parse resp int %s", s[1:i]))
-		} else {
-			return []string{s[1:i]}, nil
+			return nil, pos, errors.New(fmt.Sprintf("Unable parse resp int %s", line))
 		}
-	case byte('$'):
-		i := strings.Index(s, "#")
-		val, err := strconv.ParseInt(s[1:i], 10, 32)
-		if err != nil {
-			return []string{}, errors.New(fmt.Sprintf("Unable parse resp string %s", s[1:i]))
+		return []string{line}, next, nil
+	case byte('$'), byte('='), byte('!'):
+		val, err := strconv.ParseInt(line, 10, 32)
+		if err != nil || val < -1 {
+			return nil, pos, errors.New(fmt.Sprintf("Unable parse resp string %s", line))
 		}
 		// handle nil case
 		if val == -1 {
-			return []string{"nil"}, nil
+			return []string{"nil"}, next, nil
 		}
-		result := strings.Split(s[i+1:len(s)-1], "#")
-		return result, nil
-	case byte('*'):
-		i := strings.Index(s, "#")
-		_, err := strconv.ParseInt(s[1:i], 10, 32)
-		if err != nil {
-			return []string{}, errors.New(fmt.Sprintf("Unable parse resp bulk %s", s[1:i]))
+		end := next + int(val)
+		if end+2 > len(s) || s[end:end+2] != "\r\n" {
+			return nil, pos, errors.New(fmt.Sprintf("Unable parse resp string of length %d", val))
+		}
+		switch s[pos] {
+		case byte('!'):
+			return nil, pos, errors.New(s[next:end])
+		case byte('='):
+			// skip the format prefix of verbatim strings
+			if val >= 4 {
+				return []string{s[next+4 : end]}, end + 2, nil
+			}
+		}
+		return []string{s[next:end]}, end + 2, nil
+	case byte('*'), byte('~'), byte('>'), byte('%'):
+		val, err := strconv.ParseInt(line, 10, 32)
+		if err != nil || val < -1 {
+			return nil, pos, errors.New(fmt.Sprintf("Unable parse resp bulk %s", line))
+		}
+		if val == -1 {
+			return []string{"nil"}, next, nil
+		}
+		if s[pos] == byte('%') {
+			val = val * 2
 		}
-		// split by $
 		result := []string{}
-		split_by_dollar := strings.Split(s[i+2:], "$")
-		for _, i := range split_by_dollar {
-			// split by #
-			j := strings.Split(i, "#")
-			result = append(result, j[1])
+		for j := int64(0); j < val; j++ {
+			elem, after, err := unmarshalResp(s, next)
+			if err != nil {
+				return nil, pos, err
+			}
+			result = append(result, elem...)
+			next = after
 		}
-		return result, nil
+		return result, next, nil
 	case byte('-'):
-		i := strings.Index(s, "#")
-		return []string{}, errors.New(s[1:i])
+		return nil, pos, errors.New(line)
+	default:
+		return nil, pos, errors.New(fmt.Sprintf("Unable parse resp msg |%s|", s))
+	}
+}
+
+// respLine function returns the contents of the line starting at index
+// pos of s without its type byte and \r\n terminator, along with the
+// index of the next line
+func respLine(s string, pos int) (string, int, error) {
+	i := strings.Index(s[pos:], "\r\n")
+	if i == -1 {
+		return "", pos, errors.New(fmt.Sprintf("Unable parse resp msg |%s|: missing \\r\\n terminator", s[pos:]))
+	}
+	return s[pos+1 : pos+i], pos + i + 2, nil
+}
+
+// ReadResp function reads exactly one complete RESP element from rd and
+// returns it in its raw encoded form so that it can be passed on to
+// UnmarshalResp. Bulk strings are read using their length prefix so they
+// may contain any bytes including \r\n
+func ReadResp(rd *bufio.Reader) (string, error) {
+	var b strings.Builder
+	if err := readResp(rd, &b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// readResp function reads one RESP element from rd into b
+func readResp(rd *bufio.Reader, b *strings.Builder) error {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return errors.New(fmt.Sprintf("Unable parse resp msg |%s|", strings.TrimRight(line, "\r\n")))
+	}
+	b.WriteString(line)
+	switch line[0] {
+	case byte('+'), byte('-'), byte(':'), byte('_'), byte(','), byte('('), byte('#'):
+		return nil
+	case byte('$'), byte('='), byte('!'):
+		val, err := strconv.ParseInt(line[1:len(line)-2], 10, 32)
+		if err != nil || val < -1 {
+			return errors.New(fmt.Sprintf("Unable parse resp string %s", line[1:len(line)-2]))
+		}
+		if val == -1 {
+			return nil
+		}
+		buf := make([]byte, val+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return err
+		}
+		b.Write(buf)
+		return nil
+	case byte('*'), byte('~'), byte('>'), byte('%'):
+		val, err := strconv.ParseInt(line[1:len(line)-2], 10, 32)
+		if err != nil || val < -1 {
+			return errors.New(fmt.Sprintf("Unable parse resp bulk %s", line[1:len(line)-2]))
+		}
+		if line[0] == byte('%') {
+			val = val * 2
+		}
+		for j := int64(0); j < val; j++ {
+			if err := readResp(rd, b); err != nil {
+				return err
+			}
+		}
+		return nil
 	default:
-		return []string{}, errors.New(fmt.Sprintf("Unable parse resp msg |%s|", s))
+		return errors.New(fmt.Sprintf("Unable parse resp msg |%s|", strings.TrimRight(line, "\r\n")))
 	}
 }
@@ -1,9 +1,14 @@
 package microredis_test
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/trueutkarsh/micro-redis/microredis"
@@ -14,14 +19,18 @@ func TestMarshalResp(t *testing.T) {
 		input  interface{}
 		result string
 	}{
-		{int64(123), ":123#"},
-		{int(456), ":456#"},
-		{"abc", "$3#abc#"},
-		{microredis.Key("hello"), "$5#hello#"},
-		{[]string{"key1", "key2"}, "*2#$4#key1#$4#key2#"},
-		{[]microredis.Key{"key3", "key4"}, "*2#$4#key3#$4#key4#"},
-		{errors.New("ERR: Test error"), "-ERR: Test error#"},
-		{nil, "$-1#"},
+		{int64(123), ":123\r\n"},
+		{int(456), ":456\r\n"},
+		{"abc", "$3\r\nabc\r\n"},
+		{"a#b$c\r\nd", "$8\r\na#b$c\r\nd\r\n"},
+		{"", "$0\r\n\r\n"},
+		{microredis.SimpleString("OK"), "+OK\r\n"},
+		{microredis.Key("hello"), "$5\r\nhello\r\n"},
+		{[]string{"key1", "key2"}, "*2\r\n$4\r\nkey1\r\n$4\r\nkey2\r\n"},
+		{[]microredis.Key{"key3", "key4"}, "*2\r\n$4\r\nkey3\r\n$4\r\nkey4\r\n"},
+		{[]interface{}{"a", nil, int64(1)}, "*3\r\n$1\r\na\r\n$-1\r\n:1\r\n"},
+		{errors.New("ERR: Test error"), "-ERR: Test error\r\n"},
+		{nil, "$-1\r\n"},
 	}
 
 	for _, cs := range cases {
@@ -42,14 +51,18 @@ func TestUnmarshalResp(t *testing.T) {
 		result []string
 		err    error
 	}{
-		{":12345#", []string{"12345"}, nil},
-		{"$5#hello#", []string{"hello"}, nil},
-		{"$-1#", []string{"nil"}, nil},
-		{"*2#$5#GET#$5#mykey#", []string{"GET", "mykey"}, nil},
-		{"*3#$5#SET#$5#mykey#$5#value#", []string{"SET", "mykey", "value"}, nil},
-		{"*2#$4#KEYS#$5#key.*#", []string{"KEYS", "key.*"}, nil},
-		{"*2#$4#KEYS#$4#key*#", []string{"KEYS", "key*"}, nil},
-		{"-Err: Custom Error#", []string{}, errors.New("Err: Custom Error")},
+		{":12345\r\n", []string{"12345"}, nil},
+		{"+OK\r\n", []string{"OK"}, nil},
+		{"$5\r\nhello\r\n", []string{"hello"}, nil},
+		{"$-1\r\n", []string{"nil"}, nil},
+		{"*2\r\n$3\r\nGET\r\n$5\r\nmykey\r\n", []string{"GET", "mykey"}, nil},
+		{"*3\r\n$3\r\nSET\r\n$5\r\nmykey\r\n$5\r\nvalue\r\n", []string{"SET", "mykey", "value"}, nil},
+		{"*2\r\n$4\r\nKEYS\r\n$5\r\nkey.*\r\n", []string{"KEYS", "key.*"}, nil},
+		{"*2\r\n$4\r\nKEYS\r\n$4\r\nkey*\r\n", []string{"KEYS", "key*"}, nil},
+		{"*3\r\n$3\r\nSET\r\n$3\r\nk#$\r\n$4\r\na\r\nb\r\n", []string{"SET", "k#$", "a\r\nb"}, nil},
+		{"-Err: Custom Error\r\n", []string{}, errors.New("Err: Custom Error")},
+		{"", []string{}, errors.New("Unable parse resp msg: unexpected end of msg")},
+		{"$5\r\nhi\r\n", []string{}, errors.New("Unable parse resp string of length 5")},
 	}
 
 	for _, cs := range cases {
@@ -61,10 +74,71 @@ func TestUnmarshalResp(t *testing.T) {
 					t.Errorf("got %v, want %v", ans, cs.result)
 				}
 			} else {
-				if err.Error() != cs.err.Error() {
+				if cs.err == nil || err.Error() != cs.err.Error() {
 					t.Errorf("got %v, want %v", err, cs.err)
 				}
 			}
 		})
 	}
 }
+
+func TestReadResp(t *testing.T) {
+	input := "*2\r\n$3\r\nGET\r\n$4\r\na\r\nb\r\n:1\r\n"
+	rd := bufio.NewReader(strings.NewReader(input))
+
+	msg, err := microredis.ReadResp(rd)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if msg != "*2\r\n$3\r\nGET\r\n$4\r\na\r\nb\r\n" {
+		t.Errorf("got %q", msg)
+	}
+
+	msg, err = microredis.ReadResp(rd)
+	if err != nil || msg != ":1\r\n" {
+		t.Errorf("got %q, %v", msg, err)
+	}
+
+	_, err = microredis.ReadResp(rd)
+	if err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestMarshalRespProto(t *testing.T) {
+	var cases = []struct {
+		input interface{}
+		resp2 string
+		resp3 string
+	}{
+		{nil, "$-1\r\n", "_\r\n"},
+		{1.5, "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{math.Inf(-1), "$4\r\n-inf\r\n", ",-inf\r\n"},
+		{true, ":1\r\n", "#t\r\n"},
+		{false, ":0\r\n", "#f\r\n"},
+		{big.NewInt(12345), "$5\r\n12345\r\n", "(12345\r\n"},
+		{microredis.VerbatimReply{Format: "txt", Text: "hi"}, "$2\r\nhi\r\n", "=6\r\ntxt:hi\r\n"},
+		{microredis.MapReply{"a", int64(1)}, "*2\r\n$1\r\na\r\n:1\r\n", "%1\r\n$1\r\na\r\n:1\r\n"},
+		{microredis.SetReply{"a"}, "*1\r\n$1\r\na\r\n", "~1\r\n$1\r\na\r\n"},
+		{microredis.PushReply{"a"}, "*1\r\n$1\r\na\r\n", ">1\r\n$1\r\na\r\n"},
+		{[]interface{}{nil, microredis.MapReply{}}, "*2\r\n$-1\r\n*0\r\n", "*2\r\n_\r\n%0\r\n"},
+	}
+
+	for _, cs := range cases {
+		t.Run(fmt.Sprintf("%v", cs.input), func(t *testing.T) {
+			if ans := microredis.MarshalRespProto(cs.input, 2); ans != cs.resp2 {
+				t.Errorf("resp2 got %q, want %q", ans, cs.resp2)
+			}
+			if ans := microredis.MarshalRespProto(cs.input, 3); ans != cs.resp3 {
+				t.Errorf("resp3 got %q, want %q", ans, cs.resp3)
+			}
+			// the client must be able to read back whatever the server sends
+			for _, encoded := range []string{cs.resp2, cs.resp3} {
+				raw, err := microredis.ReadResp(bufio.NewReader(strings.NewReader(encoded)))
+				if err != nil || raw != encoded {
+					t.Errorf("ReadResp got %q, %v", raw, err)
+				}
+			}
+		})
+	}
+}
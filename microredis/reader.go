@@ -0,0 +1,284 @@
+package microredis
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Default limits for requests read by RespReader, these are the same
+// defaults that redis uses for proto-max-bulk-len and the maximum
+// number of arguments of a single command
+const (
+	DefaultMaxBulkLen      int64 = 512 * 1024 * 1024
+	DefaultMaxMultiBulkLen int64 = 1024 * 1024
+	maxInlineLen                 = 64 * 1024
+)
+
+// ProtocolError type denotes a malformed request received from a client.
+// After a protocol error the stream can't be trusted anymore so the
+// connection is supposed to be closed once the error is sent back
+type ProtocolError struct {
+	msg string
+}
+
+// Error function returns the error in the same form as redis does
+func (e *ProtocolError) Error() string {
+	return "ERR Protocol error: " + e.msg
+}
+
+// RespReader struct reads client requests one command at a time from
+// a buffered reader. A request is either a RESP array of bulk strings
+// or an inline command, a single line of space separated arguments.
+// MaxBulkLen and MaxMultiBulkLen bound the length of a single argument
+// and the number of arguments so that a malicious or broken client can't
+// make the server allocate unbounded memory
+type RespReader struct {
+	rd              *bufio.Reader
+	MaxBulkLen      int64
+	MaxMultiBulkLen int64
+}
+
+// NewRespReader function creates and initializes a RespReader reading
+// from rd with the default request limits
+func NewRespReader(rd *bufio.Reader) *RespReader {
+	result := RespReader{
+		rd:              rd,
+		MaxBulkLen:      DefaultMaxBulkLen,
+		MaxMultiBulkLen: DefaultMaxMultiBulkLen,
+	}
+	return &result
+}
+
+// Buffered function returns the number of bytes that have already been
+// received from the client but not consumed yet
+func (r *RespReader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+// ReadCommand function reads exactly one command from the underlying
+// reader and returns its arguments. An empty slice is returned for empty
+// requests which are supposed to be skipped. io.EOF is returned when the
+// client closed the connection in between commands and a *ProtocolError
+// when the request is malformed
+func (r *RespReader) ReadCommand() ([]string, error) {
+	b, err := r.rd.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] == '*' {
+		return r.readMultiBulk()
+	}
+	return r.readInline()
+}
+
+// readMultiBulk function reads a command sent as a RESP array of bulk
+// strings
+func (r *RespReader) readMultiBulk() ([]string, error) {
+	line, err := r.readLine(maxInlineLen)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil || count > r.MaxMultiBulkLen {
+		return nil, &ProtocolError{"invalid multibulk length"}
+	}
+	if count <= 0 {
+		return []string{}, nil
+	}
+
+	result := make([]string, 0, count)
+	for i := int64(0); i < count; i++ {
+		line, err := r.readLine(maxInlineLen)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if len(line) == 0 || line[0] != '$' {
+			if len(line) == 0 {
+				return nil, &ProtocolError{"expected '$', got ' '"}
+			}
+			return nil, &ProtocolError{fmt.Sprintf("expected '$', got '%c'", line[0])}
+		}
+		size, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil || size < 0 || size > r.MaxBulkLen {
+			return nil, &ProtocolError{"invalid bulk length"}
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r.rd, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, &ProtocolError{"bulk string not terminated by CRLF"}
+		}
+		result = append(result, string(buf[:size]))
+	}
+	return result, nil
+}
+
+// readInline function reads a command sent as a single line of space
+// separated arguments, arguments can be quoted as explained in splitArgs
+func (r *RespReader) readInline() ([]string, error) {
+	line, err := r.readLine(maxInlineLen)
+	if err != nil {
+		return nil, err
+	}
+	result, err := splitArgs(string(line))
+	if err != nil {
+		return nil, &ProtocolError{"unbalanced quotes in request"}
+	}
+	return result, nil
+}
+
+// splitArgs function splits a line into arguments the way a shell would
+// Arguments are separated by spaces and may be quoted. Inside double
+// quotes the escape sequences \n, \r, \t, \b, \a, \\, \" and \xHH for an
+// arbitrary byte are supported, inside single quotes only \' is. A closing
+// quote has to be followed by a space or the end of the line
+func splitArgs(line string) ([]string, error) {
+	result := []string{}
+	i := 0
+	for {
+		// skip blanks
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return result, nil
+		}
+
+		var arg strings.Builder
+		in_dquotes := false
+		in_squotes := false
+		done := false
+		for !done {
+			if in_dquotes {
+				if i == len(line) {
+					return nil, errors.New("unbalanced quotes")
+				}
+				if line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHexDigit(line[i+2]) && isHexDigit(line[i+3]) {
+					arg.WriteByte(hexDigitToInt(line[i+2])*16 + hexDigitToInt(line[i+3]))
+					i += 3
+				} else if line[i] == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						arg.WriteByte('\n')
+					case 'r':
+						arg.WriteByte('\r')
+					case 't':
+						arg.WriteByte('\t')
+					case 'b':
+						arg.WriteByte('\b')
+					case 'a':
+						arg.WriteByte('\a')
+					default:
+						arg.WriteByte(line[i])
+					}
+				} else if line[i] == '"' {
+					// closing quote must be followed by a space or end of line
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errors.New("unbalanced quotes")
+					}
+					done = true
+				} else {
+					arg.WriteByte(line[i])
+				}
+			} else if in_squotes {
+				if i == len(line) {
+					return nil, errors.New("unbalanced quotes")
+				}
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					arg.WriteByte('\'')
+				} else if line[i] == '\'' {
+					// closing quote must be followed by a space or end of line
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errors.New("unbalanced quotes")
+					}
+					done = true
+				} else {
+					arg.WriteByte(line[i])
+				}
+			} else {
+				if i == len(line) {
+					done = true
+					break
+				}
+				switch line[i] {
+				case ' ', '\n', '\r', '\t', 0:
+					done = true
+				case '"':
+					in_dquotes = true
+				case '\'':
+					in_squotes = true
+				default:
+					arg.WriteByte(line[i])
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		result = append(result, arg.String())
+	}
+}
+
+// isSpace function reports whether c separates arguments of an inline
+// command
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// isHexDigit function reports whether c is a hexadecimal digit
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// hexDigitToInt function converts a hexadecimal digit to its value
+func hexDigitToInt(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// readLine function reads a single line terminated by \n and returns it
+// without the \r\n or \n terminator. Lines longer than limit result in a
+// protocol error rather than growing the buffer indefinitely
+func (r *RespReader) readLine(limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		if len(line)+len(chunk) > limit {
+			return nil, &ProtocolError{"too big request"}
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		break
+	}
+	line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+	return line, nil
+}
+
+// unexpectedEOF function converts an io.EOF in the middle of a request
+// into io.ErrUnexpectedEOF so that it can be told apart from a client
+// closing the connection in between commands
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package microredis_test
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestReadCommand(t *testing.T) {
+	var cases = []struct {
+		input  string
+		result [][]string
+	}{
+		{"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", [][]string{{"GET", "key"}}},
+		{"*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$4\r\na\nb\n\r\n", [][]string{{"SET", "k", "a\nb\n"}}},
+		{"*1\r\n$4\r\nPING\r\n*1\r\n$4\r\nPING\r\n", [][]string{{"PING"}, {"PING"}}},
+		{"*0\r\n", [][]string{{}}},
+		{"PING\r\n", [][]string{{"PING"}}},
+		{"SET  foo   bar\n", [][]string{{"SET", "foo", "bar"}}},
+		{"\r\n", [][]string{{}}},
+		{"SET k \"hello world\"\r\n", [][]string{{"SET", "k", "hello world"}}},
+		{"SET k 'it\\'s'\r\n", [][]string{{"SET", "k", "it's"}}},
+		{"SET k 'a\\nb'\r\n", [][]string{{"SET", "k", "a\\nb"}}},
+		{"SET k \"a\\nb\\t\\\"c\\\"\\x41\\\\\"\r\n", [][]string{{"SET", "k", "a\nb\t\"c\"A\\"}}},
+		{"SET \"\" ''\r\nGET x\r\n", [][]string{{"SET", "", ""}, {"GET", "x"}}},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.input, func(t *testing.T) {
+			r := m.NewRespReader(bufio.NewReader(strings.NewReader(cs.input)))
+			for _, expected := range cs.result {
+				commands, err := r.ReadCommand()
+				assert.Nil(t, err)
+				assert.Equal(t, expected, commands)
+			}
+			_, err := r.ReadCommand()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestReadCommandLargeValue(t *testing.T) {
+	val := strings.Repeat("x", 100*1024)
+	input := m.MarshalResp([]string{"SET", "key", val})
+	r := m.NewRespReader(bufio.NewReader(strings.NewReader(input)))
+	commands, err := r.ReadCommand()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"SET", "key", val}, commands)
+}
+
+func TestReadCommandErrors(t *testing.T) {
+	var cases = []struct {
+		input string
+		err   string
+	}{
+		{"*x\r\n", "ERR Protocol error: invalid multibulk length"},
+		{"*3\r\n", io.ErrUnexpectedEOF.Error()},
+		{"*1\r\n:1\r\n", "ERR Protocol error: expected '$', got ':'"},
+		{"*1\r\n$-5\r\n", "ERR Protocol error: invalid bulk length"},
+		{"*1\r\n$20\r\n", "ERR Protocol error: invalid bulk length"},
+		{"*1\r\n$3\r\nGETXX", "ERR Protocol error: bulk string not terminated by CRLF"},
+		{"*1\r\n$3\r\nGE", io.ErrUnexpectedEOF.Error()},
+		{"*5\r\n$3\r\nGET\r\n", "ERR Protocol error: invalid multibulk length"},
+		{strings.Repeat("a", 70*1024) + "\r\n", "ERR Protocol error: too big request"},
+		{"SET k \"unterminated\r\n", "ERR Protocol error: unbalanced quotes in request"},
+		{"SET k 'abc'def\r\n", "ERR Protocol error: unbalanced quotes in request"},
+	}
+
+	for _, cs := range cases {
+		name := cs.input
+		if len(name) > 20 {
+			name = name[:20]
+		}
+		t.Run(name, func(t *testing.T) {
+			r := m.NewRespReader(bufio.NewReader(strings.NewReader(cs.input)))
+			r.MaxBulkLen = 10
+			r.MaxMultiBulkLen = 4
+			_, err := r.ReadCommand()
+			if assert.NotNil(t, err) {
+				assert.Equal(t, cs.err, err.Error())
+			}
+		})
+	}
+}
@@ -1,14 +1,15 @@
 package microredis
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,11 +21,19 @@ import (
 // and there is a expired keys clean up goroutine as well, so as to
 // maintain consitent state and avoid race conditions we have a mutex
 // pointer lock which essentially guards the storage/db
+// max_bulk_len and max_multibulk_len are the limits on size of a single
+// argument and number of arguments in requests read from clients
+// waiters holds for each key the clients parked on it by blocking commands
+// in the order they were parked, it is guarded by lock as well
 type Server struct {
-	db      *Storage
-	address string
-	port    string
-	lock    *sync.Mutex
+	db                *Storage
+	address           string
+	port              string
+	lock              *sync.Mutex
+	max_bulk_len      int64
+	max_multibulk_len int64
+	next_client_id    int64
+	waiters           map[Key][]*waiter
 }
 
 // NewServer creates and initializes a server instance and returns
@@ -35,10 +44,22 @@ func NewServer(address string, port string, clear_freq time.Duration) *Server {
 		address: address,
 		port:    port,
 		lock:    &sync.Mutex{},
+
+		max_bulk_len:      DefaultMaxBulkLen,
+		max_multibulk_len: DefaultMaxMultiBulkLen,
+		waiters:           make(map[Key][]*waiter),
 	}
 	return &result
 }
 
+// SetRequestLimits function sets the maximum length of a single argument
+// and the maximum number of arguments the server accepts in one request
+// Connections sending bigger requests get a protocol error and are closed
+func (s *Server) SetRequestLimits(max_bulk_len int64, max_multibulk_len int64) {
+	s.max_bulk_len = max_bulk_len
+	s.max_multibulk_len = max_multibulk_len
+}
+
 // Run function is the starting point for Redis server functionality
 // It starts listening for tcp connections with address initialized
 // Then it starts a background goroutine to clear expired keys
@@ -76,211 +97,252 @@ func (s *Server) Run() {
 // from the Redis client. Then it reads RESP messages from the tcp connection
 // and processes it, get the response, marshal it and send it back to Redis
 // client and do this continuously
+// Clients may pipeline commands, i.e. send many of them without waiting for
+// replies. So every command already received is read as one batch which is
+// executed in order under a single acquisition of the lock, and the replies
+// of the whole batch are flushed to the client in one write
+// A blocking command which can't be served parks the connection, the lock
+// is released while parked and the rest of the batch is executed once the
+// blocking command replies. Clients parked on keys modified by the batch
+// are served before the lock is released
 func (s *Server) HandleConnection(conn net.Conn) {
 	defer conn.Close()
-	scanner := bufio.NewScanner(conn)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "quit" {
-			return
-		}
+	c := NewConnection(conn, atomic.AddInt64(&s.next_client_id, 1))
+	c.reader.MaxBulkLen = s.max_bulk_len
+	c.reader.MaxMultiBulkLen = s.max_multibulk_len
 
-		if line == "" || line == "\n" {
-			continue
+	for {
+		batch, read_err := c.ReadBatch()
+
+		quit := false
+		s.lock.Lock() // aquire lock
+		for _, commands := range batch {
+			response, err := s.ProcessRESP(c, commands)
+			if b, ok := response.(blockOn); ok && err == nil {
+				if response, ok = s.park(c, commands, b); !ok {
+					s.lock.Unlock()
+					return
+				}
+			}
+			if err != nil {
+				c.WriteReply(err)
+			} else {
+				c.WriteReply(response)
+			}
+			if strings.ToLower(commands[0]) == "quit" {
+				quit = true
+				break
+			}
 		}
+		s.serveBlocked()
+		s.lock.Unlock() // release lock when batch processed
 
-		var result string
-		response, err := s.ProcessRESP(line)
-
-		if err != nil {
-			result = MarshalResp(err)
-		} else {
-			result = MarshalResp(response)
+		if _, ok := read_err.(*ProtocolError); ok && !quit {
+			c.WriteReply(read_err)
 		}
-		if _, err := conn.Write([]byte(result + "\n")); err != nil {
+		if err := c.Flush(); err != nil {
 			fmt.Printf("Failed to write response: %v\n", err)
-			continue
+			return
+		}
+		if quit || read_err != nil {
+			return
 		}
 	}
 }
 
-// ProcessRESP function unmarshals the msg it receives from tcp connection
-// into an array of commands strings. Then for each command there is a specific
-// db operation which needs to be called and the result from it is returned
-func (s *Server) ProcessRESP(msg string) (interface{}, error) {
-	s.lock.Lock()         // aquire lock
-	defer s.lock.Unlock() // release lock when processing done
-	commands, err := UnmarshalResp(msg)
-	if err != nil {
-		return "", err
+// ProcessRESP function takes the array of commands strings unmarshalled
+// from a RESP msg sent over connection c. Then the command is looked up in
+// the command table, its number of arguments is validated against its
+// arity and its handler performs the db operation whose result is returned
+// Note: the caller has to hold the server lock while calling this function
+func (s *Server) ProcessRESP(c *Connection, commands []string) (interface{}, error) {
+	cmd := LookupCommand(commands[0])
+	if cmd == nil {
+		return nil, unknownCommandError(commands)
 	}
-	switch commands[0] {
-	case "GET":
-		return s.ProcessRespCommandGet(commands)
-
-	case "SET":
-		return s.ProcessRespCommandSet(commands)
-
-	case "DEL":
-		return s.ProcessRespCommandDel(commands)
-
-	case "EXPIRE":
-		return s.ProcessRespCommandExpire(commands)
-
-	case "TTL":
-		return s.ProcessRespCommandTTL(commands)
-
-	case "KEYS":
-		return s.ProcessRespCommandKeys(commands)
-
-	default:
-		return nil, errors.New(fmt.Sprintf("Invalid command %s", commands[0]))
+	if err := cmd.CheckArity(commands); err != nil {
+		return nil, err
 	}
-
+	return cmd.Handler(s, c, commands)
 }
 
 // ProcessRespCommandGet function processes redis command GET
-func (s *Server) ProcessRespCommandGet(commands []string) (interface{}, error) {
-	if len(commands) > 2 {
-		return nil, errors.New("ERR Wrong number of arguments")
-	}
-	result := s.db.Get(Key(commands[1]))
+func (s *Server) ProcessRespCommandGet(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.Get(Key(commands[1]))
 	if result == nil {
-		return nil, nil
+		return nil, err
 	} else {
 		return *result, nil
 	}
 }
 
 // ProcessRespCommandSet function processes redis command SET
-func (s *Server) ProcessRespCommandSet(commands []string) (interface{}, error) {
-	if len(commands) > 7 || len(commands) < 3 {
-		return nil, errors.New("ERR Wrong number of commands")
-	}
-
+// Only one of NX and XX and only one of EX, PX, EXAT, PXAT and KEEPTTL can
+// be given. With GET the old value is replied even when NX or XX prevent
+// the value from being set
+// Other than the redis options IFVER version sets the value ONLY if the
+// current version of the key is version, which makes SET a compare and set
+// operation. Versions start at 1 so IFVER 0 only sets a key which does not
+// exist
+func (s *Server) ProcessRespCommandSet(c *Connection, commands []string) (interface{}, error) {
 	key := Key(commands[1])
 	val := commands[2]
-	var exp time.Time
+	var exp *time.Time
 	ret_old_val := false
 	keep_ttl := false
 	set_if_exists := false
 	set_if_not_exists := false
 	expiry_set := false
+	var if_version *int64
 
-	args := make(map[string]string)
-
-	i := 3
-	for {
-		if i == len(commands) {
-			break
-		}
-		if strings.ToUpper(commands[i]) == "NX" {
-			args["NX"] = "true"
-			if _, prs := args["XX"]; prs {
-				return nil, errors.New("ERR Invalid args NX and XX can't be present together")
-			}
+	for i := 3; i < len(commands); i++ {
+		opt := strings.ToUpper(commands[i])
+		if opt == "NX" && !set_if_exists {
 			set_if_not_exists = true
-			i += 1
-		} else if strings.ToUpper(commands[i]) == "XX" {
-			args["XX"] = "true"
-			if _, prs := args["NX"]; prs {
-				return nil, errors.New("ERR Invalid args NX and XX can't be present together")
-			}
+		} else if opt == "XX" && !set_if_not_exists {
 			set_if_exists = true
-			i += 1
-		} else if strings.ToUpper(commands[i]) == "GET" {
+		} else if opt == "GET" {
 			ret_old_val = true
-			i += 1
-		} else if strings.ToUpper(commands[i]) == "EX" {
-			if !expiry_set {
-				sec, err := strconv.ParseFloat(commands[i+1], 64)
-				if err != nil {
-					return nil, errors.New(fmt.Sprintf("ERR Invalid args, unable to parse %s", commands[i+1]))
-				}
-				exp = time.Now().Add(time.Duration(float64(sec) * float64(time.Second)))
-				i += 2
-				expiry_set = true
-			} else {
-				return nil, errors.New("Invalid Args, multiple expiry provided")
+		} else if opt == "KEEPTTL" && !expiry_set {
+			keep_ttl = true
+			expiry_set = true
+		} else if (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && !expiry_set && i+1 < len(commands) {
+			t, err := parseExpireTime(opt, commands[i+1], "set")
+			if err != nil {
+				return nil, err
 			}
-		} else if strings.ToUpper(commands[i]) == "PX" {
-			if !expiry_set {
-				milsec, err := strconv.ParseInt(commands[i+1], 10, 64)
-				if err != nil {
-					return nil, errors.New(fmt.Sprintf("ERR Invalid args, unable to parse %s", commands[i+1]))
-				}
-				exp = time.Now().Add(time.Duration(float64(milsec) * float64(time.Millisecond)))
-				i += 2
-				expiry_set = true
-			} else {
-				return nil, errors.New("Invalid Args, multiple expiry provided")
-			}
-		} else if strings.ToUpper(commands[i]) == "EXAT" {
-			if !expiry_set {
-				sec, err := strconv.ParseInt(commands[i+1], 10, 64)
-				if err != nil {
-					return nil, errors.New(fmt.Sprintf("ERR Invalid args, unable to parse %s", commands[i+1]))
-				}
-				exp = time.Unix(sec, 0)
-				i += 2
-				expiry_set = true
-			} else {
-				return nil, errors.New("Invalid Args, multiple expiry provided")
-			}
-		} else if strings.ToUpper(commands[i]) == "PXAT" {
-			if !expiry_set {
-				milsec, err := strconv.ParseInt(commands[i+1], 10, 64)
-				if err != nil {
-					return nil, errors.New(fmt.Sprintf("ERR Invalid args, unable to parse %s", commands[i+1]))
-				}
-				exp = time.Unix(0, milsec*1000)
-				i += 2
-				expiry_set = true
-			} else {
-				return nil, errors.New("Invalid Args, multiple expiry provided")
-			}
-		} else if strings.ToUpper(commands[i]) == "KEEPTTL" {
-			if !expiry_set {
-				// exp = nil
-				i += 1
-				expiry_set = true
-			} else {
-				return nil, errors.New("Invalid Args, multiple expiry provided")
+			exp = &t
+			expiry_set = true
+			i += 1
+		} else if opt == "IFVER" && if_version == nil && i+1 < len(commands) {
+			version, err := strconv.ParseInt(commands[i+1], 10, 64)
+			if err != nil || version < 0 {
+				return nil, ErrNotInteger
 			}
+			if_version = &version
+			i += 1
 		} else {
-			return nil, errors.New(fmt.Sprintf("Invalid Arg: %s", commands[i]))
+			return nil, ErrSyntax
 		}
-
 	}
 
-	var success bool
 	var old_val *string
-	if keep_ttl || !expiry_set {
-		success, old_val = s.db.Set(key, val, nil, ret_old_val, keep_ttl, set_if_exists, set_if_not_exists)
-	} else {
-		success, old_val = s.db.Set(key, val, &exp, ret_old_val, keep_ttl, set_if_exists, set_if_not_exists)
+	if ret_old_val {
+		var err error
+		if old_val, err = s.db.Get(key); err != nil {
+			return nil, err
+		}
+	}
+	success := false
+	if if_version == nil || s.db.Version(key) == *if_version {
+		success, _ = s.db.Set(key, val, exp, false, keep_ttl, set_if_exists, set_if_not_exists)
+	}
+	if ret_old_val {
+		if old_val == nil {
+			return nil, nil
+		}
+		return *old_val, nil
 	}
 	if !success {
 		return nil, nil
-	} else {
-		if !ret_old_val {
-			return "OK", nil
-		} else {
-			if old_val == nil {
-				return nil, nil
-			} else {
-				return *old_val, nil
+	}
+	return SimpleString("OK"), nil
+}
+
+// parseExpireTime function parses the argument of the expiry options EX,
+// PX, EXAT and PXAT into the absolute time at which the key expires.
+// Following redis the argument has to be a positive integer, name is the
+// command being parsed which is mentioned in the error
+func parseExpireTime(opt string, arg string, name string) (time.Time, error) {
+	val, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrNotInteger
+	}
+	invalid := errors.New(fmt.Sprintf("ERR invalid expire time in '%s' command", name))
+	if val <= 0 {
+		return time.Time{}, invalid
+	}
+	switch opt {
+	case "EX", "EXAT":
+		if val > math.MaxInt64/1000 {
+			return time.Time{}, invalid
+		}
+		val *= 1000
+	}
+	switch opt {
+	case "EX", "PX":
+		now := time.Now().UnixMilli()
+		if val > math.MaxInt64-now {
+			return time.Time{}, invalid
+		}
+		return time.UnixMilli(now + val), nil
+	default:
+		return time.UnixMilli(val), nil
+	}
+}
+
+// ProcessRespCommandGetDel function processes redis command GETDEL
+func (s *Server) ProcessRespCommandGetDel(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.GetDel(Key(commands[1]))
+	if result == nil {
+		return nil, err
+	}
+	return *result, nil
+}
+
+// ProcessRespCommandGetEx function processes redis command GETEX
+func (s *Server) ProcessRespCommandGetEx(c *Connection, commands []string) (interface{}, error) {
+	var exp *time.Time
+	persist := false
+	if len(commands) > 2 {
+		opt := strings.ToUpper(commands[2])
+		if opt == "PERSIST" && len(commands) == 3 {
+			persist = true
+		} else if (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && len(commands) == 4 {
+			t, err := parseExpireTime(opt, commands[3], "getex")
+			if err != nil {
+				return nil, err
 			}
+			exp = &t
+		} else {
+			return nil, ErrSyntax
 		}
 	}
+	result, err := s.db.GetEx(Key(commands[1]), exp, persist)
+	if result == nil {
+		return nil, err
+	}
+	return *result, nil
 }
 
-// ProcessRespCommandDel function processes redis command DEL
-func (s *Server) ProcessRespCommandDel(commands []string) (interface{}, error) {
-	if len(commands) < 2 {
-		return nil, errors.New("ERR Invalid number of args")
+// ProcessRespCommandGetVer function processes the command GETVER which is
+// not part of redis. It replies with the value of key along with its
+// version, which can be passed to SET IFVER and DELIFVER, or a nil array
+// if the key does not exist
+func (s *Server) ProcessRespCommandGetVer(c *Connection, commands []string) (interface{}, error) {
+	result, version, err := s.db.GetVer(Key(commands[1]))
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return NullArray{}, nil
+	}
+	return []interface{}{*result, version}, nil
+}
+
+// ProcessRespCommandDelIfVer function processes the command DELIFVER which
+// is not part of redis. It deletes key ONLY if its version matches the
+// given one and replies with the number of keys deleted
+func (s *Server) ProcessRespCommandDelIfVer(c *Connection, commands []string) (interface{}, error) {
+	version, err := strconv.ParseInt(commands[2], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
 	}
+	return s.db.DelIfVer(Key(commands[1]), version), nil
+}
+
+// ProcessRespCommandDel function processes redis command DEL
+func (s *Server) ProcessRespCommandDel(c *Connection, commands []string) (interface{}, error) {
 	keys := make([]Key, 0)
 	for _, c := range commands[1:] {
 		keys = append(keys, Key(c))
@@ -289,51 +351,108 @@ func (s *Server) ProcessRespCommandDel(commands []string) (interface{}, error) {
 }
 
 // ProcessRespCommandExpire function processes redis command EXPIRE
-func (s *Server) ProcessRespCommandExpire(commands []string) (interface{}, error) {
-	if len(commands) < 3 || len(commands) > 4 {
-		return nil, errors.New("ERR invalid number of args")
-	}
+func (s *Server) ProcessRespCommandExpire(c *Connection, commands []string) (interface{}, error) {
+	return s.processExpire(commands, time.Second, false)
+}
 
+// ProcessRespCommandPExpire function processes redis command PEXPIRE
+func (s *Server) ProcessRespCommandPExpire(c *Connection, commands []string) (interface{}, error) {
+	return s.processExpire(commands, time.Millisecond, false)
+}
+
+// ProcessRespCommandExpireAt function processes redis command EXPIREAT
+func (s *Server) ProcessRespCommandExpireAt(c *Connection, commands []string) (interface{}, error) {
+	return s.processExpire(commands, time.Second, true)
+}
+
+// ProcessRespCommandPExpireAt function processes redis command PEXPIREAT
+func (s *Server) ProcessRespCommandPExpireAt(c *Connection, commands []string) (interface{}, error) {
+	return s.processExpire(commands, time.Millisecond, true)
+}
+
+// processExpire function implements the EXPIRE family of commands which
+// take a key, a time in the given unit which is relative to now unless
+// absolute is set, and any of the NX, XX, GT and LT conditions
+func (s *Server) processExpire(commands []string, unit time.Duration, absolute bool) (interface{}, error) {
 	key := Key(commands[1])
-	secs, err := strconv.ParseInt(commands[2], 10, 64)
+	val, err := strconv.ParseInt(commands[2], 10, 64)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("ERR Invalid arg: Unable to parse sec argument %s", commands[2]))
+		return nil, ErrNotInteger
 	}
+
 	set_if_no_expiry := false
 	set_if_expiry := false
 	set_if_gt := false
 	set_if_lt := false
-
-	if len(commands) == 4 {
-		if commands[3] == "NX" {
+	for _, opt := range commands[3:] {
+		switch strings.ToUpper(opt) {
+		case "NX":
 			set_if_no_expiry = true
-		} else if commands[3] == "XX" {
+		case "XX":
 			set_if_expiry = true
-		} else if commands[3] == "GT" {
+		case "GT":
 			set_if_gt = true
-		} else if commands[3] == "LT" {
+		case "LT":
 			set_if_lt = true
+		default:
+			return nil, errors.New(fmt.Sprintf("ERR Unsupported option %s", opt))
+		}
+	}
+	if set_if_no_expiry && (set_if_expiry || set_if_gt || set_if_lt) {
+		return nil, errors.New("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if set_if_gt && set_if_lt {
+		return nil, errors.New("ERR GT and LT options at the same time are not compatible")
+	}
+
+	// convert to milliseconds guarding against overflows
+	invalid := errors.New(fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(commands[0])))
+	ms := val
+	if unit == time.Second {
+		if val > math.MaxInt64/1000 || val < math.MinInt64/1000 {
+			return nil, invalid
+		}
+		ms = val * 1000
+	}
+	if !absolute {
+		now := time.Now().UnixMilli()
+		if ms > math.MaxInt64-now {
+			return nil, invalid
 		}
+		ms += now
 	}
 
-	return s.db.Expire(key, secs, set_if_no_expiry, set_if_expiry, set_if_gt, set_if_lt), nil
+	return s.db.PExpireAt(key, time.UnixMilli(ms), set_if_no_expiry, set_if_expiry, set_if_gt, set_if_lt), nil
+}
+
+// ProcessRespCommandPersist function processes redis command PERSIST
+func (s *Server) ProcessRespCommandPersist(c *Connection, commands []string) (interface{}, error) {
+	return s.db.Persist(Key(commands[1])), nil
 }
 
 // ProcessRespCommandTTL function processes the redis commmand TTL
-func (s *Server) ProcessRespCommandTTL(commands []string) (interface{}, error) {
-	if len(commands) != 2 {
-		return nil, errors.New("ERR Invalid number of args")
-	}
+func (s *Server) ProcessRespCommandTTL(c *Connection, commands []string) (interface{}, error) {
 	result := s.db.TTL(Key(commands[1]))
 	return result, nil
 }
 
-// ProcessRespCommandKeys function processes the redis command KEYS
-func (s *Server) ProcessRespCommandKeys(commands []string) (interface{}, error) {
-	if len(commands) != 2 {
-		return nil, errors.New("ERR Invalid number of args")
-	}
+// ProcessRespCommandPTTL function processes the redis commmand PTTL
+func (s *Server) ProcessRespCommandPTTL(c *Connection, commands []string) (interface{}, error) {
+	return s.db.PTTL(Key(commands[1])), nil
+}
 
+// ProcessRespCommandExpireTime function processes the redis commmand EXPIRETIME
+func (s *Server) ProcessRespCommandExpireTime(c *Connection, commands []string) (interface{}, error) {
+	return s.db.ExpireTime(Key(commands[1])), nil
+}
+
+// ProcessRespCommandPExpireTime function processes the redis commmand PEXPIRETIME
+func (s *Server) ProcessRespCommandPExpireTime(c *Connection, commands []string) (interface{}, error) {
+	return s.db.PExpireTime(Key(commands[1])), nil
+}
+
+// ProcessRespCommandKeys function processes the redis command KEYS
+func (s *Server) ProcessRespCommandKeys(c *Connection, commands []string) (interface{}, error) {
 	result, err := s.db.Keys(commands[1])
 	if err != nil {
 		return nil, err
@@ -341,3 +460,75 @@ func (s *Server) ProcessRespCommandKeys(commands []string) (interface{}, error)
 		return result, nil
 	}
 }
+
+// ProcessRespCommandType function processes redis command TYPE
+func (s *Server) ProcessRespCommandType(c *Connection, commands []string) (interface{}, error) {
+	return SimpleString(s.db.Type(Key(commands[1]))), nil
+}
+
+// ProcessRespCommandObject function processes redis command OBJECT, of
+// its subcommands only ENCODING is supported
+func (s *Server) ProcessRespCommandObject(c *Connection, commands []string) (interface{}, error) {
+	if strings.ToUpper(commands[1]) != "ENCODING" {
+		return nil, errors.New(fmt.Sprintf("ERR unknown subcommand '%s'. Try OBJECT HELP.", commands[1]))
+	}
+	if len(commands) != 3 {
+		return nil, errors.New("ERR wrong number of arguments for 'object|encoding' command")
+	}
+	result := s.db.Encoding(Key(commands[2]))
+	if result == nil {
+		return nil, nil
+	}
+	return *result, nil
+}
+
+// ProcessRespCommandHello function processes redis command HELLO which
+// switches the protocol of the connection to RESP2 or RESP3 and replies
+// with information about the server. As the server has no users
+// configured AUTH is accepted with any credentials
+func (s *Server) ProcessRespCommandHello(c *Connection, commands []string) (interface{}, error) {
+	proto := c.proto
+	name := c.name
+	if len(commands) > 1 {
+		ver, err := strconv.ParseInt(commands[1], 10, 64)
+		if err != nil {
+			return nil, errors.New("ERR Protocol version is not an integer or out of range")
+		}
+		if ver < 2 || ver > 3 {
+			return nil, errors.New("NOPROTO unsupported protocol version")
+		}
+		proto = int(ver)
+	}
+
+	for i := 2; i < len(commands); i++ {
+		if strings.ToUpper(commands[i]) == "AUTH" && i+2 < len(commands) {
+			i += 2
+		} else if strings.ToUpper(commands[i]) == "SETNAME" && i+1 < len(commands) {
+			if strings.ContainsAny(commands[i+1], " \n") {
+				return nil, errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+			}
+			name = commands[i+1]
+			i += 1
+		} else {
+			return nil, errors.New(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", commands[i]))
+		}
+	}
+
+	c.proto = proto
+	c.name = name
+	return MapReply{
+		"server", "micro-redis",
+		"version", RedisVersion,
+		"proto", int64(c.proto),
+		"id", c.id,
+		"mode", "standalone",
+		"role", "master",
+		"modules", []interface{}{},
+	}, nil
+}
+
+// ProcessRespCommandQuit function processes redis command QUIT, the
+// connection itself is closed by HandleConnection once the reply is sent
+func (s *Server) ProcessRespCommandQuit(c *Connection, commands []string) (interface{}, error) {
+	return SimpleString("OK"), nil
+}
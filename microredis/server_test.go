@@ -0,0 +1,301 @@
+package microredis_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+// testConn struct is the client end of an in memory connection to a
+// server which is used to exercise commands end to end over RESP
+type testConn struct {
+	t    *testing.T
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// newTestConn function starts handling a new in memory connection on
+// server s and returns its client end
+func newTestConn(t *testing.T, s *m.Server) *testConn {
+	client, server := net.Pipe()
+	go s.HandleConnection(server)
+	t.Cleanup(func() { client.Close() })
+	return &testConn{t: t, conn: client, rd: bufio.NewReader(client)}
+}
+
+// send function writes raw bytes to the server
+func (c *testConn) send(raw string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(raw)); err != nil {
+		c.t.Fatalf("failed to write %q: %v", raw, err)
+	}
+}
+
+// read function reads one raw RESP reply from the server
+func (c *testConn) read() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := m.ReadResp(c.rd)
+	if err != nil {
+		c.t.Fatalf("failed to read reply: %v", err)
+	}
+	return reply
+}
+
+// do function sends a command to the server and returns its raw reply
+func (c *testConn) do(args ...string) string {
+	c.t.Helper()
+	c.send(m.MarshalResp(args))
+	return c.read()
+}
+
+// step struct is a command sent to the server along with the reply it is
+// expected to get, either exactly or matching a pattern
+type step struct {
+	args    []string
+	reply   string
+	pattern string
+}
+
+// stepCase struct is a named sequence of steps
+type stepCase struct {
+	name  string
+	steps []step
+}
+
+// runSteps function runs each case on a fresh server as a subtest
+func runSteps(t *testing.T, cases []stepCase) {
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			c := newTestConn(t, m.NewServer("localhost", "6379", time.Second))
+			for _, st := range cs.steps {
+				reply := c.do(st.args...)
+				if st.pattern != "" {
+					assert.Regexp(t, st.pattern, reply, st.args)
+				} else {
+					assert.Equal(t, st.reply, reply, st.args)
+				}
+			}
+		})
+	}
+}
+
+func TestHello(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c := newTestConn(t, s)
+
+	// connections start with RESP2
+	assert.Equal(t, "$-1\r\n", c.do("GET", "missing"))
+
+	reply := c.do("HELLO", "3")
+	assert.Contains(t, reply, "%7\r\n")
+	assert.Contains(t, reply, "$5\r\nproto\r\n:3\r\n")
+	assert.Equal(t, "_\r\n", c.do("GET", "missing"))
+
+	assert.Equal(t, "-NOPROTO unsupported protocol version\r\n", c.do("HELLO", "4"))
+	assert.Equal(t, "-ERR Syntax error in HELLO option 'FOO'\r\n", c.do("HELLO", "2", "FOO"))
+	// failed negotiation keeps the existing protocol
+	assert.Equal(t, "_\r\n", c.do("GET", "missing"))
+
+	reply = c.do("HELLO", "2", "AUTH", "default", "secret", "SETNAME", "worker")
+	assert.Contains(t, reply, "*14\r\n")
+	assert.Equal(t, "$-1\r\n", c.do("GET", "missing"))
+}
+
+func TestPipelining(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c := newTestConn(t, s)
+
+	var pipeline strings.Builder
+	for i := 0; i < 500; i++ {
+		pipeline.WriteString(m.MarshalResp([]string{"SET", fmt.Sprintf("key%d", i), fmt.Sprintf("val%d", i)}))
+	}
+	for i := 0; i < 500; i++ {
+		pipeline.WriteString(m.MarshalResp([]string{"GET", fmt.Sprintf("key%d", i)}))
+	}
+	// the pipe blocks writes until they are read so send in the background
+	go c.conn.Write([]byte(pipeline.String()))
+
+	for i := 0; i < 500; i++ {
+		assert.Equal(t, "+OK\r\n", c.read())
+	}
+	for i := 0; i < 500; i++ {
+		assert.Equal(t, m.MarshalResp(fmt.Sprintf("val%d", i)), c.read())
+	}
+}
+
+func TestPipeliningQuit(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c := newTestConn(t, s)
+
+	go c.conn.Write([]byte(m.MarshalResp([]string{"SET", "a", "1"}) + m.MarshalResp([]string{"QUIT"}) + m.MarshalResp([]string{"SET", "b", "2"})))
+	assert.Equal(t, "+OK\r\n", c.read())
+	assert.Equal(t, "+OK\r\n", c.read())
+
+	// commands pipelined after QUIT are not executed
+	c2 := newTestConn(t, s)
+	assert.Equal(t, "$1\r\n1\r\n", c2.do("GET", "a"))
+	assert.Equal(t, "$-1\r\n", c2.do("GET", "b"))
+}
+
+func TestCommandDispatch(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c := newTestConn(t, s)
+
+	var cases = []struct {
+		args  []string
+		reply string
+	}{
+		{[]string{"set", "k", "v"}, "+OK\r\n"},
+		{[]string{"GeT", "k"}, "$1\r\nv\r\n"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+		{[]string{"GET", "k", "extra"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+		{[]string{"SET", "k"}, "-ERR wrong number of arguments for 'set' command\r\n"},
+		{[]string{"SET", "k", "v", "EX"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "k", "v", "PXAT"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "k", "v", "BOGUS"}, "-ERR syntax error\r\n"},
+		{[]string{"DEL"}, "-ERR wrong number of arguments for 'del' command\r\n"},
+		{[]string{"EXPIRE", "k"}, "-ERR wrong number of arguments for 'expire' command\r\n"},
+		{[]string{"TTL"}, "-ERR wrong number of arguments for 'ttl' command\r\n"},
+		{[]string{"keys"}, "-ERR wrong number of arguments for 'keys' command\r\n"},
+		{[]string{"FOO", "a", "b"}, "-ERR unknown command 'FOO', with args beginning with: 'a' 'b' \r\n"},
+	}
+
+	for _, cs := range cases {
+		assert.Equal(t, cs.reply, c.do(cs.args...), strings.Join(cs.args, " "))
+	}
+
+	// inline commands go through the same dispatch
+	c.send("del k\r\n")
+	assert.Equal(t, ":1\r\n", c.read())
+}
+
+func TestCommandKeys(t *testing.T) {
+	assert.Equal(t, []m.Key{"a"}, m.LookupCommand("GET").Keys([]string{"GET", "a"}))
+	assert.Equal(t, []m.Key{"a", "b", "c"}, m.LookupCommand("del").Keys([]string{"DEL", "a", "b", "c"}))
+	assert.Equal(t, []m.Key{}, m.LookupCommand("keys").Keys([]string{"KEYS", "*"}))
+	assert.Nil(t, m.LookupCommand("nosuchcommand"))
+}
+
+func TestCommandIntrospection(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c := newTestConn(t, s)
+
+	count := c.do("COMMAND", "COUNT")
+	assert.Regexp(t, `^:\d+\r\n$`, count)
+	assert.Equal(t, "*"+count[1:], c.do("COMMAND")[:len(count)])
+
+	assert.Equal(t,
+		"*2\r\n*10\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n*3\r\n+@read\r\n+@string\r\n+@fast\r\n*0\r\n*0\r\n*0\r\n$-1\r\n",
+		c.do("COMMAND", "INFO", "get", "nosuchcommand"))
+
+	assert.Equal(t, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", c.do("COMMAND", "GETKEYS", "DEL", "a", "b"))
+	assert.Equal(t, "-ERR Invalid command specified\r\n", c.do("COMMAND", "GETKEYS", "FOO", "a"))
+	assert.Equal(t, "-ERR Invalid number of arguments specified for command\r\n", c.do("COMMAND", "GETKEYS", "GET"))
+	assert.Equal(t, "-ERR The command has no key arguments\r\n", c.do("COMMAND", "GETKEYS", "KEYS", "*"))
+
+	c.do("HELLO", "3")
+	docs := c.do("COMMAND", "DOCS", "ttl")
+	assert.Contains(t, docs, "%1\r\n$3\r\nttl\r\n%4\r\n$7\r\nsummary\r\n")
+	assert.Contains(t, docs, "$5\r\ngroup\r\n$7\r\ngeneric\r\n")
+	docs = c.do("COMMAND", "DOCS", "getver")
+	assert.Contains(t, docs, "%1\r\n$6\r\ngetver\r\n%3\r\n$7\r\nsummary\r\n")
+	assert.NotContains(t, docs, "since")
+	assert.Equal(t, "-ERR unknown subcommand 'FOO'. Try COMMAND HELP.\r\n", c.do("COMMAND", "FOO"))
+}
+
+func TestExpireCommands(t *testing.T) {
+	future_ms := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+	runSteps(t, []stepCase{
+		{"pexpire and pttl", []step{
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"PTTL", "k"}, reply: ":-1\r\n"},
+			{args: []string{"PEXPIRE", "k", "1400"}, reply: ":1\r\n"},
+			{args: []string{"PTTL", "k"}, pattern: `^:1[0-4]\d\d\r\n$`},
+			{args: []string{"TTL", "k"}, reply: ":1\r\n"},
+			{args: []string{"PTTL", "missing"}, reply: ":-2\r\n"},
+		}},
+		{"expireat and pexpireat", []step{
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"EXPIREAT", "k", future}, reply: ":1\r\n"},
+			{args: []string{"EXPIRETIME", "k"}, reply: ":" + future + "\r\n"},
+			{args: []string{"PEXPIREAT", "k", future_ms}, reply: ":1\r\n"},
+			{args: []string{"PEXPIRETIME", "k"}, reply: ":" + future_ms + "\r\n"},
+			{args: []string{"EXPIREAT", "k", "1"}, reply: ":1\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+			{args: []string{"EXPIRETIME", "k"}, reply: ":-2\r\n"},
+		}},
+		{"persist", []step{
+			{args: []string{"SET", "k", "v", "EX", "100"}, reply: "+OK\r\n"},
+			{args: []string{"PERSIST", "k"}, reply: ":1\r\n"},
+			{args: []string{"PERSIST", "k"}, reply: ":0\r\n"},
+			{args: []string{"EXPIRETIME", "k"}, reply: ":-1\r\n"},
+		}},
+		{"conditions are case insensitive", []step{
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"EXPIRE", "k", "100", "xx"}, reply: ":0\r\n"},
+			{args: []string{"EXPIRE", "k", "100", "nx"}, reply: ":1\r\n"},
+			{args: []string{"EXPIRE", "k", "200", "nx"}, reply: ":0\r\n"},
+			{args: []string{"PEXPIRE", "k", "50000", "gt"}, reply: ":0\r\n"},
+			{args: []string{"PEXPIRE", "k", "50000", "lt"}, reply: ":1\r\n"},
+			{args: []string{"EXPIRE", "k", "300", "XX", "GT"}, reply: ":1\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":300\r\n"},
+		}},
+		{"invalid conditions", []step{
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"EXPIRE", "k", "100", "foo"}, reply: "-ERR Unsupported option foo\r\n"},
+			{args: []string{"EXPIRE", "k", "100", "NX", "XX"}, reply: "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n"},
+			{args: []string{"PEXPIREAT", "k", "100", "GT", "LT"}, reply: "-ERR GT and LT options at the same time are not compatible\r\n"},
+			{args: []string{"EXPIRE", "k", "abc"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"EXPIRE", "k", "9223372036854775807"}, reply: "-ERR invalid expire time in 'expire' command\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":-1\r\n"},
+		}},
+		{"negative expire deletes the key", []step{
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"EXPIRE", "k", "-1"}, reply: ":1\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+			{args: []string{"EXPIRE", "k", "10"}, reply: ":0\r\n"},
+		}},
+	})
+}
+
+func TestVersionCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"compare and set", []step{
+			{args: []string{"GETVER", "k"}, reply: "*-1\r\n"},
+			{args: []string{"SET", "k", "a", "IFVER", "1"}, reply: "$-1\r\n"},
+			{args: []string{"SET", "k", "a", "IFVER", "0"}, reply: "+OK\r\n"},
+			{args: []string{"GETVER", "k"}, reply: "*2\r\n$1\r\na\r\n:1\r\n"},
+			{args: []string{"SET", "k", "b", "IFVER", "0"}, reply: "$-1\r\n"},
+			{args: []string{"SET", "k", "b", "ifver", "1", "GET"}, reply: "$1\r\na\r\n"},
+			{args: []string{"SET", "k", "c", "IFVER", "1", "GET"}, reply: "$1\r\nb\r\n"},
+			{args: []string{"GETVER", "k"}, reply: "*2\r\n$1\r\nb\r\n:2\r\n"},
+			{args: []string{"SET", "k", "c", "IFVER", "2", "XX", "EX", "100"}, reply: "+OK\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":100\r\n"},
+			{args: []string{"SET", "k", "d", "IFVER", "x"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"SET", "k", "d", "IFVER", "-1"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"SET", "k", "d", "IFVER", "3", "IFVER", "3"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"SET", "k", "d", "IFVER"}, reply: "-ERR syntax error\r\n"},
+		}},
+		{"compare and delete", []step{
+			{args: []string{"SET", "k", "a"}, reply: "+OK\r\n"},
+			{args: []string{"APPEND", "k", "b"}, reply: ":2\r\n"},
+			{args: []string{"DELIFVER", "k", "1"}, reply: ":0\r\n"},
+			{args: []string{"DELIFVER", "k", "x"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"DELIFVER", "k", "2"}, reply: ":1\r\n"},
+			{args: []string{"DELIFVER", "k", "2"}, reply: ":0\r\n"},
+			{args: []string{"RPUSH", "l", "a"}, reply: ":1\r\n"},
+			{args: []string{"GETVER", "l"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"DELIFVER", "l", "3"}, reply: ":1\r\n"},
+		}},
+	})
+}
@@ -0,0 +1,651 @@
+package microredis
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// setMaxIntSetEntries is the maximum number of members of a Set encoded
+// as an IntSet, the same as the default set-max-intset-entries of redis
+const setMaxIntSetEntries = 512
+
+// Set struct denotes the set datatype. While all its members are integers
+// and there are at most setMaxIntSetEntries of them it is encoded as an
+// IntSet in ints. Otherwise ints is nil and the members are kept in a
+// slice along with the position of each member in it, which allows to
+// pick random members in constant time
+type Set struct {
+	ints    *IntSet
+	members []string
+	index   map[string]int
+}
+
+// NewSet function creates and initializes an empty Set encoded as an
+// IntSet
+func NewSet() *Set {
+	return &Set{ints: &IntSet{}}
+}
+
+// Encoding function returns the name of the encoding of the set the way
+// OBJECT ENCODING reports it
+func (s *Set) Encoding() string {
+	if s.ints != nil {
+		return "intset"
+	}
+	return "hashtable"
+}
+
+// Len function returns the number of members of the set
+func (s *Set) Len() int {
+	if s.ints != nil {
+		return len(*s.ints)
+	}
+	return len(s.members)
+}
+
+// Contains function returns whether member is a member of the set
+func (s *Set) Contains(member string) bool {
+	if s.ints != nil {
+		v, ok := parseCanonicalInt(member)
+		return ok && s.ints.Contains(v)
+	}
+	_, prs := s.index[member]
+	return prs
+}
+
+// Add function adds member to the set, it returns false if it was already
+// a member. The set is converted out of the IntSet encoding once a member
+// which is not an integer is added or it grows too big
+func (s *Set) Add(member string) bool {
+	if s.ints != nil {
+		v, ok := parseCanonicalInt(member)
+		if ok && s.ints.Contains(v) {
+			return false
+		}
+		if ok && len(*s.ints) < setMaxIntSetEntries {
+			return s.ints.Add(v)
+		}
+		s.convert()
+	}
+	if _, prs := s.index[member]; prs {
+		return false
+	}
+	s.index[member] = len(s.members)
+	s.members = append(s.members, member)
+	return true
+}
+
+// Remove function removes member from the set, it returns false if it was
+// not a member
+func (s *Set) Remove(member string) bool {
+	if s.ints != nil {
+		v, ok := parseCanonicalInt(member)
+		return ok && s.ints.Remove(v)
+	}
+	i, prs := s.index[member]
+	if !prs {
+		return false
+	}
+	// move the last member into the freed position
+	last := s.members[len(s.members)-1]
+	s.members[i] = last
+	s.index[last] = i
+	s.members = s.members[:len(s.members)-1]
+	delete(s.index, member)
+	return true
+}
+
+// Members function returns all the members of the set, in ascending order
+// if the set is encoded as an IntSet and in no particular order otherwise
+func (s *Set) Members() []string {
+	if s.ints != nil {
+		result := make([]string, len(*s.ints))
+		for i, v := range *s.ints {
+			result[i] = strconv.FormatInt(v, 10)
+		}
+		return result
+	}
+	return append([]string{}, s.members...)
+}
+
+// Random function returns a random member of the set, which must not be
+// empty
+func (s *Set) Random() string {
+	if s.ints != nil {
+		return strconv.FormatInt((*s.ints)[rand.Intn(len(*s.ints))], 10)
+	}
+	return s.members[rand.Intn(len(s.members))]
+}
+
+// convert function converts the set from the IntSet encoding to the
+// hashtable one
+func (s *Set) convert() {
+	s.members = make([]string, 0, len(*s.ints)+1)
+	s.index = make(map[string]int, len(*s.ints)+1)
+	for _, v := range *s.ints {
+		member := strconv.FormatInt(v, 10)
+		s.index[member] = len(s.members)
+		s.members = append(s.members, member)
+	}
+	s.ints = nil
+}
+
+// getSet function returns the set stored at key. If the key does not
+// exist nil is returned, unless create is set in which case a new empty
+// set is stored at key. ErrWrongType is returned if the key holds another
+// datatype
+func (s *Storage) getSet(key Key, create bool) (*Set, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		if !create {
+			return nil, nil
+		}
+		result := NewSet()
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	result, ok := val.val.(*Set)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// getSets function returns the sets stored at all the keys, a key which
+// does not exist is returned as an empty set. ErrWrongType is returned if
+// any of the keys holds another datatype
+func (s *Storage) getSets(keys []Key) ([]*Set, error) {
+	result := make([]*Set, len(keys))
+	for i, key := range keys {
+		set, err := s.getSet(key, false)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			set = NewSet()
+		}
+		result[i] = set
+	}
+	return result, nil
+}
+
+// SAdd function adds the members to the set stored at key, creating the
+// set if it does not exist. Returns the number of members added
+func (s *Storage) SAdd(key Key, members []string) (int, error) {
+	set, err := s.getSet(key, true)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, member := range members {
+		if set.Add(member) {
+			added++
+		}
+	}
+	if added > 0 {
+		s.keyModified(key)
+	}
+	return added, nil
+}
+
+// SRem function removes the members from the set stored at key and returns
+// the number of members removed. The key is deleted once the set is empty
+func (s *Storage) SRem(key Key, members []string) (int, error) {
+	set, err := s.getSet(key, false)
+	if set == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if set.Remove(member) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.deleteIfEmptySet(key, set)
+		s.keyModified(key)
+	}
+	return removed, nil
+}
+
+// deleteIfEmptySet function removes key if it holds an empty set, as
+// redis never keeps empty aggregate values around
+func (s *Storage) deleteIfEmptySet(key Key, set *Set) {
+	if set.Len() == 0 {
+		delete(s.data, key)
+	}
+}
+
+// SMIsMember function returns whether each of the members is a member of
+// the set stored at key
+func (s *Storage) SMIsMember(key Key, members []string) ([]bool, error) {
+	set, err := s.getSet(key, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]bool, len(members))
+	if set != nil {
+		for i, member := range members {
+			result[i] = set.Contains(member)
+		}
+	}
+	return result, nil
+}
+
+// SMembers function returns all the members of the set stored at key, an
+// empty slice if the key does not exist
+func (s *Storage) SMembers(key Key) ([]string, error) {
+	set, err := s.getSet(key, false)
+	if set == nil {
+		return []string{}, err
+	}
+	return set.Members(), nil
+}
+
+// SCard function returns the number of members of the set stored at key
+func (s *Storage) SCard(key Key) (int, error) {
+	set, err := s.getSet(key, false)
+	if set == nil {
+		return 0, err
+	}
+	return set.Len(), nil
+}
+
+// SPop function removes and returns up to count random members of the set
+// stored at key. nil is returned if the key does not exist
+func (s *Storage) SPop(key Key, count int) ([]string, error) {
+	set, err := s.getSet(key, false)
+	if set == nil {
+		return nil, err
+	}
+	result := []string{}
+	for len(result) < count && set.Len() > 0 {
+		member := set.Random()
+		set.Remove(member)
+		result = append(result, member)
+	}
+	if len(result) > 0 {
+		s.deleteIfEmptySet(key, set)
+		s.keyModified(key)
+	}
+	return result, nil
+}
+
+// SRandMember function returns random members of the set stored at key
+// A positive count returns up to count distinct members while a negative
+// one returns exactly -count members which may repeat, up to maxRandCount
+// of them. nil is returned if the key does not exist
+func (s *Storage) SRandMember(key Key, count int) ([]string, error) {
+	if int64(count) < -maxRandCount {
+		return nil, errRandCountRange
+	}
+	set, err := s.getSet(key, false)
+	if set == nil {
+		return nil, err
+	}
+	if count < 0 {
+		result := make([]string, -count)
+		for i := range result {
+			result[i] = set.Random()
+		}
+		return result, nil
+	}
+	if count >= set.Len() {
+		return set.Members(), nil
+	}
+	if count*3 > set.Len() {
+		// a big part of the set is returned, shuffle all the members
+		result := set.Members()
+		rand.Shuffle(len(result), func(i, j int) { result[i], result[j] = result[j], result[i] })
+		return result[:count], nil
+	}
+	seen := make(map[string]bool, count)
+	result := make([]string, 0, count)
+	for len(result) < count {
+		member := set.Random()
+		if !seen[member] {
+			seen[member] = true
+			result = append(result, member)
+		}
+	}
+	return result, nil
+}
+
+// SInter function returns the members of the intersection of the sets
+// stored at keys, keys which do not exist are considered empty sets
+// Counting stops once limit members are found unless limit is 0
+func (s *Storage) SInter(keys []Key, limit int) ([]string, error) {
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	// iterate over the smallest set and check the others
+	smallest := 0
+	for i, set := range sets {
+		if set.Len() < sets[smallest].Len() {
+			smallest = i
+		}
+	}
+	result := []string{}
+	for _, member := range sets[smallest].Members() {
+		in_all := true
+		for i, set := range sets {
+			if i != smallest && !set.Contains(member) {
+				in_all = false
+				break
+			}
+		}
+		if in_all {
+			result = append(result, member)
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// SUnion function returns the members of the union of the sets stored at
+// keys, keys which do not exist are considered empty sets
+func (s *Storage) SUnion(keys []Key) ([]string, error) {
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	union := NewSet()
+	for _, set := range sets {
+		for _, member := range set.Members() {
+			union.Add(member)
+		}
+	}
+	return union.Members(), nil
+}
+
+// SDiff function returns the members of the set stored at the first key
+// which are not members of any of the sets stored at the other keys
+func (s *Storage) SDiff(keys []Key) ([]string, error) {
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, member := range sets[0].Members() {
+		found := false
+		for _, set := range sets[1:] {
+			if set.Contains(member) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, member)
+		}
+	}
+	return result, nil
+}
+
+// SStore function stores a set of the members at key replacing whatever
+// the key holds, or deletes the key if there are no members. Returns the
+// number of members stored
+func (s *Storage) SStore(key Key, members []string) int {
+	if len(members) == 0 {
+		if _, prs := s.lookup(key); prs {
+			delete(s.data, key)
+			s.keyModified(key)
+		}
+		return 0
+	}
+	set := NewSet()
+	for _, member := range members {
+		set.Add(member)
+	}
+	s.data[key] = Value{val: set}
+	s.keyModified(key)
+	return set.Len()
+}
+
+// ProcessRespCommandSAdd function processes redis command SADD
+func (s *Server) ProcessRespCommandSAdd(c *Connection, commands []string) (interface{}, error) {
+	return s.db.SAdd(Key(commands[1]), commands[2:])
+}
+
+// ProcessRespCommandSRem function processes redis command SREM
+func (s *Server) ProcessRespCommandSRem(c *Connection, commands []string) (interface{}, error) {
+	return s.db.SRem(Key(commands[1]), commands[2:])
+}
+
+// ProcessRespCommandSIsMember function processes redis command SISMEMBER
+func (s *Server) ProcessRespCommandSIsMember(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.SMIsMember(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	if result[0] {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// ProcessRespCommandSMIsMember function processes redis command SMISMEMBER
+func (s *Server) ProcessRespCommandSMIsMember(c *Connection, commands []string) (interface{}, error) {
+	found, err := s.db.SMIsMember(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(found))
+	for i, f := range found {
+		if f {
+			result[i] = 1
+		} else {
+			result[i] = 0
+		}
+	}
+	return result, nil
+}
+
+// ProcessRespCommandSMembers function processes redis command SMEMBERS
+func (s *Server) ProcessRespCommandSMembers(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.SMembers(Key(commands[1]))
+	if err != nil {
+		return nil, err
+	}
+	return setReply(result), nil
+}
+
+// ProcessRespCommandSCard function processes redis command SCARD
+func (s *Server) ProcessRespCommandSCard(c *Connection, commands []string) (interface{}, error) {
+	return s.db.SCard(Key(commands[1]))
+}
+
+// ProcessRespCommandSPop function processes redis command SPOP. Without
+// count a single member is replied, with count a set of up to count
+// members
+func (s *Server) ProcessRespCommandSPop(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) > 3 {
+		return nil, ErrSyntax
+	}
+	count := int64(1)
+	if len(commands) == 3 {
+		val, err := strconv.ParseInt(commands[2], 10, 64)
+		if err != nil || val < 0 {
+			return nil, errors.New("ERR value is out of range, must be positive")
+		}
+		count = val
+	}
+	result, err := s.db.SPop(Key(commands[1]), int(count))
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 2 {
+		if len(result) == 0 {
+			return nil, nil
+		}
+		return result[0], nil
+	}
+	return setReply(result), nil
+}
+
+// ProcessRespCommandSRandMember function processes redis command
+// SRANDMEMBER. Without count a single member is replied, with count an
+// array of members
+func (s *Server) ProcessRespCommandSRandMember(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) > 3 {
+		return nil, ErrSyntax
+	}
+	count := int64(1)
+	if len(commands) == 3 {
+		val, err := strconv.ParseInt(commands[2], 10, 64)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		count = val
+	}
+	result, err := s.db.SRandMember(Key(commands[1]), int(count))
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 2 {
+		if len(result) == 0 {
+			return nil, nil
+		}
+		return result[0], nil
+	}
+	if result == nil {
+		return []string{}, nil
+	}
+	return result, nil
+}
+
+// setReply function converts members into a set reply
+func setReply(members []string) SetReply {
+	result := make(SetReply, len(members))
+	for i, member := range members {
+		result[i] = member
+	}
+	return result
+}
+
+// toKeys function converts arguments into keys
+func toKeys(args []string) []Key {
+	result := make([]Key, len(args))
+	for i, arg := range args {
+		result[i] = Key(arg)
+	}
+	return result
+}
+
+// ProcessRespCommandSInter function processes redis command SINTER
+func (s *Server) ProcessRespCommandSInter(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.SInter(toKeys(commands[1:]), 0)
+	if err != nil {
+		return nil, err
+	}
+	return setReply(result), nil
+}
+
+// ProcessRespCommandSUnion function processes redis command SUNION
+func (s *Server) ProcessRespCommandSUnion(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.SUnion(toKeys(commands[1:]))
+	if err != nil {
+		return nil, err
+	}
+	return setReply(result), nil
+}
+
+// ProcessRespCommandSDiff function processes redis command SDIFF
+func (s *Server) ProcessRespCommandSDiff(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.SDiff(toKeys(commands[1:]))
+	if err != nil {
+		return nil, err
+	}
+	return setReply(result), nil
+}
+
+// ProcessRespCommandSInterStore function processes redis command
+// SINTERSTORE
+func (s *Server) ProcessRespCommandSInterStore(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.SInter(toKeys(commands[2:]), 0)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.SStore(Key(commands[1]), result), nil
+}
+
+// ProcessRespCommandSUnionStore function processes redis command
+// SUNIONSTORE
+func (s *Server) ProcessRespCommandSUnionStore(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.SUnion(toKeys(commands[2:]))
+	if err != nil {
+		return nil, err
+	}
+	return s.db.SStore(Key(commands[1]), result), nil
+}
+
+// ProcessRespCommandSDiffStore function processes redis command SDIFFSTORE
+func (s *Server) ProcessRespCommandSDiffStore(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.SDiff(toKeys(commands[2:]))
+	if err != nil {
+		return nil, err
+	}
+	return s.db.SStore(Key(commands[1]), result), nil
+}
+
+// parseNumKeys function parses the numkeys argument at index i of commands
+// which is followed by numkeys keys, and returns the keys
+func parseNumKeys(commands []string, i int) ([]Key, error) {
+	numkeys, err := strconv.ParseInt(commands[i], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	if numkeys <= 0 {
+		return nil, errors.New("ERR numkeys should be greater than 0")
+	}
+	if numkeys > int64(len(commands)-i-1) {
+		return nil, errors.New("ERR Number of keys can't be greater than number of args")
+	}
+	return toKeys(commands[i+1 : i+1+int(numkeys)]), nil
+}
+
+// numKeysAt function returns a function returning the keys of commands
+// which take the number of keys at index i followed by the keys, to be
+// used as the MovableKeys of the command
+func numKeysAt(i int) func(commands []string) []Key {
+	return func(commands []string) []Key {
+		keys, err := parseNumKeys(commands, i)
+		if err != nil {
+			return []Key{}
+		}
+		return keys
+	}
+}
+
+// ProcessRespCommandSInterCard function processes redis command SINTERCARD
+func (s *Server) ProcessRespCommandSInterCard(c *Connection, commands []string) (interface{}, error) {
+	keys, err := parseNumKeys(commands, 1)
+	if err != nil {
+		return nil, err
+	}
+	limit := int64(0)
+	for i := 2 + len(keys); i < len(commands); i++ {
+		if strings.ToUpper(commands[i]) == "LIMIT" && i+1 < len(commands) {
+			val, err := strconv.ParseInt(commands[i+1], 10, 64)
+			if err != nil {
+				return nil, ErrNotInteger
+			}
+			if val < 0 {
+				return nil, errors.New("ERR LIMIT can't be negative")
+			}
+			limit = val
+			i++
+		} else {
+			return nil, ErrSyntax
+		}
+	}
+	result, err := s.db.SInter(keys, int(limit))
+	if err != nil {
+		return nil, err
+	}
+	return len(result), nil
+}
@@ -0,0 +1,190 @@
+package microredis_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestIntSet(t *testing.T) {
+	is := m.IntSet{}
+	for _, v := range []int64{5, -3, 10, 5, 0} {
+		is.Add(v)
+	}
+	assert.Equal(t, m.IntSet{-3, 0, 5, 10}, is)
+	assert.True(t, is.Contains(10))
+	assert.False(t, is.Contains(7))
+	assert.True(t, is.Remove(0))
+	assert.False(t, is.Remove(0))
+	assert.Equal(t, m.IntSet{-3, 5, 10}, is)
+}
+
+func TestSetEncoding(t *testing.T) {
+	set := m.NewSet()
+	assert.True(t, set.Add("3"))
+	assert.True(t, set.Add("-1"))
+	assert.False(t, set.Add("3"))
+	assert.Equal(t, "intset", set.Encoding())
+	assert.Equal(t, []string{"-1", "3"}, set.Members())
+
+	// only canonical integers are kept in the intset
+	assert.False(t, set.Contains("03"))
+	assert.True(t, set.Add("03"))
+	assert.Equal(t, "hashtable", set.Encoding())
+	assert.ElementsMatch(t, []string{"-1", "3", "03"}, set.Members())
+	assert.True(t, set.Contains("3"))
+	assert.True(t, set.Remove("-1"))
+	assert.False(t, set.Remove("-1"))
+	assert.Equal(t, 2, set.Len())
+
+	// too many members convert the set as well
+	set = m.NewSet()
+	for i := 0; i < 512; i++ {
+		set.Add(strconv.Itoa(i))
+	}
+	assert.Equal(t, "intset", set.Encoding())
+	assert.False(t, set.Add("511"))
+	assert.Equal(t, "intset", set.Encoding())
+	set.Add("512")
+	assert.Equal(t, "hashtable", set.Encoding())
+	assert.Equal(t, 513, set.Len())
+	for i := 0; i < 513; i++ {
+		assert.True(t, set.Contains(strconv.Itoa(i)))
+	}
+}
+
+func TestSetStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	s.SAdd(m.Key("a"), []string{"1", "2", "3", "4"})
+	s.SAdd(m.Key("b"), []string{"3", "4", "5", "x"})
+	s.SAdd(m.Key("c"), []string{"4", "x"})
+
+	inter, err := s.SInter([]m.Key{"a", "b", "c"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4"}, inter)
+	inter, _ = s.SInter([]m.Key{"a", "missing"}, 0)
+	assert.Empty(t, inter)
+	inter, _ = s.SInter([]m.Key{"a", "b"}, 1)
+	assert.Len(t, inter, 1)
+
+	union, err := s.SUnion([]m.Key{"a", "b", "missing"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "2", "3", "4", "5", "x"}, union)
+
+	diff, err := s.SDiff([]m.Key{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, diff)
+	diff, _ = s.SDiff([]m.Key{"missing", "a"})
+	assert.Empty(t, diff)
+
+	// storing an empty result deletes the destination
+	assert.Equal(t, 2, s.SStore(m.Key("d"), []string{"1", "2", "1"}))
+	assert.Equal(t, 0, s.SStore(m.Key("a"), []string{}))
+	assert.Equal(t, "none", s.Type(m.Key("a")))
+
+	popped, err := s.SPop(m.Key("c"), 5)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"4", "x"}, popped)
+	assert.Equal(t, "none", s.Type(m.Key("c")))
+
+	members, err := s.SRandMember(m.Key("b"), 3)
+	assert.NoError(t, err)
+	assert.Len(t, members, 3)
+	assert.NotEqual(t, members[0], members[1])
+	members, _ = s.SRandMember(m.Key("b"), -7)
+	assert.Len(t, members, 7)
+	members, _ = s.SRandMember(m.Key("missing"), 3)
+	assert.Nil(t, members)
+
+	s.Push(m.Key("l"), []string{"x"}, true)
+	_, err = s.SUnion([]m.Key{"b", "l"})
+	assert.Equal(t, m.ErrWrongType, err)
+	_, err = s.SAdd(m.Key("l"), []string{"x"})
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestSetCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"membership", []step{
+			{args: []string{"SADD", "s", "1", "2", "2", "3"}, reply: ":3\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "s"}, reply: "$6\r\nintset\r\n"},
+			{args: []string{"SMEMBERS", "s"}, reply: "*3\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n"},
+			{args: []string{"SADD", "s", "a"}, reply: ":1\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "s"}, reply: "$9\r\nhashtable\r\n"},
+			{args: []string{"SCARD", "s"}, reply: ":4\r\n"},
+			{args: []string{"SISMEMBER", "s", "a"}, reply: ":1\r\n"},
+			{args: []string{"SISMEMBER", "s", "b"}, reply: ":0\r\n"},
+			{args: []string{"SMISMEMBER", "s", "a", "b", "1"}, reply: "*3\r\n:1\r\n:0\r\n:1\r\n"},
+			{args: []string{"SMISMEMBER", "missing", "a"}, reply: "*1\r\n:0\r\n"},
+			{args: []string{"SREM", "s", "a", "b", "1"}, reply: ":2\r\n"},
+			{args: []string{"SREM", "s", "2", "3"}, reply: ":2\r\n"},
+			{args: []string{"TYPE", "s"}, reply: "+none\r\n"},
+			{args: []string{"SMEMBERS", "s"}, reply: "*0\r\n"},
+		}},
+		{"random members", []step{
+			{args: []string{"SADD", "s", "a"}, reply: ":1\r\n"},
+			{args: []string{"SRANDMEMBER", "s"}, reply: "$1\r\na\r\n"},
+			{args: []string{"SRANDMEMBER", "s", "-2"}, reply: "*2\r\n$1\r\na\r\n$1\r\na\r\n"},
+			{args: []string{"SRANDMEMBER", "s", "5"}, reply: "*1\r\n$1\r\na\r\n"},
+			{args: []string{"SRANDMEMBER", "s", "x"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"SRANDMEMBER", "s", "-1048577"}, reply: "-ERR value is out of range\r\n"},
+			{args: []string{"SRANDMEMBER", "s", "-9223372036854775808"}, reply: "-ERR value is out of range\r\n"},
+			{args: []string{"SRANDMEMBER", "s", "9223372036854775807"}, reply: "*1\r\n$1\r\na\r\n"},
+			{args: []string{"SRANDMEMBER", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"SRANDMEMBER", "missing", "3"}, reply: "*0\r\n"},
+			{args: []string{"SPOP", "s", "0"}, reply: "*0\r\n"},
+			{args: []string{"SPOP", "s", "-1"}, reply: "-ERR value is out of range, must be positive\r\n"},
+			{args: []string{"SPOP", "s"}, reply: "$1\r\na\r\n"},
+			{args: []string{"SPOP", "s"}, reply: "$-1\r\n"},
+			{args: []string{"SPOP", "s", "2"}, reply: "*0\r\n"},
+		}},
+		{"algebra", []step{
+			{args: []string{"SADD", "a", "1", "2", "3"}, reply: ":3\r\n"},
+			{args: []string{"SADD", "b", "2", "3", "4"}, reply: ":3\r\n"},
+			{args: []string{"SINTER", "a", "b"}, reply: "*2\r\n$1\r\n2\r\n$1\r\n3\r\n"},
+			{args: []string{"SUNION", "a", "b"}, reply: "*4\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n$1\r\n4\r\n"},
+			{args: []string{"SDIFF", "a", "b"}, reply: "*1\r\n$1\r\n1\r\n"},
+			{args: []string{"SINTERSTORE", "dst", "a", "b"}, reply: ":2\r\n"},
+			{args: []string{"SMEMBERS", "dst"}, reply: "*2\r\n$1\r\n2\r\n$1\r\n3\r\n"},
+			{args: []string{"SUNIONSTORE", "dst", "a", "b"}, reply: ":4\r\n"},
+			{args: []string{"SDIFFSTORE", "dst", "a", "b", "missing"}, reply: ":1\r\n"},
+			{args: []string{"SDIFFSTORE", "dst", "missing", "a"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "dst"}, reply: "+none\r\n"},
+			{args: []string{"SINTERCARD", "2", "a", "b"}, reply: ":2\r\n"},
+			{args: []string{"SINTERCARD", "2", "a", "b", "LIMIT", "1"}, reply: ":1\r\n"},
+			{args: []string{"SINTERCARD", "1", "a", "limit", "0"}, reply: ":3\r\n"},
+			{args: []string{"SINTERCARD", "3", "a", "b"}, reply: "-ERR Number of keys can't be greater than number of args\r\n"},
+			{args: []string{"SINTERCARD", "0", "a"}, reply: "-ERR numkeys should be greater than 0\r\n"},
+			{args: []string{"SINTERCARD", "1", "a", "LIMIT", "-1"}, reply: "-ERR LIMIT can't be negative\r\n"},
+			{args: []string{"SINTERCARD", "1", "a", "b"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"COMMAND", "GETKEYS", "SINTERCARD", "2", "a", "b", "LIMIT", "1"}, reply: "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+			{args: []string{"HELLO", "3"}, pattern: `^%7`},
+			{args: []string{"SINTER", "a", "b"}, reply: "~2\r\n$1\r\n2\r\n$1\r\n3\r\n"},
+		}},
+		{"wrongtype", []step{
+			{args: []string{"SET", "str", "v"}, reply: "+OK\r\n"},
+			{args: []string{"SADD", "s", "a"}, reply: ":1\r\n"},
+			{args: []string{"TYPE", "s"}, reply: "+set\r\n"},
+			{args: []string{"SADD", "str", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"SINTER", "s", "str"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"GET", "s"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			// the destination of the store variants is overwritten
+			{args: []string{"SUNIONSTORE", "str", "s"}, reply: ":1\r\n"},
+			{args: []string{"TYPE", "str"}, reply: "+set\r\n"},
+		}},
+		{"object encoding", []step{
+			{args: []string{"SET", "n", "12345"}, reply: "+OK\r\n"},
+			{args: []string{"SET", "e", "hello"}, reply: "+OK\r\n"},
+			{args: []string{"RPUSH", "l", "a"}, reply: ":1\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "n"}, reply: "$3\r\nint\r\n"},
+			{args: []string{"OBJECT", "encoding", "e"}, reply: "$6\r\nembstr\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "l"}, reply: "$9\r\nquicklist\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"OBJECT", "FREQ", "n"}, reply: "-ERR unknown subcommand 'FREQ'. Try OBJECT HELP.\r\n"},
+			{args: []string{"OBJECT", "ENCODING"}, reply: "-ERR wrong number of arguments for 'object|encoding' command\r\n"},
+		}},
+	})
+}
@@ -0,0 +1,289 @@
+package microredis
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// skipListMaxLevel is the maximum number of levels of a SkipList, enough
+// for 4^32 elements
+const skipListMaxLevel = 32
+
+// skipListP is the probability of a node to have one more level
+const skipListP = 0.25
+
+// skipListLevel struct is a link of a node at one of the levels, span is
+// the number of nodes between the node and forward which is used to
+// compute ranks
+type skipListLevel struct {
+	forward *skipListNode
+	span    int
+}
+
+// skipListNode struct is an element of a SkipList
+type skipListNode struct {
+	member   string
+	score    float64
+	backward *skipListNode
+	level    []skipListLevel
+}
+
+// before function returns whether the node sorts before the element of
+// score and member. Elements are ordered by score, elements with the same
+// score by member
+func (n *skipListNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// SkipList struct is the skip list of a ZSet, modeled after the zskiplist
+// of redis. Each level links a subset of the nodes of the level below it
+// which keeps lookups, inserts, deletes and finding the element at a rank
+// O(log(N)). The nodes at the lowest level are also linked backwards so
+// ranges can be walked in both directions
+type SkipList struct {
+	header *skipListNode
+	tail   *skipListNode
+	length int
+	level  int
+}
+
+// NewSkipList function creates and initializes an empty SkipList
+func NewSkipList() *SkipList {
+	return &SkipList{
+		header: &skipListNode{level: make([]skipListLevel, skipListMaxLevel)},
+		level:  1,
+	}
+}
+
+// Len function returns the number of elements in the skip list
+func (sl *SkipList) Len() int {
+	return sl.length
+}
+
+// randomLevel function returns the level of a new node, where every level
+// is skipListP times as likely as the one below it
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// Insert function inserts the element of score and member, which must not
+// be in the skip list already
+func (sl *SkipList) Insert(score float64, member string) {
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]int
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].level[i].span = sl.length
+		}
+		sl.level = level
+	}
+	x = &skipListNode{member: member, score: score, level: make([]skipListLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	// the levels above the new node now span one more node
+	for i := level; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+}
+
+// Delete function removes the element of score and member, it returns
+// false if the element is not in the skip list
+func (sl *SkipList) Delete(score float64, member string) bool {
+	var update [skipListMaxLevel]*skipListNode
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	for i := 0; i < sl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.level[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+	return true
+}
+
+// Rank function returns the 0 based rank of the element of score and
+// member, -1 if it is not in the skip list
+func (sl *SkipList) Rank(score float64, member string) int {
+	rank := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && (x.level[i].forward.before(score, member) ||
+			(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != sl.header && x.score == score && x.member == member {
+			return rank - 1
+		}
+	}
+	return -1
+}
+
+// byRank function returns the node at the 0 based rank, nil if the rank
+// is out of range
+func (sl *SkipList) byRank(rank int) *skipListNode {
+	if rank < 0 || rank >= sl.length {
+		return nil
+	}
+	traversed := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank+1 {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank+1 {
+			return x
+		}
+	}
+	return nil
+}
+
+// zrangeBounds interface denotes the bounds of a range of elements of a
+// skip list, either a ScoreRange or a LexRange
+type zrangeBounds interface {
+	// aboveMin returns whether the node is above the lower bound
+	aboveMin(n *skipListNode) bool
+	// belowMax returns whether the node is below the upper bound
+	belowMax(n *skipListNode) bool
+}
+
+// firstInRange function returns the first node within the bounds, nil if
+// no node is
+func (sl *SkipList) firstInRange(r zrangeBounds) *skipListNode {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.aboveMin(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !r.belowMax(x) {
+		return nil
+	}
+	return x
+}
+
+// lastInRange function returns the last node within the bounds, nil if no
+// node is
+func (sl *SkipList) lastInRange(r zrangeBounds) *skipListNode {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.belowMax(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	if x == sl.header || !r.aboveMin(x) {
+		return nil
+	}
+	return x
+}
+
+// ScoreRange struct denotes a range of scores, each bound is inclusive
+// unless it is marked as exclusive
+type ScoreRange struct {
+	Min          float64
+	Max          float64
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+func (r ScoreRange) aboveMin(n *skipListNode) bool {
+	if r.MinExclusive {
+		return n.score > r.Min
+	}
+	return n.score >= r.Min
+}
+
+func (r ScoreRange) belowMax(n *skipListNode) bool {
+	if r.MaxExclusive {
+		return n.score < r.Max
+	}
+	return n.score <= r.Max
+}
+
+// LexBound struct denotes a bound of a LexRange. Inf is -1 for the bound
+// below every member and 1 for the bound above every member, in which case
+// Member and Exclusive are ignored
+type LexBound struct {
+	Member    string
+	Exclusive bool
+	Inf       int
+}
+
+// compare function compares member to the bound the same way
+// strings.Compare does
+func (b LexBound) compare(member string) int {
+	if b.Inf != 0 {
+		return -b.Inf
+	}
+	return strings.Compare(member, b.Member)
+}
+
+// LexRange struct denotes a range of members, it is only meaningful for
+// elements which all have the same score
+type LexRange struct {
+	Min LexBound
+	Max LexBound
+}
+
+func (r LexRange) aboveMin(n *skipListNode) bool {
+	c := r.Min.compare(n.member)
+	return c > 0 || (c == 0 && !r.Min.Exclusive)
+}
+
+func (r LexRange) belowMax(n *skipListNode) bool {
+	c := r.Max.compare(n.member)
+	return c < 0 || (c == 0 && !r.Max.Exclusive)
+}
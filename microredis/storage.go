@@ -10,20 +10,115 @@ import (
 // Key type to denote key in the key value storage
 type Key string
 
+// ErrWrongType is returned when a command is performed against a key
+// holding a different datatype than the one the command works on
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
 // Value type to denote the value in the key value storage
 //
-// For simplicity purpose we are only considering string datatype
+// val holds the data of the value whose datatype decides how it is
+// stored, a *string for strings and the container type such as *List,
+// Hash, *Set or *ZSet for the other datatypes
+//
+// version is bumped every time the value is modified, it is unique across
+// all keys and only ever increases so that a key which is deleted and
+// created again never gets a version it had before
 type Value struct {
-	val    *string
-	expiry *time.Time // nil time denotes infinite expiry
+	val     interface{}
+	expiry  *time.Time // nil time denotes infinite expiry
+	version int64
+}
+
+// Type function returns the name of the datatype of the value the way
+// the TYPE command reports it
+func (v Value) Type() string {
+	switch v.val.(type) {
+	case *string, *Bitmap:
+		return "string"
+	case *List:
+		return "list"
+	case Hash:
+		return "hash"
+	case *Set:
+		return "set"
+	case *ZSet:
+		return "zset"
+	case *Stream:
+		return "stream"
+	case *HyperLogLog:
+		return "hyperloglog"
+	case *BloomFilter:
+		return "MBbloom--"
+	case *CuckooFilter:
+		return "MBbloomCF"
+	case *CountMinSketch:
+		return "CMSk-TYPE"
+	case *TopK:
+		return "TopK-TYPE"
+	case *JSON:
+		return "ReJSON-RL"
+	default:
+		return "none"
+	}
+}
+
+// Encoding function returns the name of the way the value is encoded the
+// way OBJECT ENCODING reports it, following the names redis uses
+func (v Value) Encoding() string {
+	switch val := v.val.(type) {
+	case *string:
+		if _, ok := parseCanonicalInt(*val); ok {
+			return "int"
+		}
+		if len(*val) <= 44 {
+			return "embstr"
+		}
+		return "raw"
+	case *Bitmap:
+		return "raw"
+	case *List:
+		return "quicklist"
+	case Hash:
+		return "hashtable"
+	case *Set:
+		return val.Encoding()
+	case *ZSet:
+		return "skiplist"
+	case *Stream:
+		return "stream"
+	case *HyperLogLog:
+		return val.Encoding()
+	case *BloomFilter, *CuckooFilter, *CountMinSketch, *TopK, *JSON:
+		return "raw"
+	default:
+		return ""
+	}
+}
+
+// str function returns the value if it is a string and nil otherwise, a
+// Bitmap is copied into a string
+func (v Value) str() *string {
+	if bm, ok := v.val.(*Bitmap); ok {
+		result := string(bm.buf)
+		return &result
+	}
+	result, _ := v.val.(*string)
+	return result
 }
 
 // Storage will be the key value in-memory storage where
 // data is the actual data stored and
 // clear_freq is the freq at which expired keys will be cleared
+// blocked counts the clients blocked on each key and ready holds the
+// blocked keys modified since they were last taken by readyKeys, in the
+// order they were modified
+// version is the last version given to a value
 type Storage struct {
 	data       map[Key]Value
 	clear_freq time.Duration
+	blocked    map[Key]int
+	ready      []Key
+	version    int64
 }
 
 // NewStorage function to create initialize and return a pointer
@@ -32,24 +127,84 @@ func NewStorage(freq time.Duration) *Storage {
 	result := Storage{
 		data:       make(map[Key]Value),
 		clear_freq: freq,
+		blocked:    make(map[Key]int),
 	}
 	return &result
 }
 
-// Get function to get the value for a key if it exists
+// lookup function returns the value for a key if it exists
 // It also clears the key from storage if it has expired
-func (s *Storage) Get(key Key) *string {
+func (s *Storage) lookup(key Key) (Value, bool) {
 	result, prs := s.data[key]
-	if prs {
-		if result.expiry != nil {
-			if time.Now().After(*result.expiry) {
-				delete(s.data, key)
-				return nil
-			}
+	if prs && result.expiry != nil && !time.Now().Before(*result.expiry) {
+		delete(s.data, key)
+		return Value{}, false
+	}
+	return result, prs
+}
+
+// keyModified function has to be called whenever the value stored at key
+// is created, changed or deleted by a command. The value gets a new
+// version and if clients are blocked on the key it is recorded as ready
+// so that the server can serve them
+func (s *Storage) keyModified(key Key) {
+	if val, prs := s.data[key]; prs {
+		s.version++
+		val.version = s.version
+		s.data[key] = val
+	}
+	if s.blocked[key] == 0 {
+		return
+	}
+	for _, k := range s.ready {
+		if k == key {
+			return
 		}
-		return s.data[key].val
 	}
-	return nil
+	s.ready = append(s.ready, key)
+}
+
+// block function records that a client is blocked on key
+func (s *Storage) block(key Key) {
+	s.blocked[key]++
+}
+
+// unblock function records that a client is no longer blocked on key
+func (s *Storage) unblock(key Key) {
+	s.blocked[key]--
+	if s.blocked[key] <= 0 {
+		delete(s.blocked, key)
+	}
+}
+
+// readyKeys function returns the keys recorded by keyModified in the
+// order they were modified and starts recording afresh
+func (s *Storage) readyKeys() []Key {
+	result := s.ready
+	s.ready = nil
+	return result
+}
+
+// Get function to get the value for a key if it exists
+// It also clears the key from storage if it has expired
+// ErrWrongType is returned if the key does not hold a string
+func (s *Storage) Get(key Key) (*string, error) {
+	result, prs := s.lookup(key)
+	if !prs {
+		return nil, nil
+	}
+	str := result.str()
+	if str == nil {
+		return nil, ErrWrongType
+	}
+	return str, nil
+}
+
+// Type function returns the name of the datatype of the value stored at
+// key, none if the key does not exist
+func (s *Storage) Type(key Key) string {
+	result, _ := s.lookup(key)
+	return result.Type()
 }
 
 // Set function to set a value for a corresponding key with expiry
@@ -67,6 +222,8 @@ func (s *Storage) Set(
 	set_if_exists bool,
 	set_if_not_exists bool,
 ) (bool, *string) {
+	// clear the key if it has expired so it is not considered to exist
+	s.lookup(key)
 
 	if set_if_exists {
 		return s.setIfKeyExists(key, val, exp, ret_old_val, keep_ttl)
@@ -82,8 +239,9 @@ func (s *Storage) Set(
 			val:    &val,
 			expiry: exp,
 		}
+		s.keyModified(key)
 		if ret_old_val {
-			return true, prev_val.val
+			return true, prev_val.str()
 		} else {
 			return true, nil
 		}
@@ -111,8 +269,9 @@ func (s *Storage) setIfKeyExists(
 			val:    &val,
 			expiry: exp,
 		}
+		s.keyModified(key)
 		if ret_old_val {
-			return true, prev_val.val
+			return true, prev_val.str()
 		} else {
 			return true, nil
 		}
@@ -140,8 +299,9 @@ func (s *Storage) setIfKeyNotExists(
 			val:    &val,
 			expiry: exp,
 		}
+		s.keyModified(key)
 		if ret_old_val {
-			return true, prev_val.val
+			return true, prev_val.str()
 		} else {
 			return true, nil
 		}
@@ -150,6 +310,81 @@ func (s *Storage) setIfKeyNotExists(
 	}
 }
 
+// GetDel function gets the value for a key and deletes the key
+// nil is returned if the key does not exist
+func (s *Storage) GetDel(key Key) (*string, error) {
+	result, err := s.Get(key)
+	if result != nil {
+		delete(s.data, key)
+		s.keyModified(key)
+	}
+	return result, err
+}
+
+// GetEx function gets the value for a key and updates its expiry. If exp
+// is not nil it becomes the new expiry, a time which has already passed
+// deletes the key. If persist is set the expiry is cleared instead and
+// if neither is given the expiry is left as is
+func (s *Storage) GetEx(key Key, exp *time.Time, persist bool) (*string, error) {
+	result, err := s.Get(key)
+	if result == nil {
+		return nil, err
+	}
+	val := s.data[key]
+	if exp != nil {
+		if !exp.After(time.Now()) {
+			delete(s.data, key)
+			s.keyModified(key)
+			return result, nil
+		}
+		val.expiry = exp
+	} else if persist {
+		val.expiry = nil
+	} else {
+		return result, nil
+	}
+	s.data[key] = val
+	s.keyModified(key)
+	return result, nil
+}
+
+// Encoding function returns the encoding of the value stored at key, nil
+// if the key does not exist
+func (s *Storage) Encoding(key Key) *string {
+	val, prs := s.lookup(key)
+	if !prs {
+		return nil
+	}
+	result := val.Encoding()
+	return &result
+}
+
+// Version function returns the version of the value stored at key, 0 if
+// the key does not exist
+func (s *Storage) Version(key Key) int64 {
+	val, _ := s.lookup(key)
+	return val.version
+}
+
+// GetVer function is same as Get but also returns the version of the value
+func (s *Storage) GetVer(key Key) (*string, int64, error) {
+	result, err := s.Get(key)
+	if result == nil {
+		return nil, 0, err
+	}
+	return result, s.data[key].version, nil
+}
+
+// DelIfVer function deletes key ONLY if the version of its value is
+// version. It returns 1 if the key was deleted and 0 otherwise
+func (s *Storage) DelIfVer(key Key, version int64) int {
+	val, prs := s.lookup(key)
+	if !prs || val.version != version {
+		return 0
+	}
+	return s.Del([]Key{key})
+}
+
 // Del function to del a set of keys from storage
 // The return value denotes number of keys deleted
 func (s *Storage) Del(keys []Key) int {
@@ -158,6 +393,7 @@ func (s *Storage) Del(keys []Key) int {
 		_, prs := s.data[key]
 		if prs {
 			delete(s.data, key)
+			s.keyModified(key)
 			removed_count += 1
 		}
 	}
@@ -166,195 +402,139 @@ func (s *Storage) Del(keys []Key) int {
 
 // Expire function to expire an existing key after
 // certain number of secs
-// ONLY one other condition should be present through
-// arguments
-// set_if_expiry means only set new expiry if not already set previously
-// set_if_no_expiry means only set new expiry if it already exists
+// The conditions given through arguments are explained in PExpireAt
+func (s *Storage) Expire(
+	key Key,
+	secs int64,
+	set_if_no_expiry bool,
+	set_if_expiry bool,
+	set_if_gt bool,
+	set_if_lt bool) int {
+	at := time.Now().Add(time.Duration(secs) * time.Second)
+	return s.PExpireAt(key, at, set_if_no_expiry, set_if_expiry, set_if_gt, set_if_lt)
+}
+
+// PExpireAt function to expire an existing key at the given time, which is
+// truncated to milliseconds. The conditions given through arguments are
+// set_if_no_expiry means only set new expiry if not already set previously
+// set_if_expiry means only set new expiry if it already exists
 // set_if_gt means only set new expiry if it is greater than existing expiry
 // set_if_lt means only set new expiry if it is less than existing expiry
+// For set_if_gt and set_if_lt a key without expiry is considered to live
+// forever. set_if_no_expiry can't be combined with any other condition and
+// set_if_gt can't be combined with set_if_lt.
 // return 1 if setting new expiry is successful else 0 if it fails due to
-// any of the args above
-func (s *Storage) Expire(
+// the key not existing or any of the args above. A time which has already
+// passed deletes the key
+func (s *Storage) PExpireAt(
 	key Key,
-	secs int64,
+	at time.Time,
 	set_if_no_expiry bool,
 	set_if_expiry bool,
 	set_if_gt bool,
 	set_if_lt bool) int {
 	// check arguments validity
-	count := 0
-	if set_if_expiry {
-		count += 1
-	}
-	if set_if_no_expiry {
-		count += 1
-	}
-	if set_if_gt {
-		count += 1
-	}
-	if set_if_lt {
-		count += 1
+	if set_if_no_expiry && (set_if_expiry || set_if_gt || set_if_lt) {
+		return 0
 	}
-	if count > 1 {
-		// invalid arguments
+	if set_if_gt && set_if_lt {
 		return 0
 	}
 
-	val, prs := s.data[key]
-
+	val, prs := s.lookup(key)
 	// key does not exists
 	if !prs {
 		return 0
 	}
 
-	if set_if_no_expiry {
-		return s.expireIfNoExpiry(key, secs)
+	at = time.UnixMilli(at.UnixMilli())
+	if set_if_no_expiry && val.expiry != nil {
+		return 0
 	}
-
-	if set_if_expiry {
-		return s.expireIfExpiryExists(key, secs)
+	if set_if_expiry && val.expiry == nil {
+		return 0
 	}
-
-	if set_if_gt {
-		return s.expireIfExpiryGreater(key, secs)
+	if set_if_gt && (val.expiry == nil || !at.After(*val.expiry)) {
+		return 0
 	}
-
-	if set_if_lt {
-		return s.expireIfExpiryLesser(key, secs)
+	if set_if_lt && val.expiry != nil && !at.Before(*val.expiry) {
+		return 0
 	}
 
-	// base case
-	if secs > 0 {
-		new_exp := time.Now().Add(time.Duration(float64(secs) * float64(time.Second)))
-		val.expiry = &new_exp
-		s.data[key] = val
-		return 1
-	} else {
+	if !at.After(time.Now()) {
 		delete(s.data, key)
-		return 0
+		s.keyModified(key)
+		return 1
 	}
+	val.expiry = &at
+	s.data[key] = val
+	s.keyModified(key)
+	return 1
 }
 
-// expireIfNoExpire function sets expiry of a key in secs
-// ONLY if expiry is not already set.
-// Note: negative secs means clear the key from db
-func (s *Storage) expireIfNoExpiry(key Key, secs int64) int {
-	val, prs := s.data[key]
-	if !prs {
-		return 0
-	}
-	if val.expiry == nil {
-		if secs > 0 {
-			new_exp := time.Now().Add(time.Duration(float64(secs) * float64(time.Second)))
-			val.expiry = &new_exp
-			s.data[key] = val
-		} else {
-			// delete the key
-			delete(s.data, key)
-		}
-		return 1
-	} else {
+// Persist function removes the expiry of a key, it returns 1 if the
+// expiry was removed and 0 if the key does not exist or has no expiry
+func (s *Storage) Persist(key Key) int {
+	val, prs := s.lookup(key)
+	if !prs || val.expiry == nil {
 		return 0
 	}
+	val.expiry = nil
+	s.data[key] = val
+	s.keyModified(key)
+	return 1
 }
 
-// expireIfExpiryExists function sets expiry of a key in secs ONLY
-// if expiry already exists
-// Note: negative secs means clear the key from db
-func (s *Storage) expireIfExpiryExists(key Key, secs int64) int {
-	val, prs := s.data[key]
-	if !prs {
-		return 0
-	}
-	if val.expiry != nil {
-		if secs > 0 {
-			new_exp := time.Now().Add(time.Duration(float64(secs) * float64(time.Second)))
-			val.expiry = &new_exp
-			s.data[key] = val
-		} else {
-			delete(s.data, key)
-		}
-		return 1
-	} else {
-		return 0
+// TTL function to return the time to live of key in seconds, rounded to
+// the nearest second like redis does
+// returns -1 if key has no expiry and -2 if it does not exists
+// This function also clears the existing key if it has expired
+func (s *Storage) TTL(key Key) int64 {
+	result := s.PTTL(key)
+	if result < 0 {
+		return result
 	}
+	return (result + 500) / 1000
 }
 
-// expireIfExpiryGreater function sets expiry of a key in secs ONLY
-// if new expiry is further in time than existing expiry
-// Note: negative secs means clear the key from db
-func (s *Storage) expireIfExpiryGreater(key Key, secs int64) int {
-	val, prs := s.data[key]
+// PTTL function is same as TTL but returns the time to live in
+// milliseconds
+func (s *Storage) PTTL(key Key) int64 {
+	val, prs := s.lookup(key)
 	if !prs {
-		return 0
+		return -2
 	}
-	if val.expiry != nil {
-		if secs > 0 {
-			new_exp := time.Now().Add(time.Duration(float64(secs) * float64(time.Second)))
-			if val.expiry.Before(new_exp) {
-				val.expiry = &new_exp
-				s.data[key] = val
-				return 1
-			} else {
-				return 0
-			}
-		} else {
-			delete(s.data, key)
-			return 0
-		}
-	} else {
+	if val.expiry == nil {
+		return -1
+	}
+	result := val.expiry.Sub(time.Now()).Milliseconds()
+	if result < 0 {
 		return 0
 	}
+	return result
 }
 
-// expireIfExpiryLesser function sets expiry of a key in secs ONLY
-// if new expiry is before in time than existing expiry
-// Note: negative secs means clear the key from db
-func (s *Storage) expireIfExpiryLesser(key Key, secs int64) int {
-	val, prs := s.data[key]
-	if !prs {
-		return 0
-	}
-	if val.expiry != nil {
-		if secs > 0 {
-			new_exp := time.Now().Add(time.Duration(float64(secs) * float64(time.Second)))
-			if val.expiry.After(new_exp) {
-				val.expiry = &new_exp
-				s.data[key] = val
-				return 1
-			} else {
-				return 0
-			}
-		} else {
-			delete(s.data, key)
-			return 0
-		}
-	} else {
-		return 0
+// ExpireTime function returns the absolute unix time in seconds at which
+// the key expires, -1 if key has no expiry and -2 if it does not exists
+func (s *Storage) ExpireTime(key Key) int64 {
+	result := s.PExpireTime(key)
+	if result < 0 {
+		return result
 	}
+	return result / 1000
 }
 
-// TTL function to return the time to live of key in seconds
-// returns -1 if key has no expiry and -2 if it does not exists
-// This function also clears the existing key if it has expired
-func (s *Storage) TTL(key Key) int64 {
-	val, prs := s.data[key]
-	if prs {
-		if val.expiry == nil {
-			return -1
-		} else {
-			result := int64(val.expiry.Sub(time.Now()).Seconds())
-			// negative ttl implies key has expired so clear it
-			if result < 0 {
-				delete(s.data, key)
-				return -2
-			} else {
-				return result
-			}
-		}
-	} else {
+// PExpireTime function is same as ExpireTime but returns the unix time in
+// milliseconds
+func (s *Storage) PExpireTime(key Key) int64 {
+	val, prs := s.lookup(key)
+	if !prs {
 		return -2
 	}
+	if val.expiry == nil {
+		return -1
+	}
+	return val.expiry.UnixMilli()
 }
 
 // Keys function filters keys in the storage through a
@@ -1,7 +1,6 @@
 package microredis_test
 
 import (
-	"reflect"
 	"testing"
 	"time"
 
@@ -14,13 +13,29 @@ func TestGet(t *testing.T) {
 
 	// base case set key, key exists
 	s.Set(m.Key("hello"), "world", nil, false, false, false, false)
-	act := s.Get(m.Key("hello"))
+	act, err := s.Get(m.Key("hello"))
+	assert.NoError(t, err)
 	assert.Equal(t, *act, "world")
 
 	// key does not exists
-	act = s.Get(m.Key("hello2"))
+	act, err = s.Get(m.Key("hello2"))
+	assert.NoError(t, err)
 	assert.Nil(t, act)
 
+	// key holds another datatype
+	s.Push(m.Key("list"), []string{"a"}, true)
+	act, err = s.Get(m.Key("list"))
+	assert.Equal(t, m.ErrWrongType, err)
+	assert.Nil(t, act)
+}
+
+// getString function is a helper returning the string stored at key and
+// failing the test if the key holds another datatype
+func getString(t *testing.T, s *m.Storage, key m.Key) *string {
+	t.Helper()
+	result, err := s.Get(key)
+	assert.NoError(t, err)
+	return result
 }
 
 func TestSet(t *testing.T) {
@@ -131,7 +146,7 @@ func TestTTL(t *testing.T) {
 	exp := time.Now().Add(time.Minute)
 	s.Set(m.Key("hello"), "world", &exp, false, false, false, false)
 	ttl := s.TTL(m.Key("hello"))
-	assert.True(t, ttl < 60)
+	assert.True(t, ttl <= 60)
 	time.Sleep(5)
 	assert.True(t, ttl > 50)
 }
@@ -146,14 +161,98 @@ func TestKeys(t *testing.T) {
 	if err != nil {
 		t.Errorf("Err -> %v", err.Error())
 	} else {
-		assert.True(t, reflect.DeepEqual([]m.Key{"hello", "hell"}, result))
+		assert.ElementsMatch(t, []m.Key{"hello", "hell"}, result)
 	}
 
 	result, err = s.Keys(".ell.")
 	if err != nil {
 		t.Errorf("Err -> %v", err.Error())
 	} else {
-		assert.True(t, reflect.DeepEqual([]m.Key{"hello", "bella"}, result))
+		assert.ElementsMatch(t, []m.Key{"hello", "bella"}, result)
 	}
 
 }
+
+func TestPExpireAt(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	s.Set(m.Key("hello"), "world", nil, false, false, false, false)
+
+	// millisecond precision
+	at := time.Now().Add(1400 * time.Millisecond)
+	assert.Equal(t, 1, s.PExpireAt(m.Key("hello"), at, false, false, false, false))
+	assert.Equal(t, at.UnixMilli(), s.PExpireTime(m.Key("hello")))
+	assert.Equal(t, at.Unix(), s.ExpireTime(m.Key("hello")))
+	pttl := s.PTTL(m.Key("hello"))
+	assert.True(t, pttl > 1000 && pttl <= 1400)
+	assert.Equal(t, int64(1), s.TTL(m.Key("hello")))
+
+	// incompatible conditions
+	assert.Equal(t, 0, s.PExpireAt(m.Key("hello"), at, true, true, false, false))
+	assert.Equal(t, 0, s.PExpireAt(m.Key("hello"), at, false, false, true, true))
+
+	// a time in the past deletes the key
+	assert.Equal(t, 1, s.PExpireAt(m.Key("hello"), time.Now().Add(-time.Second), false, false, false, false))
+	assert.Nil(t, getString(t, s, m.Key("hello")))
+	assert.Equal(t, int64(-2), s.PTTL(m.Key("hello")))
+	assert.Equal(t, int64(-2), s.PExpireTime(m.Key("hello")))
+}
+
+func TestExpireLtWithoutExpiry(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	// a key without expiry lives forever so any expiry is less than it
+	s.Set(m.Key("hello"), "world", nil, false, false, false, false)
+	assert.Equal(t, 1, s.Expire(m.Key("hello"), 60, false, false, false, true))
+	// but XX and LT together need an existing expiry
+	s.Set(m.Key("bella"), "ciao", nil, false, false, false, false)
+	assert.Equal(t, 0, s.Expire(m.Key("bella"), 60, false, true, false, true))
+}
+
+func TestPersist(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	exp := time.Now().Add(time.Minute)
+	s.Set(m.Key("hello"), "world", &exp, false, false, false, false)
+	assert.Equal(t, 1, s.Persist(m.Key("hello")))
+	assert.Equal(t, int64(-1), s.TTL(m.Key("hello")))
+	assert.Equal(t, int64(-1), s.ExpireTime(m.Key("hello")))
+	assert.Equal(t, 0, s.Persist(m.Key("hello")))
+	assert.Equal(t, 0, s.Persist(m.Key("missing")))
+}
+
+func TestVersion(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	assert.Equal(t, int64(0), s.Version(m.Key("a")))
+
+	s.Set(m.Key("a"), "1", nil, false, false, false, false)
+	v1 := s.Version(m.Key("a"))
+	assert.True(t, v1 > 0)
+
+	// every modification bumps the version
+	s.IncrBy(m.Key("a"), 1)
+	v2 := s.Version(m.Key("a"))
+	assert.True(t, v2 > v1)
+	s.Expire(m.Key("a"), 10, false, false, false, false)
+	v3 := s.Version(m.Key("a"))
+	assert.True(t, v3 > v2)
+
+	// versions are unique across keys
+	s.Push(m.Key("l"), []string{"x"}, true)
+	assert.True(t, s.Version(m.Key("l")) > v3)
+
+	// commands which do not modify the value keep the version
+	s.Set(m.Key("a"), "2", nil, false, false, false, true)
+	s.GetEx(m.Key("a"), nil, false)
+	s.Persist(m.Key("missing"))
+	val, version, err := s.GetVer(m.Key("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2", *val)
+	assert.Equal(t, v3, version)
+
+	// a deleted key never gets an old version back
+	assert.Equal(t, 0, s.DelIfVer(m.Key("a"), v2))
+	assert.Equal(t, 1, s.DelIfVer(m.Key("a"), v3))
+	s.Set(m.Key("a"), "1", nil, false, false, false, false)
+	assert.True(t, s.Version(m.Key("a")) > v3)
+
+	_, _, err = s.GetVer(m.Key("l"))
+	assert.Equal(t, m.ErrWrongType, err)
+}
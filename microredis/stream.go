@@ -0,0 +1,667 @@
+package microredis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// streamNodeSize is the number of entries of a node of a stream in redis,
+// approximate trimming only removes whole nodes worth of entries
+const streamNodeSize = 100
+
+// errInvalidStreamID is returned when an argument is not a valid stream ID
+var errInvalidStreamID = errors.New("ERR Invalid stream ID specified as stream command argument")
+
+// errStreamIDTooSmall is returned when XADD is given an ID which is not
+// higher than the ID of the last entry of the stream
+var errStreamIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+
+// StreamID struct denotes the ID of a stream entry, the milliseconds time
+// the entry was added at and a sequence number for entries added within
+// the same millisecond
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// maxStreamID is the highest possible stream ID
+var maxStreamID = StreamID{math.MaxUint64, math.MaxUint64}
+
+// String function formats the ID the way redis does, as ms-seq
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Less function returns whether the ID is lower than other
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+
+// next function returns the ID following id, ok is false if id is the
+// highest possible ID
+func (id StreamID) next() (StreamID, bool) {
+	if id.Seq < math.MaxUint64 {
+		return StreamID{id.Ms, id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return StreamID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// prev function returns the ID preceding id, ok is false if id is 0-0
+func (id StreamID) prev() (StreamID, bool) {
+	if id.Seq > 0 {
+		return StreamID{id.Ms, id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return StreamID{id.Ms - 1, math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// StreamEntry struct denotes an entry of a stream, Fields holds its fields
+// and values flattened as field1, value1, field2, value2...
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// Stream struct denotes the stream datatype, an append only log of entries
+// ordered by their IDs. lastID is the ID of the last entry ever added
+// which may have been deleted since, new entries must have a higher ID
+// Unlike the other aggregate datatypes an empty stream is not deleted
+type Stream struct {
+	entries []StreamEntry
+	lastID  StreamID
+}
+
+// NewStream function creates and initializes an empty Stream
+func NewStream() *Stream {
+	return &Stream{}
+}
+
+// Len function returns the number of entries of the stream
+func (st *Stream) Len() int {
+	return len(st.entries)
+}
+
+// LastID function returns the ID of the last entry added to the stream
+func (st *Stream) LastID() StreamID {
+	return st.lastID
+}
+
+// search function returns the position of the first entry whose ID is not
+// lower than id
+func (st *Stream) search(id StreamID) int {
+	return sort.Search(len(st.entries), func(i int) bool { return !st.entries[i].ID.Less(id) })
+}
+
+// Range function returns the entries whose ID is between start and end,
+// both inclusive, from the last one if reverse is set. At most count
+// entries are returned, all of them if count is negative
+func (st *Stream) Range(start StreamID, end StreamID, reverse bool, count int) []StreamEntry {
+	result := []StreamEntry{}
+	if end.Less(start) {
+		return result
+	}
+	first, last := st.search(start), len(st.entries)
+	if next, ok := end.next(); ok {
+		last = st.search(next)
+	}
+	for i := first; i < last && count != 0; i++ {
+		if reverse {
+			result = append(result, st.entries[first+last-1-i])
+		} else {
+			result = append(result, st.entries[i])
+		}
+		count--
+	}
+	return result
+}
+
+// Delete function deletes the entry of id from the stream, it returns false
+// if there is no such entry
+func (st *Stream) Delete(id StreamID) bool {
+	i := st.search(id)
+	if i == len(st.entries) || st.entries[i].ID != id {
+		return false
+	}
+	st.entries = append(st.entries[:i], st.entries[i+1:]...)
+	return true
+}
+
+// StreamTrim struct holds the trimming options of XADD and XTRIM. Strategy
+// is either MAXLEN to keep at most MaxLen entries or MINID to drop the
+// entries whose ID is lower than MinID. With Approx trimming only removes
+// whole nodes worth of entries the same way redis does, and at most Limit
+// entries, 0 meaning the default limit of 100 nodes
+type StreamTrim struct {
+	Strategy string
+	MaxLen   int64
+	MinID    StreamID
+	Approx   bool
+	Limit    int64
+}
+
+// Trim function removes the oldest entries of the stream following trim
+// and returns the number of entries removed
+func (st *Stream) Trim(trim StreamTrim) int {
+	remove := 0
+	switch trim.Strategy {
+	case "MAXLEN":
+		if int64(len(st.entries)) > trim.MaxLen {
+			remove = len(st.entries) - int(trim.MaxLen)
+		}
+	case "MINID":
+		remove = st.search(trim.MinID)
+	}
+	if trim.Approx {
+		limit := trim.Limit
+		if limit == 0 {
+			limit = 100 * streamNodeSize
+		}
+		if int64(remove) > limit {
+			remove = int(limit)
+		}
+		remove -= remove % streamNodeSize
+	}
+	st.entries = st.entries[remove:]
+	return remove
+}
+
+// getStream function returns the stream stored at key. If the key does not
+// exist nil is returned, unless create is set in which case a new empty
+// stream is stored at key. ErrWrongType is returned if the key holds
+// another datatype
+func (s *Storage) getStream(key Key, create bool) (*Stream, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		if !create {
+			return nil, nil
+		}
+		result := NewStream()
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	result, ok := val.val.(*Stream)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// XAdd function appends an entry of fields to the stream stored at key,
+// creating the stream if it does not exist unless no_mkstream is set in
+// which case nil is returned. The ID of the entry is id, unless auto_ms is
+// set and it is generated from the current time or auto_seq is set and only
+// its sequence number is generated. The stream is trimmed following trim
+// after adding the entry. Returns the ID of the entry
+func (s *Storage) XAdd(key Key, id StreamID, auto_ms bool, auto_seq bool, fields []string, no_mkstream bool, trim StreamTrim) (*StreamID, error) {
+	st, err := s.getStream(key, false)
+	if err != nil {
+		return nil, err
+	}
+	last := StreamID{}
+	if st != nil {
+		last = st.lastID
+	}
+	switch {
+	case auto_ms:
+		id = StreamID{uint64(time.Now().UnixMilli()), 0}
+		if !last.Less(id) {
+			next, ok := last.next()
+			if !ok {
+				return nil, errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+			}
+			id = next
+		}
+	case auto_seq:
+		if id.Ms == last.Ms {
+			if last.Seq == math.MaxUint64 {
+				return nil, errStreamIDTooSmall
+			}
+			id.Seq = last.Seq + 1
+		} else if id.Ms < last.Ms {
+			return nil, errStreamIDTooSmall
+		}
+	}
+	if id == (StreamID{}) {
+		return nil, errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	}
+	if !last.Less(id) {
+		return nil, errStreamIDTooSmall
+	}
+	if st == nil {
+		if no_mkstream {
+			return nil, nil
+		}
+		st, _ = s.getStream(key, true)
+	}
+	st.entries = append(st.entries, StreamEntry{id, fields})
+	st.lastID = id
+	st.Trim(trim)
+	s.keyModified(key)
+	return &id, nil
+}
+
+// XLen function returns the number of entries of the stream stored at key
+func (s *Storage) XLen(key Key) (int, error) {
+	st, err := s.getStream(key, false)
+	if st == nil {
+		return 0, err
+	}
+	return st.Len(), nil
+}
+
+// XRange function returns the entries of the stream stored at key whose ID
+// is between start and end, see Stream.Range
+func (s *Storage) XRange(key Key, start StreamID, end StreamID, reverse bool, count int) ([]StreamEntry, error) {
+	st, err := s.getStream(key, false)
+	if st == nil {
+		return []StreamEntry{}, err
+	}
+	return st.Range(start, end, reverse, count), nil
+}
+
+// XDel function deletes the entries of ids from the stream stored at key
+// and returns the number of entries deleted
+func (s *Storage) XDel(key Key, ids []StreamID) (int, error) {
+	st, err := s.getStream(key, false)
+	if st == nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, id := range ids {
+		if st.Delete(id) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		s.keyModified(key)
+	}
+	return deleted, nil
+}
+
+// XTrim function trims the stream stored at key following trim and returns
+// the number of entries removed
+func (s *Storage) XTrim(key Key, trim StreamTrim) (int, error) {
+	st, err := s.getStream(key, false)
+	if st == nil {
+		return 0, err
+	}
+	removed := st.Trim(trim)
+	if removed > 0 {
+		s.keyModified(key)
+	}
+	return removed, nil
+}
+
+// XLastID function returns the ID of the last entry added to the stream
+// stored at key, 0-0 if the key does not exist
+func (s *Storage) XLastID(key Key) (StreamID, error) {
+	st, err := s.getStream(key, false)
+	if st == nil {
+		return StreamID{}, err
+	}
+	return st.lastID, nil
+}
+
+// parseStreamID function parses a stream ID given as ms-seq, an ID without
+// the sequence number gets missing_seq as its sequence number
+func parseStreamID(arg string, missing_seq uint64) (StreamID, error) {
+	ms_arg, seq_arg, has_seq := strings.Cut(arg, "-")
+	ms, err := strconv.ParseUint(ms_arg, 10, 64)
+	if err != nil {
+		return StreamID{}, errInvalidStreamID
+	}
+	if !has_seq {
+		return StreamID{ms, missing_seq}, nil
+	}
+	seq, err := strconv.ParseUint(seq_arg, 10, 64)
+	if err != nil {
+		return StreamID{}, errInvalidStreamID
+	}
+	return StreamID{ms, seq}, nil
+}
+
+// parseRangeID function parses the start or end of a range of stream IDs
+// where - and + are the lowest and highest IDs and an ID prefixed with ( is
+// exclusive. ok is false if the range is empty due to an exclusive bound
+// which can't be moved past
+func parseRangeID(arg string, is_start bool) (StreamID, bool, error) {
+	switch arg {
+	case "-":
+		return StreamID{}, true, nil
+	case "+":
+		return maxStreamID, true, nil
+	}
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+	missing_seq := uint64(0)
+	if !is_start {
+		missing_seq = math.MaxUint64
+	}
+	id, err := parseStreamID(arg, missing_seq)
+	if err != nil || !exclusive {
+		return id, true, err
+	}
+	if is_start {
+		id, ok := id.next()
+		return id, ok, nil
+	}
+	id, ok := id.prev()
+	return id, ok, nil
+}
+
+// parseStreamTrim function parses the MAXLEN or MINID option at args[i]
+// of XADD and XTRIM along with its arguments and returns the index of the
+// last argument of the option
+func parseStreamTrim(args []string, i int, trim *StreamTrim) (int, error) {
+	trim.Strategy = strings.ToUpper(args[i])
+	i++
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		trim.Approx = args[i] == "~"
+		i++
+	}
+	if i >= len(args) {
+		return i, ErrSyntax
+	}
+	if trim.Strategy == "MAXLEN" {
+		val, err := strconv.ParseInt(args[i], 10, 64)
+		if err != nil {
+			return i, ErrNotInteger
+		}
+		if val < 0 {
+			return i, errors.New("ERR The MAXLEN argument must be >= 0.")
+		}
+		trim.MaxLen = val
+	} else {
+		id, err := parseStreamID(args[i], 0)
+		if err != nil {
+			return i, err
+		}
+		trim.MinID = id
+	}
+	if i+1 < len(args) && strings.ToUpper(args[i+1]) == "LIMIT" {
+		if i+2 >= len(args) {
+			return i, ErrSyntax
+		}
+		val, err := strconv.ParseInt(args[i+2], 10, 64)
+		if err != nil || val < 0 {
+			return i, errors.New("ERR The LIMIT argument must be >= 0.")
+		}
+		if !trim.Approx {
+			return i, errors.New("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		trim.Limit = val
+		i += 2
+	}
+	return i, nil
+}
+
+// streamEntriesReply function converts entries into a reply, an array of
+// entries where each entry is an array of its ID and its fields
+func streamEntriesReply(entries []StreamEntry) []interface{} {
+	result := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		result[i] = []interface{}{entry.ID.String(), entry.Fields}
+	}
+	return result
+}
+
+// ProcessRespCommandXAdd function processes redis command XADD
+func (s *Server) ProcessRespCommandXAdd(c *Connection, commands []string) (interface{}, error) {
+	no_mkstream := false
+	trim := StreamTrim{}
+	i := 2
+options:
+	for ; i < len(commands); i++ {
+		switch strings.ToUpper(commands[i]) {
+		case "NOMKSTREAM":
+			no_mkstream = true
+		case "MAXLEN", "MINID":
+			end, err := parseStreamTrim(commands, i, &trim)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		default:
+			break options
+		}
+	}
+	if i+1 >= len(commands) || (len(commands)-i-1)%2 != 0 {
+		return nil, errors.New(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(commands[0])))
+	}
+	fields := commands[i+1:]
+	id, auto_ms, auto_seq := StreamID{}, false, false
+	if commands[i] == "*" {
+		auto_ms = true
+	} else if strings.HasSuffix(commands[i], "-*") {
+		ms, err := strconv.ParseUint(strings.TrimSuffix(commands[i], "-*"), 10, 64)
+		if err != nil {
+			return nil, errInvalidStreamID
+		}
+		id, auto_seq = StreamID{ms, 0}, true
+	} else {
+		parsed, err := parseStreamID(commands[i], 0)
+		if err != nil {
+			return nil, err
+		}
+		id = parsed
+	}
+	result, err := s.db.XAdd(Key(commands[1]), id, auto_ms, auto_seq, fields, no_mkstream, trim)
+	if result == nil {
+		return nil, err
+	}
+	return result.String(), nil
+}
+
+// ProcessRespCommandXLen function processes redis command XLEN
+func (s *Server) ProcessRespCommandXLen(c *Connection, commands []string) (interface{}, error) {
+	return s.db.XLen(Key(commands[1]))
+}
+
+// ProcessRespCommandXRange function processes redis command XRANGE
+func (s *Server) ProcessRespCommandXRange(c *Connection, commands []string) (interface{}, error) {
+	return s.processXRange(commands, false)
+}
+
+// ProcessRespCommandXRevRange function processes redis command XREVRANGE
+// which takes the end of the range before its start
+func (s *Server) ProcessRespCommandXRevRange(c *Connection, commands []string) (interface{}, error) {
+	return s.processXRange(commands, true)
+}
+
+// processXRange function implements XRANGE and XREVRANGE
+func (s *Server) processXRange(commands []string, reverse bool) (interface{}, error) {
+	start_arg, end_arg := commands[2], commands[3]
+	if reverse {
+		start_arg, end_arg = end_arg, start_arg
+	}
+	start, start_ok, err := parseRangeID(start_arg, true)
+	if err != nil {
+		return nil, err
+	}
+	end, end_ok, err := parseRangeID(end_arg, false)
+	if err != nil {
+		return nil, err
+	}
+	count := int64(-1)
+	if len(commands) > 4 {
+		if len(commands) != 6 || strings.ToUpper(commands[4]) != "COUNT" {
+			return nil, ErrSyntax
+		}
+		val, err := strconv.ParseInt(commands[5], 10, 64)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		if val < 0 {
+			val = 0
+		}
+		count = val
+	}
+	if !start_ok || !end_ok {
+		return []interface{}{}, nil
+	}
+	result, err := s.db.XRange(Key(commands[1]), start, end, reverse, int(count))
+	if err != nil {
+		return nil, err
+	}
+	return streamEntriesReply(result), nil
+}
+
+// ProcessRespCommandXDel function processes redis command XDEL
+func (s *Server) ProcessRespCommandXDel(c *Connection, commands []string) (interface{}, error) {
+	ids := make([]StreamID, len(commands)-2)
+	for i, arg := range commands[2:] {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return s.db.XDel(Key(commands[1]), ids)
+}
+
+// ProcessRespCommandXTrim function processes redis command XTRIM
+func (s *Server) ProcessRespCommandXTrim(c *Connection, commands []string) (interface{}, error) {
+	strategy := strings.ToUpper(commands[2])
+	if strategy != "MAXLEN" && strategy != "MINID" {
+		return nil, ErrSyntax
+	}
+	trim := StreamTrim{}
+	end, err := parseStreamTrim(commands, 2, &trim)
+	if err != nil {
+		return nil, err
+	}
+	if end != len(commands)-1 {
+		return nil, ErrSyntax
+	}
+	return s.db.XTrim(Key(commands[1]), trim)
+}
+
+// xreadStreams function returns the index of the STREAMS option of XREAD
+// and the number of keys following it, the keys are followed by as many IDs
+func xreadStreams(commands []string) (int, int, error) {
+	for i := 1; i < len(commands); i++ {
+		switch strings.ToUpper(commands[i]) {
+		case "COUNT", "BLOCK":
+			i++
+		case "STREAMS":
+			rest := len(commands) - i - 1
+			if rest == 0 || rest%2 != 0 {
+				return 0, 0, errors.New("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+			}
+			return i, rest / 2, nil
+		default:
+			return 0, 0, ErrSyntax
+		}
+	}
+	return 0, 0, ErrSyntax
+}
+
+// xreadKeys function returns the keys of XREAD, to be used as its
+// MovableKeys
+func xreadKeys(commands []string) []Key {
+	i, numkeys, err := xreadStreams(commands)
+	if err != nil {
+		return []Key{}
+	}
+	return toKeys(commands[i+1 : i+1+numkeys])
+}
+
+// ProcessRespCommandXRead function processes redis command XREAD. For each
+// of the streams the entries with an ID higher than the given one are
+// replied, $ being the ID of the last entry of the stream. With BLOCK the
+// client is blocked until one of the streams gets new entries if none of
+// them has any, in which case $ refers to the last entry at the time the
+// client blocked
+func (s *Server) ProcessRespCommandXRead(c *Connection, commands []string) (interface{}, error) {
+	streams, numkeys, err := xreadStreams(commands)
+	if err != nil {
+		return nil, err
+	}
+	count := int64(-1)
+	block := false
+	timeout := time.Duration(0)
+	for i := 1; i < streams; i += 2 {
+		if strings.ToUpper(commands[i]) == "COUNT" {
+			val, err := strconv.ParseInt(commands[i+1], 10, 64)
+			if err != nil {
+				return nil, ErrNotInteger
+			}
+			if val > 0 {
+				count = val
+			}
+		} else {
+			block = true
+			if timeout, err = parseTimeoutMillis(commands[i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	keys := toKeys(commands[streams+1 : streams+1+numkeys])
+	ids := make([]StreamID, numkeys)
+	resolved := false
+	for i, arg := range commands[streams+1+numkeys:] {
+		if arg == "$" {
+			last, err := s.db.XLastID(keys[i])
+			if err != nil {
+				return nil, err
+			}
+			ids[i] = last
+			resolved = true
+			continue
+		}
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	result := []interface{}{}
+	for i, key := range keys {
+		start, ok := ids[i].next()
+		if !ok {
+			continue
+		}
+		entries, err := s.db.XRange(key, start, maxStreamID, false, int(count))
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			result = append(result, []interface{}{string(key), streamEntriesReply(entries)})
+		}
+	}
+	if len(result) > 0 {
+		if c.proto >= 3 {
+			reply := MapReply{}
+			for _, stream := range result {
+				reply = append(reply, stream.([]interface{})...)
+			}
+			return reply, nil
+		}
+		return result, nil
+	}
+	if !block {
+		return NullArray{}, nil
+	}
+	b := blockOn{keys: keys, timeout: timeout}
+	if resolved {
+		// block with the IDs $ referred to, so that the entries added while
+		// blocked are replied
+		b.commands = append([]string{}, commands[:streams+1+numkeys]...)
+		for _, id := range ids {
+			b.commands = append(b.commands, id.String())
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,204 @@
+package microredis_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func streamIDs(entries []m.StreamEntry) []string {
+	result := make([]string, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.ID.String()
+	}
+	return result
+}
+
+func TestStream(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	for i := 1; i <= 5; i++ {
+		id, err := s.XAdd(m.Key("s"), m.StreamID{Ms: 1, Seq: uint64(i)}, false, false, []string{"f", strconv.Itoa(i)}, false, m.StreamTrim{})
+		assert.Nil(t, err)
+		assert.Equal(t, "1-"+strconv.Itoa(i), id.String())
+	}
+
+	entries, err := s.XRange(m.Key("s"), m.StreamID{Ms: 1, Seq: 2}, m.StreamID{Ms: 1, Seq: 4}, false, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1-2", "1-3", "1-4"}, streamIDs(entries))
+	assert.Equal(t, []string{"f", "2"}, entries[0].Fields)
+	entries, _ = s.XRange(m.Key("s"), m.StreamID{}, m.StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}, true, 2)
+	assert.Equal(t, []string{"1-5", "1-4"}, streamIDs(entries))
+	entries, _ = s.XRange(m.Key("s"), m.StreamID{Ms: 2}, m.StreamID{Ms: 1}, false, -1)
+	assert.Equal(t, []string{}, streamIDs(entries))
+
+	deleted, _ := s.XDel(m.Key("s"), []m.StreamID{{Ms: 1, Seq: 5}, {Ms: 1, Seq: 9}})
+	assert.Equal(t, 1, deleted)
+	last, _ := s.XLastID(m.Key("s"))
+	assert.Equal(t, "1-5", last.String())
+
+	// new entries must be above the last ID even once it is deleted
+	_, err = s.XAdd(m.Key("s"), m.StreamID{Ms: 1, Seq: 5}, false, false, []string{"f", "v"}, false, m.StreamTrim{})
+	assert.Equal(t, "ERR The ID specified in XADD is equal or smaller than the target stream top item", err.Error())
+	id, _ := s.XAdd(m.Key("s"), m.StreamID{Ms: 1}, false, true, []string{"f", "v"}, false, m.StreamTrim{})
+	assert.Equal(t, "1-6", id.String())
+	id, _ = s.XAdd(m.Key("s"), m.StreamID{Ms: 3}, false, true, []string{"f", "v"}, false, m.StreamTrim{})
+	assert.Equal(t, "3-0", id.String())
+	id, _ = s.XAdd(m.Key("s"), m.StreamID{}, true, false, []string{"f", "v"}, false, m.StreamTrim{})
+	assert.Less(t, uint64(time.Now().Add(-time.Minute).UnixMilli()), id.Ms)
+
+	removed, _ := s.XTrim(m.Key("s"), m.StreamTrim{Strategy: "MINID", MinID: m.StreamID{Ms: 1, Seq: 4}})
+	assert.Equal(t, 3, removed)
+	removed, _ = s.XTrim(m.Key("s"), m.StreamTrim{Strategy: "MAXLEN", MaxLen: 0})
+	assert.Equal(t, 4, removed)
+
+	// empty streams are kept
+	length, err := s.XLen(m.Key("s"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, length)
+	assert.Equal(t, "stream", s.Type(m.Key("s")))
+
+	id, err = s.XAdd(m.Key("missing"), m.StreamID{}, true, false, []string{"f", "v"}, true, m.StreamTrim{})
+	assert.Nil(t, err)
+	assert.Nil(t, id)
+	_, err = s.XAdd(m.Key("new"), m.StreamID{}, false, false, []string{"f", "v"}, false, m.StreamTrim{})
+	assert.Equal(t, "ERR The ID specified in XADD must be greater than 0-0", err.Error())
+	id, _ = s.XAdd(m.Key("new"), m.StreamID{}, false, true, []string{"f", "v"}, false, m.StreamTrim{})
+	assert.Equal(t, "0-1", id.String())
+
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, err = s.XLen(m.Key("str"))
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestStreamApproxTrim(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	for i := 1; i <= 250; i++ {
+		s.XAdd(m.Key("s"), m.StreamID{Ms: uint64(i)}, false, false, []string{"f", "v"}, false, m.StreamTrim{})
+	}
+
+	// only whole nodes are removed
+	removed, _ := s.XTrim(m.Key("s"), m.StreamTrim{Strategy: "MAXLEN", MaxLen: 60, Approx: true})
+	assert.Equal(t, 100, removed)
+	removed, _ = s.XTrim(m.Key("s"), m.StreamTrim{Strategy: "MAXLEN", MaxLen: 60, Approx: true, Limit: 50})
+	assert.Equal(t, 0, removed)
+	removed, _ = s.XTrim(m.Key("s"), m.StreamTrim{Strategy: "MINID", MinID: m.StreamID{Ms: 240}, Approx: true})
+	assert.Equal(t, 100, removed)
+	length, _ := s.XLen(m.Key("s"))
+	assert.Equal(t, 50, length)
+	removed, _ = s.XTrim(m.Key("s"), m.StreamTrim{Strategy: "MAXLEN", MaxLen: 0, Approx: true})
+	assert.Equal(t, 0, removed)
+	removed, _ = s.XTrim(m.Key("s"), m.StreamTrim{Strategy: "MAXLEN", MaxLen: 10})
+	assert.Equal(t, 40, removed)
+}
+
+func TestStreamCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"add and range", []step{
+			{args: []string{"XADD", "s", "1-1", "a", "1"}, reply: "$3\r\n1-1\r\n"},
+			{args: []string{"XADD", "s", "1-*", "b", "2"}, reply: "$3\r\n1-2\r\n"},
+			{args: []string{"XADD", "s", "2", "c", "3", "d", "4"}, reply: "$3\r\n2-0\r\n"},
+			{args: []string{"XADD", "s", "*", "e", "5"}, pattern: `^\$\d+\r\n\d{13}-0\r\n$`},
+			{args: []string{"XLEN", "s"}, reply: ":4\r\n"},
+			{args: []string{"TYPE", "s"}, reply: "+stream\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "s"}, reply: "$6\r\nstream\r\n"},
+			{args: []string{"XRANGE", "s", "-", "2"}, reply: "*3\r\n" +
+				"*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n" +
+				"*2\r\n$3\r\n1-2\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n" +
+				"*2\r\n$3\r\n2-0\r\n*4\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\nd\r\n$1\r\n4\r\n"},
+			{args: []string{"XRANGE", "s", "(1-1", "1", "COUNT", "5"}, reply: "*1\r\n*2\r\n$3\r\n1-2\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+			{args: []string{"XRANGE", "s", "1-1", "(1-2"}, reply: "*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+			{args: []string{"XRANGE", "s", "-", "+", "COUNT", "0"}, reply: "*0\r\n"},
+			{args: []string{"XRANGE", "s", "3", "1"}, reply: "*0\r\n"},
+			{args: []string{"XREVRANGE", "s", "2", "-", "COUNT", "2"}, reply: "*2\r\n" +
+				"*2\r\n$3\r\n2-0\r\n*4\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\nd\r\n$1\r\n4\r\n" +
+				"*2\r\n$3\r\n1-2\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+			{args: []string{"XRANGE", "missing", "-", "+"}, reply: "*0\r\n"},
+			{args: []string{"XRANGE", "s", "x", "+"}, reply: "-ERR Invalid stream ID specified as stream command argument\r\n"},
+			{args: []string{"XRANGE", "s", "-", "+", "COUNT"}, reply: "-ERR syntax error\r\n"},
+		}},
+		{"add errors", []step{
+			{args: []string{"XADD", "s", "5-5", "f", "v"}, reply: "$3\r\n5-5\r\n"},
+			{args: []string{"XADD", "s", "5-5", "f", "v"}, reply: "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n"},
+			{args: []string{"XADD", "s", "4-*", "f", "v"}, reply: "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n"},
+			{args: []string{"XADD", "s", "0-0", "f", "v"}, reply: "-ERR The ID specified in XADD must be greater than 0-0\r\n"},
+			{args: []string{"XADD", "s", "x", "f", "v"}, reply: "-ERR Invalid stream ID specified as stream command argument\r\n"},
+			{args: []string{"XADD", "s", "*", "f"}, reply: "-ERR wrong number of arguments for 'xadd' command\r\n"},
+			{args: []string{"XADD", "s", "MAXLEN", "1"}, reply: "-ERR wrong number of arguments for 'xadd' command\r\n"},
+			{args: []string{"XADD", "s", "MAXLEN", "-1", "*", "f", "v"}, reply: "-ERR The MAXLEN argument must be >= 0.\r\n"},
+			{args: []string{"XADD", "s", "MAXLEN", "1", "LIMIT", "5", "*", "f", "v"}, reply: "-ERR syntax error, LIMIT cannot be used without the special ~ option\r\n"},
+			{args: []string{"XADD", "missing", "NOMKSTREAM", "*", "f", "v"}, reply: "$-1\r\n"},
+			{args: []string{"TYPE", "missing"}, reply: "+none\r\n"},
+			{args: []string{"SET", "str", "v"}, reply: "+OK\r\n"},
+			{args: []string{"XADD", "str", "*", "f", "v"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+		{"trim and delete", []step{
+			{args: []string{"XADD", "s", "1", "f", "v"}, reply: "$3\r\n1-0\r\n"},
+			{args: []string{"XADD", "s", "2", "f", "v"}, reply: "$3\r\n2-0\r\n"},
+			{args: []string{"XADD", "s", "MAXLEN", "=", "2", "3", "f", "v"}, reply: "$3\r\n3-0\r\n"},
+			{args: []string{"XLEN", "s"}, reply: ":2\r\n"},
+			{args: []string{"XADD", "s", "MAXLEN", "~", "1", "LIMIT", "10", "4", "f", "v"}, reply: "$3\r\n4-0\r\n"},
+			{args: []string{"XLEN", "s"}, reply: ":3\r\n"},
+			{args: []string{"XTRIM", "s", "MINID", "4"}, reply: ":2\r\n"},
+			{args: []string{"XTRIM", "s", "MAXLEN", "~", "0"}, reply: ":0\r\n"},
+			{args: []string{"XTRIM", "s", "MAXLEN", "1", "x"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"XTRIM", "s", "SIZE", "1"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"XDEL", "s", "4", "5"}, reply: ":1\r\n"},
+			{args: []string{"XLEN", "s"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "s"}, reply: "+stream\r\n"},
+			{args: []string{"XADD", "s", "4", "f", "v"}, reply: "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n"},
+			{args: []string{"XDEL", "s", "x"}, reply: "-ERR Invalid stream ID specified as stream command argument\r\n"},
+		}},
+		{"read", []step{
+			{args: []string{"XADD", "a", "1", "f", "1"}, reply: "$3\r\n1-0\r\n"},
+			{args: []string{"XADD", "a", "2", "f", "2"}, reply: "$3\r\n2-0\r\n"},
+			{args: []string{"XADD", "b", "1", "g", "1"}, reply: "$3\r\n1-0\r\n"},
+			{args: []string{"XREAD", "COUNT", "1", "STREAMS", "a", "b", "0", "1"}, reply: "*1\r\n*2\r\n$1\r\na\r\n" +
+				"*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nf\r\n$1\r\n1\r\n"},
+			{args: []string{"XREAD", "STREAMS", "a", "b", "1", "0"}, reply: "*2\r\n" +
+				"*2\r\n$1\r\na\r\n*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nf\r\n$1\r\n2\r\n" +
+				"*2\r\n$1\r\nb\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\ng\r\n$1\r\n1\r\n"},
+			{args: []string{"XREAD", "STREAMS", "a", "$"}, reply: "*-1\r\n"},
+			{args: []string{"XREAD", "BLOCK", "10", "STREAMS", "a", "missing", "$", "$"}, reply: "*-1\r\n"},
+			{args: []string{"HELLO", "3"}, pattern: `^%7`},
+			{args: []string{"XREAD", "STREAMS", "b", "0"}, reply: "%1\r\n$1\r\nb\r\n" +
+				"*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\ng\r\n$1\r\n1\r\n"},
+			{args: []string{"XREAD", "STREAMS", "b", "$"}, reply: "_\r\n"},
+		}},
+		{"read errors", []step{
+			{args: []string{"XREAD", "STREAMS", "a", "b", "0"}, reply: "-ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.\r\n"},
+			{args: []string{"XREAD", "COUNT", "1", "a", "0"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"XREAD", "BLOCK", "x", "STREAMS", "a", "0"}, reply: "-ERR timeout is not an integer or out of range\r\n"},
+			{args: []string{"XREAD", "BLOCK", "-1", "STREAMS", "a", "0"}, reply: "-ERR timeout is negative\r\n"},
+			{args: []string{"XREAD", "STREAMS", "a", "x"}, reply: "-ERR Invalid stream ID specified as stream command argument\r\n"},
+			{args: []string{"SET", "str", "v"}, reply: "+OK\r\n"},
+			{args: []string{"XREAD", "STREAMS", "str", "0"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}
+
+func TestXReadBlock(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c1 := newTestConn(t, s)
+	c2 := newTestConn(t, s)
+	c3 := newTestConn(t, s)
+
+	assert.Equal(t, "$3\r\n1-0\r\n", c3.do("XADD", "s", "1", "f", "old"))
+	c1.send(m.MarshalResp([]string{"XREAD", "BLOCK", "0", "STREAMS", "s", "$"}))
+	s.WaitParked(t, m.Key("s"), 1)
+	c2.send(m.MarshalResp([]string{"XREAD", "BLOCK", "0", "STREAMS", "other", "s", "0", "$"}))
+	s.WaitParked(t, m.Key("s"), 2)
+
+	// $ refers to the last entry at the time the clients blocked
+	assert.Equal(t, "$3\r\n2-0\r\n", c3.do("XADD", "s", "2", "f", "new"))
+	reply := "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nf\r\n$3\r\nnew\r\n"
+	assert.Equal(t, reply, c1.read())
+	assert.Equal(t, reply, c2.read())
+
+	start := time.Now()
+	assert.Equal(t, "*-1\r\n", c1.do("XREAD", "BLOCK", "50", "STREAMS", "s", "$"))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
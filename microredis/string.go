@@ -0,0 +1,516 @@
+package microredis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxStringLen is the maximum length a string value can grow to through
+// commands which modify it in place such as APPEND and SETRANGE
+const maxStringLen = 512 * 1024 * 1024
+
+// errStringTooLong is returned when a string value would exceed maxStringLen
+var errStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+
+// errLCSTooLarge is returned when the table LCS builds would take more
+// than maxStringLen bytes
+var errLCSTooLarge = errors.New("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+
+// LCSMatch struct denotes a range of the longest common subsequence of
+// two strings, AStart to AEnd is the range in the first string and BStart
+// to BEnd the range in the second one, both ranges are inclusive
+type LCSMatch struct {
+	AStart int
+	AEnd   int
+	BStart int
+	BEnd   int
+}
+
+// Len function returns the length of the matched range
+func (m LCSMatch) Len() int {
+	return m.AEnd - m.AStart + 1
+}
+
+// setString function replaces the value of an existing key with val while
+// retaining its expiry, or creates the key without expiry if it does not
+// exist. Expired keys are expected to be cleared by the caller through Get
+func (s *Storage) setString(key Key, val string) {
+	prev_val := s.data[key]
+	s.data[key] = Value{
+		val:    &val,
+		expiry: prev_val.expiry,
+	}
+	s.keyModified(key)
+}
+
+// MGet function returns the values of all the keys in the same order,
+// nil is returned for keys which do not exist, have expired or do not
+// hold a string
+func (s *Storage) MGet(keys []Key) []*string {
+	result := make([]*string, len(keys))
+	for i, key := range keys {
+		result[i], _ = s.Get(key)
+	}
+	return result
+}
+
+// MSet function sets the values of all the keys in pairs, existing values
+// and their expiry are replaced the same way Set does
+func (s *Storage) MSet(keys []Key, vals []string) {
+	for i, key := range keys {
+		s.Set(key, vals[i], nil, false, false, false, false)
+	}
+}
+
+// MSetNX function sets the values of all the keys in pairs ONLY if none of
+// the keys exist. It returns whether the values were set
+func (s *Storage) MSetNX(keys []Key, vals []string) bool {
+	for _, key := range keys {
+		if _, prs := s.lookup(key); prs {
+			return false
+		}
+	}
+	s.MSet(keys, vals)
+	return true
+}
+
+// IncrBy function increments the integer stored at key by delta and returns
+// the new value. A key which does not exist is treated as 0 and the
+// existing expiry of the key is retained. ErrNotInteger is returned if the
+// value is not the canonical form of a 64 bit integer, such as "+5" or
+// "007", or the result would overflow
+func (s *Storage) IncrBy(key Key, delta int64) (int64, error) {
+	cur := int64(0)
+	val, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if val != nil {
+		parsed, ok := parseCanonicalInt(*val)
+		if !ok {
+			return 0, ErrNotInteger
+		}
+		cur = parsed
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return 0, ErrNotInteger
+	}
+	cur += delta
+	s.setString(key, strconv.FormatInt(cur, 10))
+	return cur, nil
+}
+
+// IncrByFloat function increments the floating point number stored at key
+// by delta and returns the new value. A key which does not exist is treated
+// as 0 and the existing expiry of the key is retained
+func (s *Storage) IncrByFloat(key Key, delta float64) (float64, error) {
+	cur := float64(0)
+	val, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if val != nil {
+		parsed, err := parseFloat(*val)
+		if err != nil {
+			return 0, ErrNotFloat
+		}
+		cur = parsed
+	}
+	cur += delta
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return 0, errors.New("ERR increment would produce NaN or Infinity")
+	}
+	s.setString(key, formatFloat(cur))
+	return cur, nil
+}
+
+// parseFloat function parses a float the way redis does, rejecting NaN
+// and infinities which are not valid values to store
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrNotFloat
+	}
+	return f, nil
+}
+
+// formatFloat function formats a float to be stored as a string value,
+// unlike replies stored values never use the exponent notation
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Append function appends val to the string stored at key, creating it if
+// it does not exist, and returns the length of the string after appending
+func (s *Storage) Append(key Key, val string) (int, error) {
+	cur := ""
+	prev_val, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if prev_val != nil {
+		cur = *prev_val
+	}
+	if len(cur)+len(val) > maxStringLen {
+		return 0, errStringTooLong
+	}
+	s.setString(key, cur+val)
+	return len(cur) + len(val), nil
+}
+
+// StrLen function returns the length of the string stored at key and 0
+// if the key does not exist
+func (s *Storage) StrLen(key Key) (int, error) {
+	val, err := s.Get(key)
+	if val != nil {
+		return len(*val), nil
+	}
+	return 0, err
+}
+
+// GetRange function returns the substring of the string stored at key
+// between the offsets start and end, both inclusive. Negative offsets are
+// counted from the end of the string, -1 being the last character
+func (s *Storage) GetRange(key Key, start int64, end int64) (string, error) {
+	val, err := s.Get(key)
+	if val == nil {
+		return "", err
+	}
+	size := int64(len(*val))
+	if start < 0 {
+		start = size + start
+	}
+	if end < 0 {
+		end = size + end
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if size == 0 || start > end {
+		return "", nil
+	}
+	return (*val)[start : end+1], nil
+}
+
+// SetRange function overwrites the string stored at key starting at offset
+// with val and returns the length of the string after it was modified. If
+// the string is shorter than offset it is padded with zero bytes, a key
+// which does not exist is treated as an empty string
+func (s *Storage) SetRange(key Key, offset int64, val string) (int, error) {
+	if offset < 0 {
+		return 0, errors.New("ERR offset is out of range")
+	}
+	cur := ""
+	prev_val, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if prev_val != nil {
+		cur = *prev_val
+	}
+	// nothing to write, the key is not created either
+	if len(val) == 0 {
+		return len(cur), nil
+	}
+	if offset+int64(len(val)) > maxStringLen {
+		return 0, errStringTooLong
+	}
+
+	size := int(offset) + len(val)
+	if size < len(cur) {
+		size = len(cur)
+	}
+	buf := make([]byte, size)
+	copy(buf, cur)
+	copy(buf[offset:], val)
+	s.setString(key, string(buf))
+	return size, nil
+}
+
+// LCS function finds the longest common subsequence of the strings stored
+// at key1 and key2, keys which do not exist are treated as empty strings.
+// It returns the subsequence along with the ranges of the two strings that
+// make it up, from the last range to the first one. Ranges shorter than
+// min_match_len are left out
+func (s *Storage) LCS(key1 Key, key2 Key, min_match_len int) (string, []LCSMatch, error) {
+	a, b := "", ""
+	val, err := s.Get(key1)
+	if err != nil {
+		return "", nil, err
+	}
+	if val != nil {
+		a = *val
+	}
+	val, err = s.Get(key2)
+	if err != nil {
+		return "", nil, err
+	}
+	if val != nil {
+		b = *val
+	}
+
+	// dp[i][j] is the length of the LCS of a[:i] and b[:j]
+	alen, blen := len(a), len(b)
+	if int64(alen+1)*int64(blen+1) > maxStringLen/4 {
+		return "", nil, errLCSTooLarge
+	}
+	dp := make([]uint32, (alen+1)*(blen+1))
+	at := func(i int, j int) uint32 {
+		return dp[j*(alen+1)+i]
+	}
+	for i := 1; i <= alen; i++ {
+		for j := 1; j <= blen; j++ {
+			if a[i-1] == b[j-1] {
+				dp[j*(alen+1)+i] = at(i-1, j-1) + 1
+			} else if lcs1, lcs2 := at(i-1, j), at(i, j-1); lcs1 > lcs2 {
+				dp[j*(alen+1)+i] = lcs1
+			} else {
+				dp[j*(alen+1)+i] = lcs2
+			}
+		}
+	}
+
+	// walk back from the end of both strings collecting the subsequence
+	// and the contiguous ranges it is made of
+	idx := at(alen, blen)
+	result := make([]byte, idx)
+	matches := []LCSMatch{}
+	cur := LCSMatch{AStart: alen}
+	i, j := alen, blen
+	for i > 0 && j > 0 {
+		emit_range := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+			if cur.AStart == alen {
+				cur = LCSMatch{AStart: i - 1, AEnd: i - 1, BStart: j - 1, BEnd: j - 1}
+			} else if cur.AStart == i && cur.BStart == j {
+				// the range is contiguous so extend it backwards
+				cur.AStart--
+				cur.BStart--
+			} else {
+				emit_range = true
+			}
+			if cur.AStart == 0 || cur.BStart == 0 {
+				emit_range = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if at(i-1, j) > at(i, j-1) {
+				i--
+			} else {
+				j--
+			}
+			if cur.AStart != alen {
+				emit_range = true
+			}
+		}
+		if emit_range {
+			if cur.Len() >= min_match_len {
+				matches = append(matches, cur)
+			}
+			cur = LCSMatch{AStart: alen}
+		}
+	}
+	return string(result), matches, nil
+}
+
+// ProcessRespCommandMGet function processes redis command MGET
+func (s *Server) ProcessRespCommandMGet(c *Connection, commands []string) (interface{}, error) {
+	keys := make([]Key, 0)
+	for _, k := range commands[1:] {
+		keys = append(keys, Key(k))
+	}
+	result := []interface{}{}
+	for _, val := range s.db.MGet(keys) {
+		if val == nil {
+			result = append(result, nil)
+		} else {
+			result = append(result, *val)
+		}
+	}
+	return result, nil
+}
+
+// keyValuePairs function splits the arguments of MSET like commands into
+// keys and values, the arguments have to be in pairs
+func keyValuePairs(commands []string) ([]Key, []string, error) {
+	if len(commands)%2 == 0 {
+		return nil, nil, errors.New(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(commands[0])))
+	}
+	keys := make([]Key, 0)
+	vals := make([]string, 0)
+	for i := 1; i < len(commands); i += 2 {
+		keys = append(keys, Key(commands[i]))
+		vals = append(vals, commands[i+1])
+	}
+	return keys, vals, nil
+}
+
+// ProcessRespCommandMSet function processes redis command MSET
+func (s *Server) ProcessRespCommandMSet(c *Connection, commands []string) (interface{}, error) {
+	keys, vals, err := keyValuePairs(commands)
+	if err != nil {
+		return nil, err
+	}
+	s.db.MSet(keys, vals)
+	return SimpleString("OK"), nil
+}
+
+// ProcessRespCommandMSetNX function processes redis command MSETNX
+func (s *Server) ProcessRespCommandMSetNX(c *Connection, commands []string) (interface{}, error) {
+	keys, vals, err := keyValuePairs(commands)
+	if err != nil {
+		return nil, err
+	}
+	if s.db.MSetNX(keys, vals) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// ProcessRespCommandIncr function processes redis command INCR
+func (s *Server) ProcessRespCommandIncr(c *Connection, commands []string) (interface{}, error) {
+	return s.db.IncrBy(Key(commands[1]), 1)
+}
+
+// ProcessRespCommandDecr function processes redis command DECR
+func (s *Server) ProcessRespCommandDecr(c *Connection, commands []string) (interface{}, error) {
+	return s.db.IncrBy(Key(commands[1]), -1)
+}
+
+// ProcessRespCommandIncrBy function processes redis command INCRBY
+func (s *Server) ProcessRespCommandIncrBy(c *Connection, commands []string) (interface{}, error) {
+	delta, ok := parseCanonicalInt(commands[2])
+	if !ok {
+		return nil, ErrNotInteger
+	}
+	return s.db.IncrBy(Key(commands[1]), delta)
+}
+
+// ProcessRespCommandDecrBy function processes redis command DECRBY
+func (s *Server) ProcessRespCommandDecrBy(c *Connection, commands []string) (interface{}, error) {
+	delta, ok := parseCanonicalInt(commands[2])
+	if !ok || delta == math.MinInt64 {
+		return nil, ErrNotInteger
+	}
+	return s.db.IncrBy(Key(commands[1]), -delta)
+}
+
+// ProcessRespCommandIncrByFloat function processes redis command INCRBYFLOAT
+// The new value is replied as a bulk string the same way it is stored
+func (s *Server) ProcessRespCommandIncrByFloat(c *Connection, commands []string) (interface{}, error) {
+	delta, err := parseFloat(commands[2])
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.IncrByFloat(Key(commands[1]), delta)
+	if err != nil {
+		return nil, err
+	}
+	return formatFloat(result), nil
+}
+
+// ProcessRespCommandAppend function processes redis command APPEND
+func (s *Server) ProcessRespCommandAppend(c *Connection, commands []string) (interface{}, error) {
+	return s.db.Append(Key(commands[1]), commands[2])
+}
+
+// ProcessRespCommandStrLen function processes redis command STRLEN
+func (s *Server) ProcessRespCommandStrLen(c *Connection, commands []string) (interface{}, error) {
+	return s.db.StrLen(Key(commands[1]))
+}
+
+// ProcessRespCommandGetRange function processes redis command GETRANGE
+func (s *Server) ProcessRespCommandGetRange(c *Connection, commands []string) (interface{}, error) {
+	start, err := strconv.ParseInt(commands[2], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	end, err := strconv.ParseInt(commands[3], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	return s.db.GetRange(Key(commands[1]), start, end)
+}
+
+// ProcessRespCommandSetRange function processes redis command SETRANGE
+func (s *Server) ProcessRespCommandSetRange(c *Connection, commands []string) (interface{}, error) {
+	offset, err := strconv.ParseInt(commands[2], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	return s.db.SetRange(Key(commands[1]), offset, commands[3])
+}
+
+// ProcessRespCommandLCS function processes redis command LCS
+// By default the subsequence itself is replied, LEN replies only its
+// length and IDX the matched ranges along with the length
+func (s *Server) ProcessRespCommandLCS(c *Connection, commands []string) (interface{}, error) {
+	get_len := false
+	get_idx := false
+	with_match_len := false
+	min_match_len := int64(0)
+
+	for i := 3; i < len(commands); i++ {
+		switch strings.ToUpper(commands[i]) {
+		case "LEN":
+			get_len = true
+		case "IDX":
+			get_idx = true
+		case "WITHMATCHLEN":
+			with_match_len = true
+		case "MINMATCHLEN":
+			if i+1 == len(commands) {
+				return nil, ErrSyntax
+			}
+			val, err := strconv.ParseInt(commands[i+1], 10, 64)
+			if err != nil {
+				return nil, ErrNotInteger
+			}
+			if val > 0 {
+				min_match_len = val
+			}
+			i++
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	if get_len && get_idx {
+		return nil, errors.New("ERR If you want both the length and indexes, please just use IDX.")
+	}
+
+	lcs, matches, err := s.db.LCS(Key(commands[1]), Key(commands[2]), int(min_match_len))
+	if err != nil {
+		return nil, err
+	}
+	if get_len {
+		return int64(len(lcs)), nil
+	}
+	if !get_idx {
+		return lcs, nil
+	}
+
+	result := []interface{}{}
+	for _, m := range matches {
+		match := []interface{}{
+			[]interface{}{int64(m.AStart), int64(m.AEnd)},
+			[]interface{}{int64(m.BStart), int64(m.BEnd)},
+		}
+		if with_match_len {
+			match = append(match, int64(m.Len()))
+		}
+		result = append(result, match)
+	}
+	return MapReply{"matches", result, "len", int64(len(lcs))}, nil
+}
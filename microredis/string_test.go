@@ -0,0 +1,356 @@
+package microredis_test
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestIncrBy(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	// missing key starts from 0
+	result, err := s.IncrBy(m.Key("counter"), 5)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), result)
+
+	result, err = s.IncrBy(m.Key("counter"), -7)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-2), result)
+	assert.Equal(t, "-2", *getString(t, s, m.Key("counter")))
+
+	// existing ttl is retained
+	exp := time.Now().Add(time.Minute)
+	s.Set(m.Key("limited"), "10", &exp, false, false, false, false)
+	result, err = s.IncrBy(m.Key("limited"), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), result)
+	assert.True(t, s.TTL(m.Key("limited")) > 50)
+
+	// non numeric values and overflow
+	s.Set(m.Key("text"), "abc", nil, false, false, false, false)
+	_, err = s.IncrBy(m.Key("text"), 1)
+	assert.Equal(t, m.ErrNotInteger, err)
+
+	s.Set(m.Key("float"), "1.5", nil, false, false, false, false)
+	_, err = s.IncrBy(m.Key("float"), 1)
+	assert.Equal(t, m.ErrNotInteger, err)
+
+	for _, val := range []string{"+5", "007", " 1", "-0"} {
+		s.Set(m.Key("noncanonical"), val, nil, false, false, false, false)
+		_, err = s.IncrBy(m.Key("noncanonical"), 1)
+		assert.Equal(t, m.ErrNotInteger, err, val)
+	}
+
+	s.Set(m.Key("big"), "9223372036854775807", nil, false, false, false, false)
+	_, err = s.IncrBy(m.Key("big"), 1)
+	assert.Equal(t, m.ErrNotInteger, err)
+	assert.Equal(t, "9223372036854775807", *getString(t, s, m.Key("big")))
+
+	s.Set(m.Key("small"), "-9223372036854775808", nil, false, false, false, false)
+	_, err = s.IncrBy(m.Key("small"), -1)
+	assert.Equal(t, m.ErrNotInteger, err)
+}
+
+func TestIncrByFloat(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	result, err := s.IncrByFloat(m.Key("f"), 10.5)
+	assert.Nil(t, err)
+	assert.Equal(t, 10.5, result)
+
+	result, err = s.IncrByFloat(m.Key("f"), 0.1)
+	assert.Nil(t, err)
+	assert.InDelta(t, 10.6, result, 1e-9)
+	assert.Equal(t, "10.6", *getString(t, s, m.Key("f")))
+
+	// large numbers are stored without exponent
+	s.IncrByFloat(m.Key("large"), 1e20)
+	assert.Equal(t, "100000000000000000000", *getString(t, s, m.Key("large")))
+
+	s.Set(m.Key("text"), "abc", nil, false, false, false, false)
+	_, err = s.IncrByFloat(m.Key("text"), 1)
+	assert.Equal(t, m.ErrNotFloat, err)
+
+	_, err = s.IncrByFloat(m.Key("f"), math.MaxFloat64)
+	assert.Nil(t, err)
+	_, err = s.IncrByFloat(m.Key("f"), math.MaxFloat64)
+	assert.EqualError(t, err, "ERR increment would produce NaN or Infinity")
+}
+
+func TestCounterCommands(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c := newTestConn(t, s)
+
+	assert.Equal(t, ":1\r\n", c.do("INCR", "n"))
+	assert.Equal(t, ":11\r\n", c.do("INCRBY", "n", "10"))
+	assert.Equal(t, ":10\r\n", c.do("DECR", "n"))
+	assert.Equal(t, ":-5\r\n", c.do("DECRBY", "n", "15"))
+	assert.Equal(t, "$4\r\n-4.5\r\n", c.do("INCRBYFLOAT", "n", "0.5"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", c.do("INCR", "n"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", c.do("INCRBY", "n", "x"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", c.do("INCRBY", "n", "+5"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", c.do("DECRBY", "n", "007"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", c.do("DECRBY", "n", "-9223372036854775808"))
+	assert.Equal(t, "-ERR value is not a valid float\r\n", c.do("INCRBYFLOAT", "n", "nan"))
+}
+
+func TestAppendStrLen(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	n, err := s.Append(m.Key("log"), "hello")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	n, _ = s.Append(m.Key("log"), " world")
+	assert.Equal(t, 11, n)
+	assert.Equal(t, "hello world", *getString(t, s, m.Key("log")))
+	size, err := s.StrLen(m.Key("log"))
+	assert.NoError(t, err)
+	assert.Equal(t, 11, size)
+	size, err = s.StrLen(m.Key("missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, size)
+}
+
+func TestGetRange(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	s.Set(m.Key("k"), "This is a string", nil, false, false, false, false)
+
+	var cases = []struct {
+		start  int64
+		end    int64
+		result string
+	}{
+		{0, 3, "This"},
+		{-3, -1, "ing"},
+		{0, -1, "This is a string"},
+		{10, 100, "string"},
+		{-100, 3, "This"},
+		{5, 2, ""},
+		{100, 200, ""},
+	}
+	for _, cs := range cases {
+		result, err := s.GetRange(m.Key("k"), cs.start, cs.end)
+		assert.NoError(t, err)
+		assert.Equal(t, cs.result, result)
+	}
+	result, err := s.GetRange(m.Key("missing"), 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, "", result)
+}
+
+func TestSetRange(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	s.Set(m.Key("k"), "Hello World", nil, false, false, false, false)
+
+	n, err := s.SetRange(m.Key("k"), 6, "Redis")
+	assert.Nil(t, err)
+	assert.Equal(t, 11, n)
+	assert.Equal(t, "Hello Redis", *getString(t, s, m.Key("k")))
+
+	// writing past the end pads with zero bytes
+	n, _ = s.SetRange(m.Key("new"), 3, "ab")
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "\x00\x00\x00ab", *getString(t, s, m.Key("new")))
+
+	// empty value doesn't create the key
+	n, _ = s.SetRange(m.Key("none"), 10, "")
+	assert.Equal(t, 0, n)
+	assert.Nil(t, getString(t, s, m.Key("none")))
+
+	_, err = s.SetRange(m.Key("k"), -1, "x")
+	assert.EqualError(t, err, "ERR offset is out of range")
+	_, err = s.SetRange(m.Key("k"), 512*1024*1024, "x")
+	assert.EqualError(t, err, "ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+}
+
+func TestLCS(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	s.Set(m.Key("key1"), "ohmytext", nil, false, false, false, false)
+	s.Set(m.Key("key2"), "mynewtext", nil, false, false, false, false)
+
+	lcs, matches, err := s.LCS(m.Key("key1"), m.Key("key2"), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "mytext", lcs)
+	assert.Equal(t, []m.LCSMatch{{4, 7, 5, 8}, {2, 3, 0, 1}}, matches)
+
+	_, matches, _ = s.LCS(m.Key("key1"), m.Key("key2"), 4)
+	assert.Equal(t, []m.LCSMatch{{4, 7, 5, 8}}, matches)
+
+	lcs, matches, err = s.LCS(m.Key("key1"), m.Key("missing"), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "", lcs)
+	assert.Empty(t, matches)
+
+	// the table for two 12KB strings would take more than 512MB
+	s.Set(m.Key("large1"), strings.Repeat("a", 12000), nil, false, false, false, false)
+	s.Set(m.Key("large2"), strings.Repeat("b", 12000), nil, false, false, false, false)
+	_, _, err = s.LCS(m.Key("large1"), m.Key("large2"), 0)
+	assert.Equal(t, "ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len", err.Error())
+}
+
+func TestStringCommands(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c := newTestConn(t, s)
+
+	assert.Equal(t, ":3\r\n", c.do("APPEND", "k", "abc"))
+	assert.Equal(t, ":3\r\n", c.do("STRLEN", "k"))
+	assert.Equal(t, "$2\r\nbc\r\n", c.do("GETRANGE", "k", "1", "-1"))
+	assert.Equal(t, ":5\r\n", c.do("SETRANGE", "k", "3", "de"))
+
+	c.do("SET", "key1", "ohmytext")
+	c.do("SET", "key2", "mynewtext")
+	assert.Equal(t, "$6\r\nmytext\r\n", c.do("LCS", "key1", "key2"))
+	assert.Equal(t, ":6\r\n", c.do("LCS", "key1", "key2", "LEN"))
+	assert.Equal(t,
+		"*4\r\n$7\r\nmatches\r\n*1\r\n*3\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n:4\r\n$3\r\nlen\r\n:6\r\n",
+		c.do("LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"))
+	assert.Equal(t, "-ERR If you want both the length and indexes, please just use IDX.\r\n", c.do("LCS", "key1", "key2", "LEN", "IDX"))
+	assert.Equal(t, "-ERR syntax error\r\n", c.do("LCS", "key1", "key2", "MINMATCHLEN"))
+}
+
+func TestMSetMGet(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	s.MSet([]m.Key{"a", "b"}, []string{"1", "2"})
+	exp := time.Now().Add(-time.Second)
+	s.Set(m.Key("expired"), "x", &exp, false, false, false, false)
+
+	result := s.MGet([]m.Key{"a", "missing", "b", "expired"})
+	assert.Equal(t, "1", *result[0])
+	assert.Nil(t, result[1])
+	assert.Equal(t, "2", *result[2])
+	assert.Nil(t, result[3])
+
+	// all or nothing
+	assert.False(t, s.MSetNX([]m.Key{"c", "a"}, []string{"3", "4"}))
+	assert.Nil(t, getString(t, s, m.Key("c")))
+	assert.Equal(t, "1", *getString(t, s, m.Key("a")))
+	assert.True(t, s.MSetNX([]m.Key{"c", "expired"}, []string{"3", "4"}))
+	assert.Equal(t, "4", *getString(t, s, m.Key("expired")))
+}
+
+func TestMultiKeyCommands(t *testing.T) {
+	s := m.NewServer("localhost", "6379", time.Second)
+	c := newTestConn(t, s)
+
+	assert.Equal(t, "+OK\r\n", c.do("MSET", "a", "1", "b", "2"))
+	assert.Equal(t, "*3\r\n$1\r\n1\r\n$-1\r\n$1\r\n2\r\n", c.do("MGET", "a", "x", "b"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'mset' command\r\n", c.do("MSET", "a", "1", "b"))
+	assert.Equal(t, ":0\r\n", c.do("MSETNX", "a", "1", "c", "3"))
+	assert.Equal(t, ":1\r\n", c.do("MSETNX", "c", "3", "d", "4"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'msetnx' command\r\n", c.do("msetnx", "e", "1", "f"))
+	assert.Equal(t, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", c.do("COMMAND", "GETKEYS", "MSET", "a", "1", "b", "2"))
+}
+
+func TestSetConformance(t *testing.T) {
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	future_ms := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+
+	runSteps(t, []stepCase{
+		{"plain set replaces ttl", []step{
+			{args: []string{"SET", "k", "v", "EX", "100"}, reply: "+OK\r\n"},
+			{args: []string{"SET", "k", "v2"}, reply: "+OK\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":-1\r\n"},
+		}},
+		{"keepttl retains ttl", []step{
+			{args: []string{"SET", "k", "v", "EX", "100"}, reply: "+OK\r\n"},
+			{args: []string{"SET", "k", "v2", "KEEPTTL"}, reply: "+OK\r\n"},
+			{args: []string{"GET", "k"}, reply: "$2\r\nv2\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":100\r\n"},
+		}},
+		{"keepttl on missing key", []step{
+			{args: []string{"SET", "k", "v", "keepttl"}, reply: "+OK\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":-1\r\n"},
+		}},
+		{"exat", []step{
+			{args: []string{"SET", "k", "v", "EXAT", future}, reply: "+OK\r\n"},
+			{args: []string{"TTL", "k"}, pattern: `^:(3599|3600)\r\n$`},
+		}},
+		{"pxat is in milliseconds", []step{
+			{args: []string{"SET", "k", "v", "PXAT", future_ms}, reply: "+OK\r\n"},
+			{args: []string{"TTL", "k"}, pattern: `^:(3599|3600)\r\n$`},
+		}},
+		{"exat in the past expires the key", []step{
+			{args: []string{"SET", "k", "v", "EXAT", "1"}, reply: "+OK\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+		}},
+		{"px", []step{
+			{args: []string{"SET", "k", "v", "PX", "100000"}, reply: "+OK\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":100\r\n"},
+		}},
+		{"nx", []step{
+			{args: []string{"SET", "k", "v", "NX"}, reply: "+OK\r\n"},
+			{args: []string{"SET", "k", "v2", "NX"}, reply: "$-1\r\n"},
+			{args: []string{"GET", "k"}, reply: "$1\r\nv\r\n"},
+		}},
+		{"xx", []step{
+			{args: []string{"SET", "k", "v", "XX"}, reply: "$-1\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"SET", "k", "v2", "XX"}, reply: "+OK\r\n"},
+			{args: []string{"GET", "k"}, reply: "$2\r\nv2\r\n"},
+		}},
+		{"get returns old value", []step{
+			{args: []string{"SET", "k", "v", "GET"}, reply: "$-1\r\n"},
+			{args: []string{"SET", "k", "v2", "GET"}, reply: "$1\r\nv\r\n"},
+			{args: []string{"GET", "k"}, reply: "$2\r\nv2\r\n"},
+		}},
+		{"nx get returns old value without setting", []step{
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"SET", "k", "v2", "NX", "GET"}, reply: "$1\r\nv\r\n"},
+			{args: []string{"GET", "k"}, reply: "$1\r\nv\r\n"},
+		}},
+		{"xx get on missing key", []step{
+			{args: []string{"SET", "k", "v", "XX", "GET"}, reply: "$-1\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+		}},
+		{"expired key is treated as missing", []step{
+			{args: []string{"SET", "k", "v", "PXAT", "1"}, reply: "+OK\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+			{args: []string{"SET", "k", "v2", "NX"}, reply: "+OK\r\n"},
+			{args: []string{"GET", "k"}, reply: "$2\r\nv2\r\n"},
+		}},
+		{"invalid options", []step{
+			{args: []string{"SET", "k", "v", "NX", "XX"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"SET", "k", "v", "EX", "10", "PX", "100"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"SET", "k", "v", "EX", "10", "KEEPTTL"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"SET", "k", "v", "EX"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"SET", "k", "v", "EX", "0"}, reply: "-ERR invalid expire time in 'set' command\r\n"},
+			{args: []string{"SET", "k", "v", "PX", "-5"}, reply: "-ERR invalid expire time in 'set' command\r\n"},
+			{args: []string{"SET", "k", "v", "EX", "1.5"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"SET", "k", "v", "EX", "9223372036854775807"}, reply: "-ERR invalid expire time in 'set' command\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+		}},
+		{"getdel", []step{
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"GETDEL", "k"}, reply: "$1\r\nv\r\n"},
+			{args: []string{"GETDEL", "k"}, reply: "$-1\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+		}},
+		{"getex", []step{
+			{args: []string{"GETEX", "k", "EX", "10"}, reply: "$-1\r\n"},
+			{args: []string{"SET", "k", "v"}, reply: "+OK\r\n"},
+			{args: []string{"GETEX", "k"}, reply: "$1\r\nv\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":-1\r\n"},
+			{args: []string{"GETEX", "k", "EX", "100"}, reply: "$1\r\nv\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":100\r\n"},
+			{args: []string{"GETEX", "k", "PXAT", future_ms}, reply: "$1\r\nv\r\n"},
+			{args: []string{"TTL", "k"}, pattern: `^:(3599|3600)\r\n$`},
+			{args: []string{"GETEX", "k", "persist"}, reply: "$1\r\nv\r\n"},
+			{args: []string{"TTL", "k"}, reply: ":-1\r\n"},
+			{args: []string{"GETEX", "k", "EXAT", "1"}, reply: "$1\r\nv\r\n"},
+			{args: []string{"GET", "k"}, reply: "$-1\r\n"},
+		}},
+		{"getex invalid options", []step{
+			{args: []string{"GETEX", "k", "EX"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"GETEX", "k", "PERSIST", "EX", "10"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"GETEX", "k", "PX", "0"}, reply: "-ERR invalid expire time in 'getex' command\r\n"},
+		}},
+	})
+}
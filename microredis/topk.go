@@ -0,0 +1,410 @@
+package microredis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// topKDefaultWidth, topKDefaultDepth and topKDefaultDecay are the
+	// parameters of TOPK.RESERVE when only k is given, the same as
+	// RedisBloom
+	topKDefaultWidth = 8
+	topKDefaultDepth = 7
+	topKDefaultDecay = 0.9
+	// topKDecayTable is the number of powers of the decay precomputed, the
+	// last one is used for the higher counts
+	topKDecayTable = 256
+	// topKMinDecay is the probability of decay under which a bucket is
+	// considered to never decay, even the highest increment then has a
+	// negligible chance to decay it
+	topKMinDecay = 1e-9
+	// topKMaxIncrement is the highest increment of TOPK.INCRBY
+	topKMaxIncrement = 100000
+	// topKMaxK, topKMaxDepth and topKMaxBuckets bound the parameters of
+	// TOPK.RESERVE so that a TopK can't take unbounded memory nor time to
+	// count an item, the buckets of the sketch take 16 bytes each and are
+	// bounded to maxStringLen bytes
+	topKMaxK       = 100000
+	topKMaxDepth   = 32
+	topKMaxBuckets = maxStringLen / 16
+)
+
+// errTopKNotFound is returned by the Top-K commands when the key does not
+// exist
+var errTopKNotFound = errors.New("ERR TopK: key does not exist")
+
+// topKBucket struct denotes a counter of the sketch of a TopK, it counts
+// the item whose hash is fp
+type topKBucket struct {
+	fp    uint64
+	count int64
+}
+
+// TopKItem struct denotes one of the items of a TopK along with its
+// estimated count
+type TopKItem struct {
+	Item  string
+	Count int64
+}
+
+// TopK struct denotes the Top-K datatype which keeps track of the k items
+// counted the most, using the HeavyKeeper algorithm like RedisBloom. Each
+// item is counted in one bucket of each of the depth rows of width buckets
+// and a bucket counting another item only decays with a probability of
+// decay to the power of its count, so that frequent items keep their
+// buckets. The top k items are kept in a min heap by count
+type TopK struct {
+	k       int64
+	width   int64
+	depth   int64
+	decay   float64
+	buckets []topKBucket
+	heap    []TopKItem
+	powers  [topKDecayTable]float64
+}
+
+// NewTopK function creates an empty TopK keeping track of k items
+func NewTopK(k int64, width int64, depth int64, decay float64) *TopK {
+	result := &TopK{
+		k:       k,
+		width:   width,
+		depth:   depth,
+		decay:   decay,
+		buckets: make([]topKBucket, width*depth),
+		heap:    make([]TopKItem, 0, k),
+	}
+	for i := range result.powers {
+		result.powers[i] = math.Pow(decay, float64(i))
+	}
+	return result
+}
+
+// find function returns the position of item in the heap, -1 if it is not
+// one of the top items
+func (tk *TopK) find(item string) int {
+	for i, cur := range tk.heap {
+		if cur.Item == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// down function moves the heap entry at i down until the heap is ordered
+// again, after its count increased
+func (tk *TopK) down(i int) {
+	for {
+		smallest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(tk.heap) && tk.heap[child].Count < tk.heap[smallest].Count {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return
+		}
+		tk.heap[i], tk.heap[smallest] = tk.heap[smallest], tk.heap[i]
+		i = smallest
+	}
+}
+
+// up function moves the heap entry at i up until the heap is ordered again
+func (tk *TopK) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if tk.heap[parent].Count <= tk.heap[i].Count {
+			return
+		}
+		tk.heap[i], tk.heap[parent] = tk.heap[parent], tk.heap[i]
+		i = parent
+	}
+}
+
+// IncrBy function counts item incr more times. If item enters the top
+// items in place of another one, the item expelled is returned
+func (tk *TopK) IncrBy(item string, incr int64) *string {
+	fp := murmurHash64A([]byte(item), 0x9747b28c)
+	max_count := int64(0)
+	for row := int64(0); row < tk.depth; row++ {
+		b := &tk.buckets[row*tk.width+int64(murmurHash64A([]byte(item), uint64(row))%uint64(tk.width))]
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, incr
+		case b.fp == fp:
+			b.count += incr
+		case tk.decay == 1:
+			// every count decays the bucket
+			if b.count > incr {
+				b.count -= incr
+			} else {
+				b.fp, b.count = fp, incr-b.count+1
+			}
+		default:
+			// rather than a draw for each count, the number of counts until
+			// the bucket decays is drawn from the geometric distribution
+			for left := incr; left > 0; {
+				power := tk.powers[topKDecayTable-1]
+				if b.count < topKDecayTable {
+					power = tk.powers[b.count]
+				}
+				if power < topKMinDecay {
+					break
+				}
+				draws := math.Ceil(math.Log(1-rand.Float64()) / math.Log1p(-power))
+				if draws > float64(left) {
+					break
+				}
+				if draws > 1 {
+					left -= int64(draws) - 1
+				}
+				b.count--
+				if b.count == 0 {
+					b.fp, b.count = fp, left
+					break
+				}
+				left--
+			}
+		}
+		if b.fp == fp && b.count > max_count {
+			max_count = b.count
+		}
+	}
+	if max_count == 0 {
+		return nil
+	}
+	if i := tk.find(item); i >= 0 {
+		if max_count > tk.heap[i].Count {
+			tk.heap[i].Count = max_count
+			tk.down(i)
+		}
+		return nil
+	}
+	if int64(len(tk.heap)) < tk.k {
+		tk.heap = append(tk.heap, TopKItem{item, max_count})
+		tk.up(len(tk.heap) - 1)
+		return nil
+	}
+	if max_count < tk.heap[0].Count {
+		return nil
+	}
+	expelled := tk.heap[0].Item
+	tk.heap[0] = TopKItem{item, max_count}
+	tk.down(0)
+	return &expelled
+}
+
+// Query function returns whether item is one of the top items
+func (tk *TopK) Query(item string) bool {
+	return tk.find(item) >= 0
+}
+
+// List function returns the top items from the one counted the most
+func (tk *TopK) List() []TopKItem {
+	result := append([]TopKItem{}, tk.heap...)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Item < result[j].Item
+	})
+	return result
+}
+
+// getTopK function returns the TopK stored at key, nil if the key does not
+// exist. ErrWrongType is returned if the key holds another datatype
+func (s *Storage) getTopK(key Key) (*TopK, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		return nil, nil
+	}
+	result, ok := val.val.(*TopK)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// getExistingTopK function returns the TopK stored at key, failing if the
+// key does not exist
+func (s *Storage) getExistingTopK(key Key) (*TopK, error) {
+	tk, err := s.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if tk == nil {
+		return nil, errTopKNotFound
+	}
+	return tk, nil
+}
+
+// TopKReserve function stores tk at key, failing if the key already exists
+func (s *Storage) TopKReserve(key Key, tk *TopK) error {
+	if _, prs := s.lookup(key); prs {
+		return errors.New("ERR TopK: key already exists")
+	}
+	s.data[key] = Value{val: tk}
+	s.keyModified(key)
+	return nil
+}
+
+// TopKIncrBy function counts each of the items of the TopK stored at key
+// the matching number of times, and returns for each of them the item it
+// expelled from the top items if any
+func (s *Storage) TopKIncrBy(key Key, items []string, incrs []int64) ([]*string, error) {
+	tk, err := s.getExistingTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*string, len(items))
+	for i, item := range items {
+		result[i] = tk.IncrBy(item, incrs[i])
+	}
+	s.keyModified(key)
+	return result, nil
+}
+
+// TopKQuery function returns whether each of the items is one of the top
+// items of the TopK stored at key
+func (s *Storage) TopKQuery(key Key, items []string) ([]bool, error) {
+	tk, err := s.getExistingTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]bool, len(items))
+	for i, item := range items {
+		result[i] = tk.Query(item)
+	}
+	return result, nil
+}
+
+// TopKList function returns the top items of the TopK stored at key from
+// the one counted the most
+func (s *Storage) TopKList(key Key) ([]TopKItem, error) {
+	tk, err := s.getExistingTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	return tk.List(), nil
+}
+
+// expelledReply function converts the items expelled by TOPK.ADD and
+// TOPK.INCRBY into a reply, nil for the items which expelled none
+func expelledReply(expelled []*string) []interface{} {
+	result := make([]interface{}, len(expelled))
+	for i, item := range expelled {
+		if item != nil {
+			result[i] = *item
+		}
+	}
+	return result
+}
+
+// ProcessRespCommandTopKReserve function processes command TOPK.RESERVE
+func (s *Server) ProcessRespCommandTopKReserve(c *Connection, commands []string) (interface{}, error) {
+	if len(commands) != 3 && len(commands) != 6 {
+		return nil, errors.New(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(commands[0])))
+	}
+	k, err := strconv.ParseInt(commands[2], 10, 64)
+	if err != nil || k < 1 {
+		return nil, errors.New("ERR TopK: invalid k")
+	}
+	if k > topKMaxK {
+		return nil, errors.New("ERR TopK: k is too large")
+	}
+	width, depth, decay := int64(topKDefaultWidth), int64(topKDefaultDepth), topKDefaultDecay
+	if len(commands) == 6 {
+		if width, err = strconv.ParseInt(commands[3], 10, 64); err != nil || width < 1 {
+			return nil, errors.New("ERR TopK: invalid width")
+		}
+		if depth, err = strconv.ParseInt(commands[4], 10, 64); err != nil || depth < 1 {
+			return nil, errors.New("ERR TopK: invalid depth")
+		}
+		if depth > topKMaxDepth {
+			return nil, errors.New("ERR TopK: depth is too large")
+		}
+		if width > topKMaxBuckets/depth {
+			return nil, errors.New("ERR TopK: dimensions are too large")
+		}
+		if decay, err = strconv.ParseFloat(commands[5], 64); err != nil || decay <= 0 || decay > 1 {
+			return nil, errors.New("ERR TopK: invalid decay value. must be '<= 1' & '> 0'")
+		}
+	}
+	if err := s.db.TopKReserve(Key(commands[1]), NewTopK(k, width, depth, decay)); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
+
+// ProcessRespCommandTopKAdd function processes command TOPK.ADD
+func (s *Server) ProcessRespCommandTopKAdd(c *Connection, commands []string) (interface{}, error) {
+	incrs := make([]int64, len(commands)-2)
+	for i := range incrs {
+		incrs[i] = 1
+	}
+	result, err := s.db.TopKIncrBy(Key(commands[1]), commands[2:], incrs)
+	if err != nil {
+		return nil, err
+	}
+	return expelledReply(result), nil
+}
+
+// ProcessRespCommandTopKIncrBy function processes command TOPK.INCRBY
+func (s *Server) ProcessRespCommandTopKIncrBy(c *Connection, commands []string) (interface{}, error) {
+	pairs := commands[2:]
+	if len(pairs)%2 != 0 {
+		return nil, errors.New(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(commands[0])))
+	}
+	items := make([]string, len(pairs)/2)
+	incrs := make([]int64, len(items))
+	for i := range items {
+		incr, err := strconv.ParseInt(pairs[2*i+1], 10, 64)
+		if err != nil || incr < 1 || incr > topKMaxIncrement {
+			return nil, errors.New("ERR TopK: increment must be an integer greater or equal to 1 and less than or equal to 100,000")
+		}
+		items[i], incrs[i] = pairs[2*i], incr
+	}
+	result, err := s.db.TopKIncrBy(Key(commands[1]), items, incrs)
+	if err != nil {
+		return nil, err
+	}
+	return expelledReply(result), nil
+}
+
+// ProcessRespCommandTopKQuery function processes command TOPK.QUERY
+func (s *Server) ProcessRespCommandTopKQuery(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.TopKQuery(Key(commands[1]), commands[2:])
+	if err != nil {
+		return nil, err
+	}
+	return boolsReply(result), nil
+}
+
+// ProcessRespCommandTopKList function processes command TOPK.LIST, with
+// WITHCOUNT each item is followed by its estimated count
+func (s *Server) ProcessRespCommandTopKList(c *Connection, commands []string) (interface{}, error) {
+	withcount := false
+	if len(commands) > 2 {
+		if len(commands) > 3 || strings.ToUpper(commands[2]) != "WITHCOUNT" {
+			return nil, ErrSyntax
+		}
+		withcount = true
+	}
+	items, err := s.db.TopKList(Key(commands[1]))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, 2*len(items))
+	for _, item := range items {
+		result = append(result, item.Item)
+		if withcount {
+			result = append(result, item.Count)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package microredis_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestTopK(t *testing.T) {
+	tk := m.NewTopK(3, 50, 5, 0.9)
+	// item i is counted i times for the first items and once for the others
+	for i := 1; i <= 10; i++ {
+		for j := 0; j < 10*i; j++ {
+			tk.IncrBy("hot:"+strconv.Itoa(i), 1)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		tk.IncrBy("cold:"+strconv.Itoa(i), 1)
+	}
+	list := tk.List()
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, []string{"hot:10", "hot:9", "hot:8"}, []string{list[0].Item, list[1].Item, list[2].Item})
+	assert.Equal(t, int64(100), list[0].Count)
+	assert.True(t, tk.Query("hot:9"))
+	assert.False(t, tk.Query("hot:1"))
+
+	// an item entering the top items expels the least counted one
+	tk = m.NewTopK(2, 100, 4, 0.9)
+	assert.Nil(t, tk.IncrBy("a", 3))
+	assert.Nil(t, tk.IncrBy("b", 2))
+	assert.Nil(t, tk.IncrBy("c", 1))
+	assert.Equal(t, "b", *tk.IncrBy("c", 2))
+	assert.Equal(t, []m.TopKItem{{Item: "a", Count: 3}, {Item: "c", Count: 3}}, tk.List())
+
+	// without decay every count of another item decrements the bucket
+	tk = m.NewTopK(2, 1, 1, 1)
+	assert.Nil(t, tk.IncrBy("a", 5))
+	assert.Nil(t, tk.IncrBy("b", 3))
+	assert.False(t, tk.Query("b"))
+	assert.Nil(t, tk.IncrBy("b", 3))
+	assert.Equal(t, []m.TopKItem{{Item: "a", Count: 5}, {Item: "b", Count: 2}}, tk.List())
+
+	// a heavy bucket practically never decays and is skipped at once
+	tk = m.NewTopK(1, 1, 1, 0.9)
+	for i := 0; i < 10; i++ {
+		tk.IncrBy("a", 100000)
+	}
+	for i := 0; i < 1000; i++ {
+		tk.IncrBy("b", 100000)
+	}
+	assert.Equal(t, []m.TopKItem{{Item: "a", Count: 1000000}}, tk.List())
+}
+
+func TestTopKStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	_, err := s.TopKIncrBy(m.Key("tk"), []string{"a"}, []int64{1})
+	assert.Equal(t, "ERR TopK: key does not exist", err.Error())
+	assert.Nil(t, s.TopKReserve(m.Key("tk"), m.NewTopK(2, 100, 4, 0.9)))
+	assert.Equal(t, "ERR TopK: key already exists", s.TopKReserve(m.Key("tk"), m.NewTopK(2, 100, 4, 0.9)).Error())
+	assert.Equal(t, "TopK-TYPE", s.Type(m.Key("tk")))
+
+	expelled, err := s.TopKIncrBy(m.Key("tk"), []string{"a", "b", "c"}, []int64{5, 3, 4})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(expelled))
+	assert.Nil(t, expelled[0])
+	assert.Nil(t, expelled[1])
+	assert.Equal(t, "b", *expelled[2])
+	found, err := s.TopKQuery(m.Key("tk"), []string{"a", "b", "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false, true}, found)
+	list, err := s.TopKList(m.Key("tk"))
+	assert.Nil(t, err)
+	assert.Equal(t, []m.TopKItem{{Item: "a", Count: 5}, {Item: "c", Count: 4}}, list)
+
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, err = s.TopKList(m.Key("str"))
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestTopKCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"top items", []step{
+			{args: []string{"TOPK.RESERVE", "tk", "2", "100", "4", "0.9"}, reply: "+OK\r\n"},
+			{args: []string{"TOPK.ADD", "tk", "a", "b", "a"}, reply: "*3\r\n$-1\r\n$-1\r\n$-1\r\n"},
+			{args: []string{"TOPK.INCRBY", "tk", "c", "5"}, reply: "*1\r\n$1\r\nb\r\n"},
+			{args: []string{"TOPK.QUERY", "tk", "a", "b", "c"}, reply: "*3\r\n:1\r\n:0\r\n:1\r\n"},
+			{args: []string{"TOPK.LIST", "tk"}, reply: "*2\r\n$1\r\nc\r\n$1\r\na\r\n"},
+			{args: []string{"TOPK.LIST", "tk", "WITHCOUNT"}, reply: "*4\r\n$1\r\nc\r\n:5\r\n$1\r\na\r\n:2\r\n"},
+			{args: []string{"TYPE", "tk"}, reply: "+TopK-TYPE\r\n"},
+			{args: []string{"TOPK.RESERVE", "default", "5"}, reply: "+OK\r\n"},
+			{args: []string{"TOPK.ADD", "default", "x"}, reply: "*1\r\n$-1\r\n"},
+			{args: []string{"TOPK.LIST", "default"}, reply: "*1\r\n$1\r\nx\r\n"},
+		}},
+		{"errors", []step{
+			{args: []string{"TOPK.RESERVE", "tk", "0"}, reply: "-ERR TopK: invalid k\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "9223372036854775807"}, reply: "-ERR TopK: k is too large\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "2", "2000000000", "32", "0.9"}, reply: "-ERR TopK: dimensions are too large\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "2", "10", "33", "0.9"}, reply: "-ERR TopK: depth is too large\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "2", "0", "4", "0.9"}, reply: "-ERR TopK: invalid width\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "2", "10", "x", "0.9"}, reply: "-ERR TopK: invalid depth\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "2", "10", "4", "1.5"}, reply: "-ERR TopK: invalid decay value. must be '<= 1' & '> 0'\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "2", "10"}, reply: "-ERR wrong number of arguments for 'topk.reserve' command\r\n"},
+			{args: []string{"TOPK.ADD", "tk", "a"}, reply: "-ERR TopK: key does not exist\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "2"}, reply: "+OK\r\n"},
+			{args: []string{"TOPK.RESERVE", "tk", "2"}, reply: "-ERR TopK: key already exists\r\n"},
+			{args: []string{"TOPK.INCRBY", "tk", "a", "0"}, reply: "-ERR TopK: increment must be an integer greater or equal to 1 and less than or equal to 100,000\r\n"},
+			{args: []string{"TOPK.INCRBY", "tk", "a", "100001"}, reply: "-ERR TopK: increment must be an integer greater or equal to 1 and less than or equal to 100,000\r\n"},
+			{args: []string{"TOPK.LIST", "tk", "COUNT"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"GET", "tk"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}
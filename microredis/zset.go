@@ -0,0 +1,947 @@
+package microredis
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ZMember struct denotes a member of a sorted set along with its score
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZSet struct denotes the sorted set datatype. Like the skiplist encoding
+// of redis the score of each member is kept in a map for O(1) lookups and
+// the members are ordered by score in a SkipList for ranks and ranges
+type ZSet struct {
+	scores map[string]float64
+	zsl    *SkipList
+}
+
+// NewZSet function creates and initializes an empty ZSet
+func NewZSet() *ZSet {
+	return &ZSet{scores: make(map[string]float64), zsl: NewSkipList()}
+}
+
+// Len function returns the number of members of the sorted set
+func (z *ZSet) Len() int {
+	return len(z.scores)
+}
+
+// Score function returns the score of member and whether it is a member
+func (z *ZSet) Score(member string) (float64, bool) {
+	score, prs := z.scores[member]
+	return score, prs
+}
+
+// Set function sets the score of member, adding it to the sorted set if
+// it is not a member yet. Returns whether member was added
+func (z *ZSet) Set(member string, score float64) bool {
+	cur, prs := z.scores[member]
+	if prs {
+		if cur != score {
+			z.zsl.Delete(cur, member)
+			z.zsl.Insert(score, member)
+			z.scores[member] = score
+		}
+		return false
+	}
+	z.zsl.Insert(score, member)
+	z.scores[member] = score
+	return true
+}
+
+// Remove function removes member from the sorted set, it returns false if
+// it was not a member
+func (z *ZSet) Remove(member string) bool {
+	score, prs := z.scores[member]
+	if !prs {
+		return false
+	}
+	z.zsl.Delete(score, member)
+	delete(z.scores, member)
+	return true
+}
+
+// Rank function returns the 0 based rank of member, ordered from the
+// lowest score or from the highest one if reverse is set, and whether it
+// is a member
+func (z *ZSet) Rank(member string, reverse bool) (int, bool) {
+	score, prs := z.scores[member]
+	if !prs {
+		return 0, false
+	}
+	rank := z.zsl.Rank(score, member)
+	if reverse {
+		rank = z.Len() - 1 - rank
+	}
+	return rank, true
+}
+
+// Range function returns the members between the ranks start and stop,
+// both inclusive, ordered from the highest score if reverse is set.
+// Negative ranks count from the end and out of range ranks are clamped
+// the same way LRANGE does
+func (z *ZSet) Range(start int, stop int, reverse bool) []ZMember {
+	length := z.Len()
+	if start < 0 {
+		start = length + start
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return []ZMember{}
+	}
+	result := make([]ZMember, 0, stop-start+1)
+	if reverse {
+		x := z.zsl.byRank(length - 1 - start)
+		for ; len(result) < stop-start+1; x = x.backward {
+			result = append(result, ZMember{x.member, x.score})
+		}
+	} else {
+		x := z.zsl.byRank(start)
+		for ; len(result) < stop-start+1; x = x.level[0].forward {
+			result = append(result, ZMember{x.member, x.score})
+		}
+	}
+	return result
+}
+
+// rangeByBounds function returns the members within the bounds, ordered
+// from the highest score if reverse is set. The first offset members are
+// skipped and at most count members are returned, all the remaining ones
+// if count is negative
+func (z *ZSet) rangeByBounds(r zrangeBounds, reverse bool, offset int, count int) []ZMember {
+	result := []ZMember{}
+	var x *skipListNode
+	if reverse {
+		x = z.zsl.lastInRange(r)
+	} else {
+		x = z.zsl.firstInRange(r)
+	}
+	for x != nil && count != 0 {
+		if (reverse && !r.aboveMin(x)) || (!reverse && !r.belowMax(x)) {
+			break
+		}
+		if offset > 0 {
+			offset--
+		} else {
+			result = append(result, ZMember{x.member, x.score})
+			count--
+		}
+		if reverse {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+	return result
+}
+
+// count function returns the number of members within the bounds
+func (z *ZSet) count(r zrangeBounds) int {
+	first := z.zsl.firstInRange(r)
+	if first == nil {
+		return 0
+	}
+	last := z.zsl.lastInRange(r)
+	return z.zsl.Rank(last.score, last.member) - z.zsl.Rank(first.score, first.member) + 1
+}
+
+// getZSet function returns the sorted set stored at key. If the key does
+// not exist nil is returned, unless create is set in which case a new
+// empty sorted set is stored at key. ErrWrongType is returned if the key
+// holds another datatype
+func (s *Storage) getZSet(key Key, create bool) (*ZSet, error) {
+	val, prs := s.lookup(key)
+	if !prs {
+		if !create {
+			return nil, nil
+		}
+		result := NewZSet()
+		s.data[key] = Value{val: result}
+		return result, nil
+	}
+	result, ok := val.val.(*ZSet)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return result, nil
+}
+
+// deleteIfEmptyZSet function removes key if it holds an empty sorted set,
+// as redis never keeps empty aggregate values around
+func (s *Storage) deleteIfEmptyZSet(key Key, z *ZSet) {
+	if z.Len() == 0 {
+		delete(s.data, key)
+	}
+}
+
+// ZAddFlags struct holds the options of ZADD. NX only adds new members and
+// XX only updates existing ones, GT and LT only update existing members if
+// the new score is greater or less than the current one. CH counts the
+// members whose score changed along with the added ones
+type ZAddFlags struct {
+	NX bool
+	XX bool
+	GT bool
+	LT bool
+	CH bool
+}
+
+// allowed function returns whether the flags allow to set the score of a
+// member to score, where cur is its current score if it is a member
+func (flags ZAddFlags) allowed(cur float64, prs bool, score float64) bool {
+	if !prs {
+		return !flags.XX
+	}
+	return !flags.NX && !(flags.GT && score <= cur) && !(flags.LT && score >= cur)
+}
+
+// ZAdd function sets the scores of the members of the sorted set stored at
+// key following flags, creating the sorted set if it does not exist.
+// Returns the number of members added, and of those changed if CH is set
+func (s *Storage) ZAdd(key Key, members []ZMember, flags ZAddFlags) (int, error) {
+	z, err := s.getZSet(key, !flags.XX)
+	if z == nil {
+		return 0, err
+	}
+	added, changed := 0, 0
+	for _, m := range members {
+		cur, prs := z.Score(m.Member)
+		if !flags.allowed(cur, prs, m.Score) || (prs && cur == m.Score) {
+			continue
+		}
+		if z.Set(m.Member, m.Score) {
+			added++
+		} else {
+			changed++
+		}
+	}
+	if added+changed > 0 {
+		s.keyModified(key)
+	}
+	if flags.CH {
+		return added + changed, nil
+	}
+	return added, nil
+}
+
+// ZIncrBy function increments the score of member in the sorted set stored
+// at key by delta following flags, a member which does not exist is added
+// with delta as score. Returns the new score, or nil if the flags did not
+// allow the update
+func (s *Storage) ZIncrBy(key Key, member string, delta float64, flags ZAddFlags) (*float64, error) {
+	z, err := s.getZSet(key, !flags.XX)
+	if z == nil {
+		return nil, err
+	}
+	cur, prs := z.Score(member)
+	score := cur + delta
+	if math.IsNaN(score) {
+		return nil, errors.New("ERR resulting score is not a number (NaN)")
+	}
+	if !flags.allowed(cur, prs, score) {
+		return nil, nil
+	}
+	z.Set(member, score)
+	s.keyModified(key)
+	return &score, nil
+}
+
+// ZRem function removes the members from the sorted set stored at key and
+// returns the number of members removed. The key is deleted once the
+// sorted set is empty
+func (s *Storage) ZRem(key Key, members []string) (int, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return 0, err
+	}
+	removed := 0
+	for _, member := range members {
+		if z.Remove(member) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.deleteIfEmptyZSet(key, z)
+		s.keyModified(key)
+	}
+	return removed, nil
+}
+
+// ZCard function returns the number of members of the sorted set stored at
+// key
+func (s *Storage) ZCard(key Key) (int, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return 0, err
+	}
+	return z.Len(), nil
+}
+
+// ZScore function returns the score of member in the sorted set stored at
+// key, nil if the key or the member does not exist
+func (s *Storage) ZScore(key Key, member string) (*float64, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return nil, err
+	}
+	if score, prs := z.Score(member); prs {
+		return &score, nil
+	}
+	return nil, nil
+}
+
+// ZRank function returns the rank of member in the sorted set stored at
+// key along with its score, ordered from the highest score if reverse is
+// set. The rank is -1 if the key or the member does not exist
+func (s *Storage) ZRank(key Key, member string, reverse bool) (int, float64, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return -1, 0, err
+	}
+	rank, prs := z.Rank(member, reverse)
+	if !prs {
+		return -1, 0, nil
+	}
+	score, _ := z.Score(member)
+	return rank, score, nil
+}
+
+// ZCount function returns the number of members of the sorted set stored
+// at key whose score is within r
+func (s *Storage) ZCount(key Key, r ScoreRange) (int, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return 0, err
+	}
+	return z.count(r), nil
+}
+
+// ZRange function returns the members of the sorted set stored at key
+// between the ranks start and stop, see ZSet.Range
+func (s *Storage) ZRange(key Key, start int, stop int, reverse bool) ([]ZMember, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return []ZMember{}, err
+	}
+	return z.Range(start, stop, reverse), nil
+}
+
+// ZRangeByScore function returns the members of the sorted set stored at
+// key whose score is within r. The first offset members are skipped and at
+// most count members are returned, all of them if count is negative
+func (s *Storage) ZRangeByScore(key Key, r ScoreRange, reverse bool, offset int, count int) ([]ZMember, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return []ZMember{}, err
+	}
+	return z.rangeByBounds(r, reverse, offset, count), nil
+}
+
+// ZRangeByLex function is same as ZRangeByScore but returns the members
+// within the lexicographical range r
+func (s *Storage) ZRangeByLex(key Key, r LexRange, reverse bool, offset int, count int) ([]ZMember, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil {
+		return []ZMember{}, err
+	}
+	return z.rangeByBounds(r, reverse, offset, count), nil
+}
+
+// ZPop function removes and returns up to count members with the lowest
+// scores from the sorted set stored at key, or with the highest ones if max
+// is set
+func (s *Storage) ZPop(key Key, count int, max bool) ([]ZMember, error) {
+	z, err := s.getZSet(key, false)
+	if z == nil || count == 0 {
+		return []ZMember{}, err
+	}
+	result := z.Range(0, count-1, max)
+	for _, m := range result {
+		z.Remove(m.Member)
+	}
+	if len(result) > 0 {
+		s.deleteIfEmptyZSet(key, z)
+		s.keyModified(key)
+	}
+	return result, nil
+}
+
+// ZStore function stores a sorted set of the members at key replacing
+// whatever the key holds, or deletes the key if there are no members.
+// Returns the number of members stored
+func (s *Storage) ZStore(key Key, members []ZMember) int {
+	if len(members) == 0 {
+		if _, prs := s.lookup(key); prs {
+			delete(s.data, key)
+			s.keyModified(key)
+		}
+		return 0
+	}
+	z := NewZSet()
+	for _, m := range members {
+		z.Set(m.Member, m.Score)
+	}
+	s.data[key] = Value{val: z}
+	s.keyModified(key)
+	return z.Len()
+}
+
+// getScores function returns the scores of the members of the sorted sets
+// stored at keys. Like redis sets are accepted as well with a score of 1
+// for every member and a key which does not exist is returned as nil.
+// ErrWrongType is returned if any of the keys holds another datatype
+func (s *Storage) getScores(keys []Key) ([]map[string]float64, error) {
+	result := make([]map[string]float64, len(keys))
+	for i, key := range keys {
+		val, prs := s.lookup(key)
+		if !prs {
+			continue
+		}
+		switch v := val.val.(type) {
+		case *ZSet:
+			result[i] = v.scores
+		case *Set:
+			result[i] = make(map[string]float64, v.Len())
+			for _, member := range v.Members() {
+				result[i][member] = 1
+			}
+		default:
+			return nil, ErrWrongType
+		}
+	}
+	return result, nil
+}
+
+// weightedScore function multiplies score by weight, where 0 times an
+// infinite score is 0 rather than NaN
+func weightedScore(score float64, weight float64) float64 {
+	result := score * weight
+	if math.IsNaN(result) {
+		return 0
+	}
+	return result
+}
+
+// aggregateScores function combines two scores of a member following
+// aggregate which is one of SUM, MIN or MAX. The sum of two opposite
+// infinities is 0
+func aggregateScores(aggregate string, a float64, b float64) float64 {
+	switch aggregate {
+	case "MIN":
+		return math.Min(a, b)
+	case "MAX":
+		return math.Max(a, b)
+	}
+	result := a + b
+	if math.IsNaN(result) {
+		return 0
+	}
+	return result
+}
+
+// ZUnion function returns the union of the sorted sets stored at keys,
+// where the scores of each sorted set are multiplied by its weight and the
+// scores of a member are combined following aggregate
+func (s *Storage) ZUnion(keys []Key, weights []float64, aggregate string) ([]ZMember, error) {
+	sources, err := s.getScores(keys)
+	if err != nil {
+		return nil, err
+	}
+	union := make(map[string]float64)
+	for i, scores := range sources {
+		for member, score := range scores {
+			score = weightedScore(score, weights[i])
+			if cur, prs := union[member]; prs {
+				score = aggregateScores(aggregate, cur, score)
+			}
+			union[member] = score
+		}
+	}
+	result := make([]ZMember, 0, len(union))
+	for member, score := range union {
+		result = append(result, ZMember{member, score})
+	}
+	return result, nil
+}
+
+// ZInter function is same as ZUnion but returns the intersection of the
+// sorted sets stored at keys
+func (s *Storage) ZInter(keys []Key, weights []float64, aggregate string) ([]ZMember, error) {
+	sources, err := s.getScores(keys)
+	if err != nil {
+		return nil, err
+	}
+	// iterate over the smallest sorted set and check the others
+	smallest := 0
+	for i, scores := range sources {
+		if len(scores) < len(sources[smallest]) {
+			smallest = i
+		}
+	}
+	result := []ZMember{}
+	for member := range sources[smallest] {
+		score := float64(0)
+		in_all := true
+		for i, scores := range sources {
+			cur, prs := scores[member]
+			if !prs {
+				in_all = false
+				break
+			}
+			if i == 0 {
+				score = weightedScore(cur, weights[i])
+			} else {
+				score = aggregateScores(aggregate, score, weightedScore(cur, weights[i]))
+			}
+		}
+		if in_all {
+			result = append(result, ZMember{member, score})
+		}
+	}
+	return result, nil
+}
+
+// parseScore function parses a score, unlike parseFloat infinities are
+// valid scores
+func parseScore(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, ErrNotFloat
+	}
+	return f, nil
+}
+
+// ProcessRespCommandZAdd function processes redis command ZADD. With INCR
+// the score of the single member is incremented and the new score is
+// replied, or nil if the options did not allow the update
+func (s *Server) ProcessRespCommandZAdd(c *Connection, commands []string) (interface{}, error) {
+	flags := ZAddFlags{}
+	incr := false
+	i := 2
+options:
+	for ; i < len(commands); i++ {
+		switch strings.ToUpper(commands[i]) {
+		case "NX":
+			flags.NX = true
+		case "XX":
+			flags.XX = true
+		case "GT":
+			flags.GT = true
+		case "LT":
+			flags.LT = true
+		case "CH":
+			flags.CH = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+	pairs := commands[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return nil, ErrSyntax
+	}
+	if flags.NX && flags.XX {
+		return nil, errors.New("ERR XX and NX options at the same time are not compatible")
+	}
+	if (flags.GT && flags.LT) || (flags.NX && (flags.GT || flags.LT)) {
+		return nil, errors.New("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if incr && len(pairs) > 2 {
+		return nil, errors.New("ERR INCR option supports a single increment-element pair")
+	}
+	members := make([]ZMember, len(pairs)/2)
+	for j := range members {
+		score, err := parseScore(pairs[2*j])
+		if err != nil {
+			return nil, err
+		}
+		members[j] = ZMember{pairs[2*j+1], score}
+	}
+	if incr {
+		result, err := s.db.ZIncrBy(Key(commands[1]), members[0].Member, members[0].Score, flags)
+		if result == nil {
+			return nil, err
+		}
+		return *result, nil
+	}
+	return s.db.ZAdd(Key(commands[1]), members, flags)
+}
+
+// ProcessRespCommandZIncrBy function processes redis command ZINCRBY
+func (s *Server) ProcessRespCommandZIncrBy(c *Connection, commands []string) (interface{}, error) {
+	delta, err := parseScore(commands[2])
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.ZIncrBy(Key(commands[1]), commands[3], delta, ZAddFlags{})
+	if err != nil {
+		return nil, err
+	}
+	return *result, nil
+}
+
+// ProcessRespCommandZRem function processes redis command ZREM
+func (s *Server) ProcessRespCommandZRem(c *Connection, commands []string) (interface{}, error) {
+	return s.db.ZRem(Key(commands[1]), commands[2:])
+}
+
+// ProcessRespCommandZCard function processes redis command ZCARD
+func (s *Server) ProcessRespCommandZCard(c *Connection, commands []string) (interface{}, error) {
+	return s.db.ZCard(Key(commands[1]))
+}
+
+// ProcessRespCommandZScore function processes redis command ZSCORE
+func (s *Server) ProcessRespCommandZScore(c *Connection, commands []string) (interface{}, error) {
+	result, err := s.db.ZScore(Key(commands[1]), commands[2])
+	if result == nil {
+		return nil, err
+	}
+	return *result, nil
+}
+
+// ProcessRespCommandZRank function processes redis command ZRANK
+func (s *Server) ProcessRespCommandZRank(c *Connection, commands []string) (interface{}, error) {
+	return s.processRank(commands, false)
+}
+
+// ProcessRespCommandZRevRank function processes redis command ZREVRANK
+func (s *Server) ProcessRespCommandZRevRank(c *Connection, commands []string) (interface{}, error) {
+	return s.processRank(commands, true)
+}
+
+// processRank function implements ZRANK and ZREVRANK. With WITHSCORE the
+// rank is replied along with the score of the member
+func (s *Server) processRank(commands []string, reverse bool) (interface{}, error) {
+	if len(commands) > 4 || (len(commands) == 4 && strings.ToUpper(commands[3]) != "WITHSCORE") {
+		return nil, ErrSyntax
+	}
+	rank, score, err := s.db.ZRank(Key(commands[1]), commands[2], reverse)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 3 {
+		if rank < 0 {
+			return nil, nil
+		}
+		return rank, nil
+	}
+	if rank < 0 {
+		return NullArray{}, nil
+	}
+	return []interface{}{rank, score}, nil
+}
+
+// parseScoreRange function parses the min and max arguments of commands
+// such as ZCOUNT, where a bound is exclusive if it is prefixed with (
+func parseScoreRange(min string, max string) (ScoreRange, error) {
+	result := ScoreRange{}
+	bounds := []struct {
+		arg       string
+		val       *float64
+		exclusive *bool
+	}{
+		{min, &result.Min, &result.MinExclusive},
+		{max, &result.Max, &result.MaxExclusive},
+	}
+	for _, b := range bounds {
+		arg := b.arg
+		if strings.HasPrefix(arg, "(") {
+			*b.exclusive = true
+			arg = arg[1:]
+		}
+		val, err := parseScore(arg)
+		if err != nil {
+			return result, errors.New("ERR min or max is not a float")
+		}
+		*b.val = val
+	}
+	return result, nil
+}
+
+// parseLexRange function parses the min and max arguments of lexicographical
+// ranges, where - and + are the lowest and highest bounds and any other
+// bound is prefixed with [ if inclusive or ( if exclusive
+func parseLexRange(min string, max string) (LexRange, error) {
+	result := LexRange{}
+	for i, arg := range []string{min, max} {
+		var bound LexBound
+		switch {
+		case arg == "-":
+			bound = LexBound{Inf: -1}
+		case arg == "+":
+			bound = LexBound{Inf: 1}
+		case strings.HasPrefix(arg, "["):
+			bound = LexBound{Member: arg[1:]}
+		case strings.HasPrefix(arg, "("):
+			bound = LexBound{Member: arg[1:], Exclusive: true}
+		default:
+			return result, errors.New("ERR min or max not valid string range item")
+		}
+		if i == 0 {
+			result.Min = bound
+		} else {
+			result.Max = bound
+		}
+	}
+	return result, nil
+}
+
+// ProcessRespCommandZCount function processes redis command ZCOUNT
+func (s *Server) ProcessRespCommandZCount(c *Connection, commands []string) (interface{}, error) {
+	r, err := parseScoreRange(commands[2], commands[3])
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ZCount(Key(commands[1]), r)
+}
+
+// zrangeArgs struct holds the options of ZRANGE and ZRANGESTORE. by is
+// either empty for ranges of ranks, BYSCORE or BYLEX
+type zrangeArgs struct {
+	by         string
+	reverse    bool
+	offset     int
+	count      int
+	withscores bool
+}
+
+// parseZRangeArgs function parses the options of ZRANGE and ZRANGESTORE
+// which follow the start and stop arguments, WITHSCORES is only accepted
+// if allow_withscores is set
+func parseZRangeArgs(args []string, allow_withscores bool) (zrangeArgs, error) {
+	result := zrangeArgs{count: -1}
+	limit := false
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "BYSCORE", "BYLEX":
+			result.by = strings.ToUpper(args[i])
+		case "REV":
+			result.reverse = true
+		case "WITHSCORES":
+			if !allow_withscores {
+				return result, ErrSyntax
+			}
+			result.withscores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return result, ErrSyntax
+			}
+			bounds, err := parseInts(args[i+1 : i+3])
+			if err != nil {
+				return result, err
+			}
+			result.offset, result.count = bounds[0], bounds[1]
+			limit = true
+			i += 2
+		default:
+			return result, ErrSyntax
+		}
+	}
+	if limit && result.by == "" {
+		return result, errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if result.withscores && result.by == "BYLEX" {
+		return result, errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	return result, nil
+}
+
+// zrange function returns the members of the sorted set stored at key
+// between start and stop following args. For ranges of scores and of
+// members start is the max if the range is reversed
+func (s *Server) zrange(key Key, start string, stop string, args zrangeArgs) ([]ZMember, error) {
+	if args.by != "" && args.reverse {
+		start, stop = stop, start
+	}
+	if args.offset < 0 {
+		// a negative offset always returns an empty range
+		args.count = 0
+	}
+	switch args.by {
+	case "BYSCORE":
+		r, err := parseScoreRange(start, stop)
+		if err != nil {
+			return nil, err
+		}
+		return s.db.ZRangeByScore(key, r, args.reverse, args.offset, args.count)
+	case "BYLEX":
+		r, err := parseLexRange(start, stop)
+		if err != nil {
+			return nil, err
+		}
+		return s.db.ZRangeByLex(key, r, args.reverse, args.offset, args.count)
+	}
+	bounds, err := parseInts([]string{start, stop})
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ZRange(key, bounds[0], bounds[1], args.reverse)
+}
+
+// zmembersReply function converts members into a reply. With scores each
+// member is followed by its score, in RESP3 every member and score are
+// replied as a pair of their own
+func zmembersReply(c *Connection, members []ZMember, withscores bool) interface{} {
+	if !withscores {
+		result := make([]string, len(members))
+		for i, m := range members {
+			result[i] = m.Member
+		}
+		return result
+	}
+	result := make([]interface{}, 0, 2*len(members))
+	for _, m := range members {
+		if c.proto >= 3 {
+			result = append(result, []interface{}{m.Member, m.Score})
+		} else {
+			result = append(result, m.Member, m.Score)
+		}
+	}
+	return result
+}
+
+// ProcessRespCommandZRange function processes redis command ZRANGE
+func (s *Server) ProcessRespCommandZRange(c *Connection, commands []string) (interface{}, error) {
+	args, err := parseZRangeArgs(commands[4:], true)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.zrange(Key(commands[1]), commands[2], commands[3], args)
+	if err != nil {
+		return nil, err
+	}
+	return zmembersReply(c, result, args.withscores), nil
+}
+
+// ProcessRespCommandZRangeStore function processes redis command
+// ZRANGESTORE
+func (s *Server) ProcessRespCommandZRangeStore(c *Connection, commands []string) (interface{}, error) {
+	args, err := parseZRangeArgs(commands[5:], false)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.zrange(Key(commands[2]), commands[3], commands[4], args)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ZStore(Key(commands[1]), result), nil
+}
+
+// ProcessRespCommandZPopMin function processes redis command ZPOPMIN
+func (s *Server) ProcessRespCommandZPopMin(c *Connection, commands []string) (interface{}, error) {
+	return s.processZPop(c, commands, false)
+}
+
+// ProcessRespCommandZPopMax function processes redis command ZPOPMAX
+func (s *Server) ProcessRespCommandZPopMax(c *Connection, commands []string) (interface{}, error) {
+	return s.processZPop(c, commands, true)
+}
+
+// processZPop function implements ZPOPMIN and ZPOPMAX. Without count the
+// member and its score are replied flat even in RESP3, the same as redis
+func (s *Server) processZPop(c *Connection, commands []string, max bool) (interface{}, error) {
+	if len(commands) > 3 {
+		return nil, ErrSyntax
+	}
+	count := int64(1)
+	if len(commands) == 3 {
+		val, err := strconv.ParseInt(commands[2], 10, 64)
+		if err != nil || val < 0 {
+			return nil, errors.New("ERR value is out of range, must be positive")
+		}
+		count = val
+	}
+	result, err := s.db.ZPop(Key(commands[1]), int(count), max)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 2 {
+		reply := []interface{}{}
+		for _, m := range result {
+			reply = append(reply, m.Member, m.Score)
+		}
+		return reply, nil
+	}
+	return zmembersReply(c, result, true), nil
+}
+
+// storeKeysAt function returns a function returning the keys of commands
+// which take a destination key followed by the number of keys at index i
+// and the keys, to be used as the MovableKeys of the command
+func storeKeysAt(i int) func(commands []string) []Key {
+	return func(commands []string) []Key {
+		return append([]Key{Key(commands[1])}, numKeysAt(i)(commands)...)
+	}
+}
+
+// ProcessRespCommandZUnionStore function processes redis command
+// ZUNIONSTORE
+func (s *Server) ProcessRespCommandZUnionStore(c *Connection, commands []string) (interface{}, error) {
+	return s.processZStore(commands, (*Storage).ZUnion)
+}
+
+// ProcessRespCommandZInterStore function processes redis command
+// ZINTERSTORE
+func (s *Server) ProcessRespCommandZInterStore(c *Connection, commands []string) (interface{}, error) {
+	return s.processZStore(commands, (*Storage).ZInter)
+}
+
+// processZStore function implements ZUNIONSTORE and ZINTERSTORE, parsing
+// the WEIGHTS and AGGREGATE options and storing the result of op
+func (s *Server) processZStore(commands []string, op func(*Storage, []Key, []float64, string) ([]ZMember, error)) (interface{}, error) {
+	keys, err := parseNumKeys(commands, 2)
+	if err != nil {
+		return nil, err
+	}
+	weights := make([]float64, len(keys))
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := "SUM"
+	for i := 3 + len(keys); i < len(commands); i++ {
+		switch strings.ToUpper(commands[i]) {
+		case "WEIGHTS":
+			if i+len(keys) >= len(commands) {
+				return nil, ErrSyntax
+			}
+			for j := range weights {
+				weight, err := parseScore(commands[i+1+j])
+				if err != nil {
+					return nil, errors.New("ERR weight value is not a float")
+				}
+				weights[j] = weight
+			}
+			i += len(keys)
+		case "AGGREGATE":
+			if i+1 >= len(commands) {
+				return nil, ErrSyntax
+			}
+			aggregate = strings.ToUpper(commands[i+1])
+			if aggregate != "SUM" && aggregate != "MIN" && aggregate != "MAX" {
+				return nil, ErrSyntax
+			}
+			i++
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	result, err := op(s.db, keys, weights, aggregate)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ZStore(Key(commands[1]), result), nil
+}
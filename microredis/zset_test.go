@@ -0,0 +1,250 @@
+package microredis_test
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/trueutkarsh/micro-redis/microredis"
+)
+
+func TestSkipList(t *testing.T) {
+	sl := m.NewSkipList()
+	expected := []m.ZMember{}
+	for i := 0; i < 1000; i++ {
+		member := m.ZMember{Member: strconv.Itoa(i), Score: float64(rand.Intn(100))}
+		sl.Insert(member.Score, member.Member)
+		expected = append(expected, member)
+	}
+	// remove a random half of the elements
+	rand.Shuffle(len(expected), func(i, j int) { expected[i], expected[j] = expected[j], expected[i] })
+	for _, member := range expected[500:] {
+		assert.True(t, sl.Delete(member.Score, member.Member))
+		assert.False(t, sl.Delete(member.Score, member.Member))
+	}
+	expected = expected[:500]
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Score != expected[j].Score {
+			return expected[i].Score < expected[j].Score
+		}
+		return expected[i].Member < expected[j].Member
+	})
+	assert.Equal(t, 500, sl.Len())
+	for rank, member := range expected {
+		assert.Equal(t, rank, sl.Rank(member.Score, member.Member))
+	}
+	assert.Equal(t, -1, sl.Rank(1000, "missing"))
+}
+
+func TestZSet(t *testing.T) {
+	z := m.NewZSet()
+	assert.True(t, z.Set("a", 1))
+	assert.True(t, z.Set("b", 2))
+	assert.True(t, z.Set("c", 3))
+	assert.False(t, z.Set("a", 4))
+	assert.Equal(t, 3, z.Len())
+
+	score, prs := z.Score("a")
+	assert.True(t, prs)
+	assert.Equal(t, float64(4), score)
+	rank, _ := z.Rank("a", false)
+	assert.Equal(t, 2, rank)
+	rank, _ = z.Rank("a", true)
+	assert.Equal(t, 0, rank)
+	_, prs = z.Rank("missing", false)
+	assert.False(t, prs)
+
+	assert.Equal(t, []m.ZMember{{"b", 2}, {"c", 3}, {"a", 4}}, z.Range(0, -1, false))
+	assert.Equal(t, []m.ZMember{{"c", 3}, {"b", 2}}, z.Range(1, 5, true))
+	assert.Equal(t, []m.ZMember{}, z.Range(2, 1, false))
+	assert.True(t, z.Remove("b"))
+	assert.False(t, z.Remove("b"))
+	assert.Equal(t, []m.ZMember{{"c", 3}, {"a", 4}}, z.Range(-5, 10, false))
+}
+
+func TestZSetStorage(t *testing.T) {
+	s := m.NewStorage(time.Second)
+
+	added, err := s.ZAdd(m.Key("z"), []m.ZMember{{"a", 1}, {"b", 2}, {"c", 3}}, m.ZAddFlags{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, added)
+	added, _ = s.ZAdd(m.Key("z"), []m.ZMember{{"a", 5}, {"d", 4}}, m.ZAddFlags{NX: true})
+	assert.Equal(t, 1, added)
+	changed, _ := s.ZAdd(m.Key("z"), []m.ZMember{{"a", 0}, {"b", 5}, {"e", 1}}, m.ZAddFlags{GT: true, CH: true})
+	assert.Equal(t, 2, changed)
+	changed, _ = s.ZAdd(m.Key("missing"), []m.ZMember{{"a", 1}}, m.ZAddFlags{XX: true, CH: true})
+	assert.Equal(t, 0, changed)
+	assert.Equal(t, "none", s.Type(m.Key("missing")))
+	assert.Equal(t, "zset", s.Type(m.Key("z")))
+
+	members, err := s.ZRange(m.Key("z"), 0, -1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []m.ZMember{{"a", 1}, {"e", 1}, {"c", 3}, {"d", 4}, {"b", 5}}, members)
+
+	score, err := s.ZIncrBy(m.Key("z"), "a", 2.5, m.ZAddFlags{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3.5, *score)
+	score, _ = s.ZIncrBy(m.Key("z"), "a", -1, m.ZAddFlags{GT: true})
+	assert.Nil(t, score)
+	s.ZIncrBy(m.Key("z"), "inf", math.Inf(1), m.ZAddFlags{})
+	_, err = s.ZIncrBy(m.Key("z"), "inf", math.Inf(-1), m.ZAddFlags{})
+	assert.EqualError(t, err, "ERR resulting score is not a number (NaN)")
+	s.ZRem(m.Key("z"), []string{"inf"})
+
+	count, _ := s.ZCount(m.Key("z"), m.ScoreRange{Min: 1, Max: 4})
+	assert.Equal(t, 4, count)
+	count, _ = s.ZCount(m.Key("z"), m.ScoreRange{Min: 1, Max: 4, MinExclusive: true, MaxExclusive: true})
+	assert.Equal(t, 2, count)
+	count, _ = s.ZCount(m.Key("z"), m.ScoreRange{Min: 4, Max: 1})
+	assert.Equal(t, 0, count)
+
+	members, _ = s.ZRangeByScore(m.Key("z"), m.ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, true, 1, 2)
+	assert.Equal(t, []m.ZMember{{"d", 4}, {"a", 3.5}}, members)
+	members, _ = s.ZRangeByLex(m.Key("z"), m.LexRange{Min: m.LexBound{Inf: -1}, Max: m.LexBound{Member: "e", Exclusive: true}}, false, 0, -1)
+	assert.Equal(t, []m.ZMember{}, members)
+
+	rank, score2, err := s.ZRank(m.Key("z"), "d", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rank)
+	assert.Equal(t, float64(4), score2)
+
+	popped, err := s.ZPop(m.Key("z"), 2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []m.ZMember{{"b", 5}, {"d", 4}}, popped)
+	popped, _ = s.ZPop(m.Key("z"), 10, false)
+	assert.Equal(t, []m.ZMember{{"e", 1}, {"c", 3}, {"a", 3.5}}, popped)
+	assert.Equal(t, "none", s.Type(m.Key("z")))
+
+	s.Set(m.Key("str"), "v", nil, false, false, false, false)
+	_, err = s.ZAdd(m.Key("str"), []m.ZMember{{"a", 1}}, m.ZAddFlags{})
+	assert.Equal(t, m.ErrWrongType, err)
+	_, err = s.ZUnion([]m.Key{"str"}, []float64{1}, "SUM")
+	assert.Equal(t, m.ErrWrongType, err)
+}
+
+func TestZUnionInter(t *testing.T) {
+	s := m.NewStorage(time.Second)
+	s.ZAdd(m.Key("a"), []m.ZMember{{"x", 1}, {"y", 2}}, m.ZAddFlags{})
+	s.ZAdd(m.Key("b"), []m.ZMember{{"y", 3}, {"z", 4}}, m.ZAddFlags{})
+	s.SAdd(m.Key("s"), []string{"y", "w"})
+
+	union, err := s.ZUnion([]m.Key{"a", "b", "missing"}, []float64{1, 2, 1}, "SUM")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []m.ZMember{{"x", 1}, {"y", 8}, {"z", 8}}, union)
+	union, _ = s.ZUnion([]m.Key{"a", "b"}, []float64{1, 1}, "MIN")
+	assert.ElementsMatch(t, []m.ZMember{{"x", 1}, {"y", 2}, {"z", 4}}, union)
+
+	// sets are accepted with a score of 1
+	inter, err := s.ZInter([]m.Key{"a", "b", "s"}, []float64{1, 1, 10}, "MAX")
+	assert.NoError(t, err)
+	assert.Equal(t, []m.ZMember{{"y", 10}}, inter)
+	inter, _ = s.ZInter([]m.Key{"a", "missing"}, []float64{1, 1}, "SUM")
+	assert.Empty(t, inter)
+
+	// 0 times an infinite score and the sum of opposite infinities are 0
+	s.ZAdd(m.Key("inf"), []m.ZMember{{"x", math.Inf(1)}}, m.ZAddFlags{})
+	s.ZAdd(m.Key("-inf"), []m.ZMember{{"x", math.Inf(-1)}}, m.ZAddFlags{})
+	inter, _ = s.ZInter([]m.Key{"inf", "-inf"}, []float64{1, 1}, "SUM")
+	assert.Equal(t, []m.ZMember{{"x", 0}}, inter)
+	inter, _ = s.ZInter([]m.Key{"inf", "a"}, []float64{0, 1}, "SUM")
+	assert.Equal(t, []m.ZMember{{"x", 1}}, inter)
+
+	assert.Equal(t, 1, s.ZStore(m.Key("dst"), inter))
+	assert.Equal(t, "zset", s.Type(m.Key("dst")))
+	assert.Equal(t, 0, s.ZStore(m.Key("dst"), []m.ZMember{}))
+	assert.Equal(t, "none", s.Type(m.Key("dst")))
+}
+
+func TestZSetCommands(t *testing.T) {
+	runSteps(t, []stepCase{
+		{"add", []step{
+			{args: []string{"ZADD", "z", "1", "a", "2", "b"}, reply: ":2\r\n"},
+			{args: []string{"ZADD", "z", "NX", "5", "a", "3", "c"}, reply: ":1\r\n"},
+			{args: []string{"ZADD", "z", "XX", "CH", "0", "a", "3", "d"}, reply: ":1\r\n"},
+			{args: []string{"ZADD", "z", "LT", "CH", "1", "a", "1", "b"}, reply: ":1\r\n"},
+			{args: []string{"ZADD", "z", "INCR", "1.5", "a"}, reply: "$3\r\n1.5\r\n"},
+			{args: []string{"ZADD", "z", "GT", "INCR", "-1", "a"}, reply: "$-1\r\n"},
+			{args: []string{"ZADD", "z", "NX", "XX", "1", "a"}, reply: "-ERR XX and NX options at the same time are not compatible\r\n"},
+			{args: []string{"ZADD", "z", "GT", "LT", "1", "a"}, reply: "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n"},
+			{args: []string{"ZADD", "z", "INCR", "1", "a", "2", "b"}, reply: "-ERR INCR option supports a single increment-element pair\r\n"},
+			{args: []string{"ZADD", "z", "1", "a", "2"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"ZADD", "z", "x", "a"}, reply: "-ERR value is not a valid float\r\n"},
+			{args: []string{"ZADD", "z", "nan", "a"}, reply: "-ERR value is not a valid float\r\n"},
+			{args: []string{"ZADD", "z", "-inf", "m"}, reply: ":1\r\n"},
+			{args: []string{"ZSCORE", "z", "m"}, reply: "$4\r\n-inf\r\n"},
+			{args: []string{"ZSCORE", "z", "a"}, reply: "$3\r\n1.5\r\n"},
+			{args: []string{"ZSCORE", "z", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"ZINCRBY", "z", "2", "b"}, reply: "$1\r\n3\r\n"},
+			{args: []string{"ZCARD", "z"}, reply: ":4\r\n"},
+			{args: []string{"ZREM", "z", "m", "missing"}, reply: ":1\r\n"},
+			{args: []string{"ZRANK", "z", "a"}, reply: ":0\r\n"},
+			{args: []string{"ZREVRANK", "z", "a"}, reply: ":2\r\n"},
+			{args: []string{"ZRANK", "z", "b", "WITHSCORE"}, reply: "*2\r\n:1\r\n$1\r\n3\r\n"},
+			{args: []string{"ZRANK", "z", "missing"}, reply: "$-1\r\n"},
+			{args: []string{"ZRANK", "z", "missing", "WITHSCORE"}, reply: "*-1\r\n"},
+			{args: []string{"ZCOUNT", "z", "(2.5", "+inf"}, reply: ":2\r\n"},
+			{args: []string{"ZCOUNT", "z", "a", "+inf"}, reply: "-ERR min or max is not a float\r\n"},
+			{args: []string{"OBJECT", "ENCODING", "z"}, reply: "$8\r\nskiplist\r\n"},
+			{args: []string{"TYPE", "z"}, reply: "+zset\r\n"},
+		}},
+		{"range", []step{
+			{args: []string{"ZADD", "z", "1", "a", "2", "b", "3", "c", "4", "d"}, reply: ":4\r\n"},
+			{args: []string{"ZRANGE", "z", "0", "1"}, reply: "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+			{args: []string{"ZRANGE", "z", "-2", "-1", "WITHSCORES"}, reply: "*4\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\nd\r\n$1\r\n4\r\n"},
+			{args: []string{"ZRANGE", "z", "0", "1", "REV"}, reply: "*2\r\n$1\r\nd\r\n$1\r\nc\r\n"},
+			{args: []string{"ZRANGE", "z", "(1", "3", "BYSCORE"}, reply: "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+			{args: []string{"ZRANGE", "z", "+inf", "-inf", "BYSCORE", "REV", "LIMIT", "1", "2"}, reply: "*2\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+			{args: []string{"ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", "-1", "2"}, reply: "*0\r\n"},
+			{args: []string{"ZRANGE", "z", "[b", "(d", "BYLEX"}, reply: "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+			{args: []string{"ZRANGE", "z", "+", "-", "BYLEX", "REV", "LIMIT", "0", "1"}, reply: "*1\r\n$1\r\nd\r\n"},
+			{args: []string{"ZRANGE", "z", "b", "d", "BYLEX"}, reply: "-ERR min or max not valid string range item\r\n"},
+			{args: []string{"ZRANGE", "z", "0", "1", "LIMIT", "0", "1"}, reply: "-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n"},
+			{args: []string{"ZRANGE", "z", "-", "+", "BYLEX", "WITHSCORES"}, reply: "-ERR syntax error, WITHSCORES not supported in combination with BYLEX\r\n"},
+			{args: []string{"ZRANGE", "z", "0", "x"}, reply: "-ERR value is not an integer or out of range\r\n"},
+			{args: []string{"ZRANGE", "missing", "0", "-1"}, reply: "*0\r\n"},
+			{args: []string{"ZRANGESTORE", "dst", "z", "2", "+inf", "BYSCORE"}, reply: ":3\r\n"},
+			{args: []string{"ZRANGE", "dst", "0", "-1"}, reply: "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n"},
+			{args: []string{"ZRANGESTORE", "dst", "z", "5", "+inf", "BYSCORE"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "dst"}, reply: "+none\r\n"},
+			{args: []string{"ZRANGESTORE", "dst", "z", "0", "1", "WITHSCORES"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"HELLO", "3"}, pattern: `^%7`},
+			{args: []string{"ZRANGE", "z", "0", "0", "WITHSCORES"}, reply: "*1\r\n*2\r\n$1\r\na\r\n,1\r\n"},
+			{args: []string{"ZSCORE", "z", "b"}, reply: ",2\r\n"},
+		}},
+		{"pop", []step{
+			{args: []string{"ZADD", "z", "1", "a", "2", "b", "3", "c"}, reply: ":3\r\n"},
+			{args: []string{"ZPOPMIN", "z"}, reply: "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+			{args: []string{"ZPOPMAX", "z", "5"}, reply: "*4\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+			{args: []string{"ZPOPMIN", "z"}, reply: "*0\r\n"},
+			{args: []string{"ZPOPMIN", "z", "-1"}, reply: "-ERR value is out of range, must be positive\r\n"},
+			{args: []string{"TYPE", "z"}, reply: "+none\r\n"},
+		}},
+		{"store", []step{
+			{args: []string{"ZADD", "a", "1", "x", "2", "y"}, reply: ":2\r\n"},
+			{args: []string{"ZADD", "b", "3", "y", "4", "z"}, reply: ":2\r\n"},
+			{args: []string{"ZUNIONSTORE", "dst", "2", "a", "b"}, reply: ":3\r\n"},
+			{args: []string{"ZRANGE", "dst", "0", "-1", "WITHSCORES"}, reply: "*6\r\n$1\r\nx\r\n$1\r\n1\r\n$1\r\nz\r\n$1\r\n4\r\n$1\r\ny\r\n$1\r\n5\r\n"},
+			{args: []string{"ZINTERSTORE", "dst", "2", "a", "b", "WEIGHTS", "2", "1", "AGGREGATE", "max"}, reply: ":1\r\n"},
+			{args: []string{"ZSCORE", "dst", "y"}, reply: "$1\r\n4\r\n"},
+			{args: []string{"ZINTERSTORE", "dst", "2", "a", "missing"}, reply: ":0\r\n"},
+			{args: []string{"TYPE", "dst"}, reply: "+none\r\n"},
+			{args: []string{"ZUNIONSTORE", "dst", "2", "a", "b", "WEIGHTS", "1"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"ZUNIONSTORE", "dst", "2", "a", "b", "WEIGHTS", "1", "x"}, reply: "-ERR weight value is not a float\r\n"},
+			{args: []string{"ZUNIONSTORE", "dst", "2", "a", "b", "AGGREGATE", "AVG"}, reply: "-ERR syntax error\r\n"},
+			{args: []string{"ZUNIONSTORE", "dst", "3", "a", "b"}, reply: "-ERR Number of keys can't be greater than number of args\r\n"},
+			{args: []string{"COMMAND", "GETKEYS", "ZUNIONSTORE", "dst", "2", "a", "b", "WEIGHTS", "1", "2"}, reply: "*3\r\n$3\r\ndst\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		}},
+		{"wrongtype", []step{
+			{args: []string{"SET", "s", "v"}, reply: "+OK\r\n"},
+			{args: []string{"ZADD", "s", "1", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"ZRANGE", "s", "0", "-1"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"ZADD", "z", "1", "a"}, reply: ":1\r\n"},
+			{args: []string{"GET", "z"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			{args: []string{"SADD", "z", "a"}, reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		}},
+	})
+}